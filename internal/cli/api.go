@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultAddr 本地守护进程http接口地址
+const defaultAddr = "http://127.0.0.1:12345"
+
+// apiClient 访问守护进程http接口
+type apiClient struct {
+	addr string
+	cli  *http.Client
+}
+
+func newAPIClient(addr string, timeout time.Duration) *apiClient {
+	return &apiClient{
+		addr: strings.TrimSuffix(addr, "/"),
+		cli:  &http.Client{Timeout: timeout},
+	}
+}
+
+// do 发送请求，body非空时以json编码，响应为json时解析到out
+func (a *apiClient) do(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, a.addr+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := a.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, e.Error)
+		}
+		return fmt.Errorf("%s %s: %d", method, path, resp.StatusCode)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
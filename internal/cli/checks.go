@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/pkg/server"
+	"system-usability-detection/pkg/status_check"
+)
+
+// Checks checks run，不经过守护进程在本地执行一次所有配置的检测
+func Checks(args []string) int {
+	if len(args) == 0 || args[0] != "run" {
+		fmt.Fprintln(os.Stderr, "usage: system-usability-detection checks run [flags]")
+		return 2
+	}
+	fs := flag.NewFlagSet("checks run", flag.ExitOnError)
+	o := addOptions(fs, false)
+	timeout := fs.Duration("timeout", 0, "timeout of each check, overrides the configured timeout")
+	warmup := fs.Duration("warmup", 0, "run checks once and wait before the reported run, for checks comparing consecutive samples (interface)")
+	_ = fs.Parse(args[1:])
+
+	if err := config.Setup(o.configPath); err != nil {
+		return fail("load config failed: %v", err)
+	}
+	// 不连接etcd：power_cache等模块的后台任务会写入守护进程维护的etcd键，
+	// 命令行诊断不能修改集群状态或与守护进程竞争，需要etcd的模块启动失败，其余模块照常执行
+	vi := config.GlobalConfigInstance.VrrpInstances
+	env := status_check.NewEnv(nil)
+	si, err := status_check.Build(vi.Checks(), env)
+	if err != nil {
+		return fail("build checks failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := status_check.StartWorkers(ctx, si); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if *warmup > 0 {
+		// 预先执行一轮，按相邻两次采样比较的模块(interface等)需要基准值
+		for _, c := range si {
+			c.CheckStatus(ctx)
+		}
+		time.Sleep(*warmup)
+	}
+	sts := make([]status_check.StatusAction, 0, len(si))
+	used := make(map[string]time.Duration, len(si))
+	for _, c := range si {
+		if *timeout > 0 {
+			c = status_check.WithTimeout(c, *timeout)
+		}
+		now := time.Now()
+		sts = append(sts, c.CheckStatus(ctx))
+		used[c.Name()] = time.Since(now)
+	}
+	report := server.NewHealthReport(sts)
+	code := o.print(report, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "CHECK\tSTATUS\tUSED\tEXTRA")
+		for _, c := range report.Checks {
+			fmt.Fprintf(w, "%s\t%s\t%v\t%s\n", c.Name, c.Level, used[c.Name].Round(time.Millisecond), firstLine(c.Extra))
+		}
+	})
+	if code == 0 && !report.Healthy {
+		return 1
+	}
+	return code
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/internal/version"
+	"system-usability-detection/pkg/server"
+	"system-usability-detection/pkg/status_check"
+)
+
+const defaultConfig = "./config.yml"
+
+// command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"serve", "run the detection daemon", Serve},
+		{"status", "show health checks and vips of the local node", Status},
+		{"vips", "show vips of the local node and their members", Vips},
+		{"checks", "run configured checks once: checks run", Checks},
+		{"drain", "drain vips of the local node", Drain},
+		{"undrain", "undrain vips of the local node", Undrain},
+		{"move-vip", "move a vip to a named node", MoveVip},
+		{"notify", "report a keepalived state transition to the daemon, used as notify script", Notify},
+		{"vrrp", "show keepalived states reported by notify scripts: vrrp [-history]", Vrrp},
+		{"gen-keepalived", "render keepalived.conf of the local node from the config file", GenKeepalived},
+		{"config", "validate the config file: config validate", Config},
+	}
+}
+
+// Run 解析子命令并执行，返回进程退出码
+// 未指定子命令时兼容旧参数 -config -version -support，默认启动守护进程
+func Run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return legacy(args)
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			if c.name != "serve" {
+				util.SetLogLevel(slog.LevelWarn)
+			}
+			return c.run(args[1:])
+		}
+	}
+	switch args[0] {
+	case "version":
+		fmt.Println(version.Version)
+		return 0
+	case "help":
+		usage()
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: system-usability-detection <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(w, "  %s\t%s\n", "version", "print version")
+	w.Flush()
+	fmt.Fprintln(os.Stderr, "\nrun 'system-usability-detection <command> -h' for command flags")
+}
+
+func legacy(args []string) int {
+	fs := flag.NewFlagSet("system-usability-detection", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfig, "config file")
+	versionInfo := fs.Bool("version", false, "print version")
+	supportType := fs.Bool("support", false, "print support check types")
+	fs.Usage = usage
+	_ = fs.Parse(args)
+
+	if *versionInfo {
+		fmt.Printf("version: %s\n", version.Version)
+		return 0
+	}
+	if *supportType {
+		fmt.Println("support check types: ")
+		for _, v := range status_check.GetAllSupportType() {
+			f, _ := status_check.Lookup(v)
+			fmt.Printf("\t %s \t%s\n", v, f.Description)
+		}
+		return 0
+	}
+	return Serve([]string{"-config", *configPath})
+}
+
+// options 各子命令通用参数
+type options struct {
+	addr       string
+	configPath string
+	output     string
+	etcd       bool
+}
+
+// addOptions 注册通用参数，withEtcd为true时支持不经过守护进程直接访问etcd
+func addOptions(fs *flag.FlagSet, withEtcd bool) *options {
+	o := &options{}
+	fs.StringVar(&o.addr, "addr", defaultAddr, "daemon http address")
+	fs.StringVar(&o.configPath, "config", defaultConfig, "config file")
+	fs.StringVar(&o.output, "o", "table", "output format: table|json")
+	if withEtcd {
+		fs.BoolVar(&o.etcd, "etcd", false, "talk to etcd directly using endpoints from the config file instead of the daemon")
+	}
+	return o
+}
+
+func (o *options) api() *apiClient {
+	return newAPIClient(o.addr, 10*time.Second)
+}
+
+// etcdServer 直接访问etcd时使用的BrainServer，不启动后台任务
+func (o *options) etcdServer() (*server.BrainServer, error) {
+	if err := config.Setup(o.configPath); err != nil {
+		return nil, err
+	}
+	b, err := server.NewBrainServer()
+	if err != nil {
+		return nil, err
+	}
+	// 加载维护模式，保证输出与守护进程一致
+	if _, err := b.LoadDrain(context.Background()); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// print 按输出格式打印，table格式由table函数输出
+func (o *options) print(v any, table func(w *tabwriter.Writer)) int {
+	if o.output == "json" {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(string(data))
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	table(w)
+	w.Flush()
+	return 0
+}
+
+func fail(format string, args ...any) int {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	return 1
+}
+
+func boolText(b bool, t, f string) string {
+	if b {
+		return t
+	}
+	return f
+}
+
+// firstLine 表格中只显示多行文本的第一行，完整内容见-o json
+func firstLine(s string) string {
+	if line, _, ok := strings.Cut(s, "\n"); ok {
+		return line + " ..."
+	}
+	return s
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/pkg/status_check"
+)
+
+// Config config validate，校验配置文件
+func Config(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: system-usability-detection config validate [-config path]")
+		return 2
+	}
+	fs := flag.NewFlagSet("config validate", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfig, "config file")
+	_ = fs.Parse(args[1:])
+
+	c, err := config.Load(*configPath)
+	if err != nil {
+		return fail("%v", err)
+	}
+	errs := c.Validate()
+	for i, ins := range c.Instances {
+		for _, err := range status_check.Validate(ins.Check) {
+			errs = append(errs, fmt.Errorf("instances[%d]: %w", i, err))
+		}
+	}
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+	fmt.Printf("%s: ok\n", *configPath)
+	return 0
+}
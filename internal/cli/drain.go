@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"system-usability-detection/pkg/server"
+)
+
+// Drain 本节点vip进入维护模式
+func Drain(args []string) int {
+	return drain("drain", args)
+}
+
+// Undrain 本节点vip退出维护模式
+func Undrain(args []string) int {
+	return drain("undrain", args)
+}
+
+func drain(name string, args []string) int {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	o := addOptions(fs, true)
+	req := &server.DrainRequest{}
+	if name == "drain" {
+		fs.StringVar(&req.Duration, "duration", "", "drain expires after the duration, e.g. 2h; empty means until undrain")
+		fs.StringVar(&req.Reason, "reason", "", "reason recorded with the drain state")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: system-usability-detection %s [flags] [vip...]\nwithout vips all vips of the local node are %sed\n", name, name)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	req.Vips = fs.Args()
+
+	var (
+		states []*server.DrainState
+		err    error
+	)
+	if o.etcd {
+		b, err := o.etcdServer()
+		if err != nil {
+			return fail("init etcd client failed: %v", err)
+		}
+		if name == "drain" {
+			states, err = b.Drain(context.Background(), req)
+		} else {
+			states, err = b.Undrain(context.Background(), req)
+		}
+		if err != nil {
+			return fail("%s failed: %v", name, err)
+		}
+	} else if err = o.api().do(http.MethodPost, "/v1/admin/"+name, req, &states); err != nil {
+		return fail("%s failed: %v", name, err)
+	}
+	return o.print(states, func(w *tabwriter.Writer) {
+		drainTable(w, states)
+	})
+}
+
+func drainTable(w *tabwriter.Writer, states []*server.DrainState) {
+	fmt.Fprintln(w, "NODE\tIP\tVIP\tEXPIRES\tREASON")
+	for _, ds := range states {
+		expires := "never"
+		if ds.ExpiresAt != nil {
+			expires = ds.ExpiresAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ds.Node, ds.IP, ds.Vip, expires, strings.ReplaceAll(ds.Reason, "\t", " "))
+	}
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/keepalived"
+	"system-usability-detection/internal/textdiff"
+)
+
+// GenKeepalived 根据配置文件生成本节点的keepalived.conf，-diff时与现有文件比较，存在差异时返回1
+func GenKeepalived(args []string) int {
+	fs := flag.NewFlagSet("gen-keepalived", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfig, "config file")
+	node := fs.String("node", "", "instance name in the config file, default is the hostname")
+	tmplPath := fs.String("template", "", "text/template file used instead of the built-in template")
+	printTmpl := fs.Bool("print-template", false, "print the built-in template and exit")
+	out := fs.String("out", "", "write to the file instead of stdout")
+	diffPath := fs.String("diff", "", "compare with the existing file and print a unified diff instead of writing")
+	checkURL := fs.String("check-url", defaultAddr+"/check", "url of the daemon /check endpoint")
+	curl := fs.String("curl", "/usr/bin/curl", "curl used by vrrp_script")
+	timeout := fs.Int("check-timeout", 1, "curl timeout in seconds")
+	interval := fs.Int("interval", 2, "vrrp_script interval in seconds")
+	fall := fs.Int("fall", 2, "vrrp_script fall")
+	rise := fs.Int("rise", 2, "vrrp_script rise")
+	weight := fs.Int("weight", 0, "vrrp_script weight, 0 puts the instance into FAULT when /check fails")
+	advertInt := fs.Int("advert-int", 1, "vrrp advert_int in seconds")
+	notify := fs.String("notify", "", "notify command, default is '<this binary> notify', 'none' disables notify")
+	_ = fs.Parse(args)
+
+	if *printTmpl {
+		fmt.Print(keepalived.DefaultTemplate)
+		return 0
+	}
+	if *out != "" && *diffPath != "" {
+		return fail("-out and -diff are mutually exclusive")
+	}
+	c, err := config.Load(*configPath)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if errs := c.Validate(); len(errs) > 0 {
+		return fail("invalid config %s: %v", *configPath, errors.Join(errs...))
+	}
+	if *node == "" {
+		if *node, err = os.Hostname(); err != nil {
+			return fail("get hostname failed: %v", err)
+		}
+	}
+	data := &keepalived.ConfData{
+		Node:      *node,
+		Interface: c.Interface,
+		Curl:      *curl,
+		Check: keepalived.CheckScript{
+			URL:           *checkURL,
+			Timeout:       *timeout,
+			Interval:      *interval,
+			ScriptTimeout: *timeout + 1,
+			Fall:          *fall,
+			Rise:          *rise,
+			Weight:        *weight,
+		},
+		Notify:    *notify,
+		AdvertInt: *advertInt,
+	}
+	switch data.Notify {
+	case "none":
+		data.Notify = ""
+	case "":
+		exe, err := os.Executable()
+		if err != nil {
+			return fail("get executable path failed: %v", err)
+		}
+		data.Notify = exe + " notify"
+	}
+	if data.Instances, err = confInstances(c, *node); err != nil {
+		return fail("%v", err)
+	}
+	var tmpl string
+	if *tmplPath != "" {
+		b, err := os.ReadFile(*tmplPath)
+		if err != nil {
+			return fail("read template failed: %v", err)
+		}
+		tmpl = string(b)
+	}
+	var buf bytes.Buffer
+	if err := keepalived.Render(&buf, tmpl, data); err != nil {
+		return fail("render keepalived.conf failed: %v", err)
+	}
+
+	switch {
+	case *diffPath != "":
+		existing, err := os.ReadFile(*diffPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fail("read %s failed: %v", *diffPath, err)
+		}
+		d := textdiff.Unified(*diffPath, "generated", string(existing), buf.String(), 3)
+		if d == "" {
+			return 0
+		}
+		fmt.Print(d)
+		return 1
+	case *out != "":
+		if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+			return fail("write %s failed: %v", *out, err)
+		}
+		return 0
+	}
+	_, _ = os.Stdout.Write(buf.Bytes())
+	return 0
+}
+
+// confInstances 本节点各vip的vrrp_instance，virtual_router_id由vip决定，需检查集群内所有vip是否冲突
+func confInstances(c *config.Config, node string) ([]keepalived.ConfInstance, error) {
+	var (
+		local []keepalived.ConfInstance
+		found bool
+	)
+	vrids := make(map[int]string)
+	for _, ins := range c.Instances {
+		for _, v := range ins.Vips {
+			ci, err := keepalived.NewConfInstance(v.Vip, v.Priority)
+			if err != nil {
+				return nil, fmt.Errorf("instance %s: %w", ins.Name, err)
+			}
+			if other, ok := vrids[ci.VRID]; ok && other != ci.Vip {
+				return nil, fmt.Errorf("vip %s and %s have the same virtual_router_id %d", other, ci.Vip, ci.VRID)
+			}
+			vrids[ci.VRID] = ci.Vip
+			if ins.Name == node {
+				local = append(local, ci)
+			}
+		}
+		if ins.Name == node {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no instance named %s found in config file", node)
+	}
+	return local, nil
+}
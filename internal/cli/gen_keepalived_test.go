@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"system-usability-detection/internal/config"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+const sampleConfig = "../../config/config.yml"
+
+// genKeepalived 使用固定的notify命令，结果不依赖测试程序路径
+func genKeepalived(t *testing.T, args ...string) int {
+	t.Helper()
+	return GenKeepalived(append([]string{"-config", sampleConfig, "-node", "node1", "-notify", "/usr/bin/system-usability-detection notify"}, args...))
+}
+
+func TestGenKeepalivedGolden(t *testing.T) {
+	golden := filepath.Join("testdata", "keepalived-node1.conf")
+	out := filepath.Join(t.TempDir(), "keepalived.conf")
+	if code := genKeepalived(t, "-out", out); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("rendered keepalived.conf differs from %s, run go test -update:\n%s", golden, got)
+	}
+
+	// -diff 无差异时返回0，有差异或文件不存在时返回1
+	if code := genKeepalived(t, "-diff", golden); code != 0 {
+		t.Fatalf("diff with golden: exit code %d", code)
+	}
+	changed := filepath.Join(t.TempDir(), "changed.conf")
+	if err := os.WriteFile(changed, []byte(strings.Replace(string(want), "priority 100", "priority 50", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := genKeepalived(t, "-diff", changed); code != 1 {
+		t.Fatalf("diff with changed file: exit code %d", code)
+	}
+	if code := genKeepalived(t, "-diff", filepath.Join(t.TempDir(), "missing.conf")); code != 1 {
+		t.Fatalf("diff with missing file: exit code %d", code)
+	}
+}
+
+func TestConfInstances(t *testing.T) {
+	cases := []struct {
+		name string
+		// 每个节点的vip
+		nodes map[string][]string
+		node  string
+		want  []string
+		err   string
+	}{
+		{"shared vips", map[string][]string{"n1": {"10.0.0.1", "10.0.0.2"}, "n2": {"10.0.0.1", "10.0.0.2"}}, "n1", []string{"VI_10_0_0_1", "VI_10_0_0_2"}, ""},
+		{"vrid collision", map[string][]string{"n1": {"10.0.0.5"}, "n2": {"10.0.1.5"}}, "n1", nil, "same virtual_router_id 5"},
+		{"zero octet", map[string][]string{"n1": {"10.0.1.0"}}, "n1", nil, "must not be 0"},
+		{"unknown node", map[string][]string{"n1": {"10.0.0.1"}}, "n3", nil, "no instance named n3"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var sb strings.Builder
+			sb.WriteString("interface: eth0\netcd: [127.0.0.1:2379]\ninstances:\n")
+			for _, n := range []string{"n1", "n2"} {
+				if len(c.nodes[n]) == 0 {
+					continue
+				}
+				sb.WriteString("  - name: " + n + "\n    vips:\n")
+				for _, vip := range c.nodes[n] {
+					sb.WriteString("      - priority: 100\n        vip: " + vip + "\n")
+				}
+			}
+			path := filepath.Join(t.TempDir(), "config.yml")
+			if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := config.Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := confInstances(cfg, c.node)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("err=%v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, ci := range got {
+				names = append(names, ci.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(c.want, ",") {
+				t.Fatalf("instances %v, want %v", names, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"system-usability-detection/pkg/server"
+)
+
+// MoveVip move-vip <vip> <node>，通过本地守护进程将vip切换到指定节点
+func MoveVip(args []string) int {
+	fs := flag.NewFlagSet("move-vip", flag.ExitOnError)
+	o := addOptions(fs, false)
+	mode := fs.String("mode", server.MoveModeRaise, "raise target priority or lower the others: raise|lower")
+	timeout := fs.Duration("timeout", 30*time.Second, "wait for the target to own the vip, rollback after timeout")
+	hold := fs.Duration("hold", 0, "keep the override for a while after the target owns the vip")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: system-usability-detection move-vip [flags] <vip> <node>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	req := &server.MoveVipRequest{
+		Vip:     fs.Arg(0),
+		Node:    fs.Arg(1),
+		Mode:    *mode,
+		Timeout: timeout.String(),
+		Hold:    hold.String(),
+	}
+	res := &server.MoveVipResult{}
+	api := newAPIClient(o.addr, *timeout+*hold+30*time.Second)
+	if err := api.do(http.MethodPost, "/v1/admin/move-vip", req, res); err != nil {
+		return fail("move vip failed: %v", err)
+	}
+	return o.print(res, func(w *tabwriter.Writer) {
+		overrides := make([]string, 0, len(res.Overrides))
+		for _, m := range res.Overrides {
+			overrides = append(overrides, fmt.Sprintf("%s=%d", m.IP, m.Priority))
+		}
+		fmt.Fprintln(w, "VIP\tTARGET\tPREVIOUS OWNER\tOVERRIDES\tELAPSED")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", res.Vip, res.Target, res.PreviousOwner, strings.Join(overrides, ","), res.Elapsed)
+	})
+}
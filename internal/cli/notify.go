@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"system-usability-detection/pkg/server"
+)
+
+// Notify notify [INSTANCE] <instance> <state> [priority]，作为keepalived的notify脚本将状态变化上报给本地守护进程
+//
+//	notify_master "/usr/bin/system-usability-detection notify VI_1 MASTER"
+//	notify "/usr/bin/system-usability-detection notify"
+//
+// 通过notify配置时keepalived会追加 INSTANCE <instance> <state> <priority> 参数
+func Notify(args []string) int {
+	fs := flag.NewFlagSet("notify", flag.ExitOnError)
+	o := addOptions(fs, false)
+	timeout := fs.Duration("timeout", 5*time.Second, "request timeout, keepalived waits for notify scripts")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: system-usability-detection notify [flags] [INSTANCE] <instance|vip> <MASTER|BACKUP|FAULT|STOP> [priority]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	rest := fs.Args()
+	if len(rest) > 0 {
+		switch rest[0] {
+		case "INSTANCE":
+			rest = rest[1:]
+		case "GROUP":
+			return fail("notify of vrrp_sync_group is not supported, configure notify in vrrp_instance")
+		}
+	}
+	if len(rest) != 2 && len(rest) != 3 {
+		fs.Usage()
+		return 2
+	}
+	req := &server.NotifyRequest{Instance: rest[0], State: rest[1]}
+	if len(rest) == 3 {
+		p, err := strconv.Atoi(rest[2])
+		if err != nil {
+			return fail("invalid priority %q", rest[2])
+		}
+		req.Priority = p
+	}
+	res := &server.NotifyResult{}
+	if err := newAPIClient(o.addr, *timeout).do(http.MethodPost, "/v1/notify", req, res); err != nil {
+		return fail("notify failed: %v", err)
+	}
+	for _, c := range res.Conflicts {
+		fmt.Fprintf(os.Stderr, "warning: vip %s is claimed MASTER by %s\n", c.Vip, masterNodes(c.Masters))
+	}
+	return o.print(res, func(w *tabwriter.Writer) {
+		vrrpTable(w, res.Transitions)
+	})
+}
+
+// Vrrp 各节点通过notify脚本上报的当前状态，-history时输出状态变化记录
+func Vrrp(args []string) int {
+	fs := flag.NewFlagSet("vrrp", flag.ExitOnError)
+	o := addOptions(fs, true)
+	history := fs.Bool("history", false, "show state transitions instead of current states")
+	vip := fs.String("vip", "", "only show transitions of the vip, used with -history")
+	limit := fs.Int("n", 20, "max transitions to show, 0 means all, used with -history")
+	_ = fs.Parse(args)
+
+	var (
+		st   = &server.VrrpStatus{}
+		hist []*server.VrrpTransition
+	)
+	if o.etcd {
+		b, err := o.etcdServer()
+		if err != nil {
+			return fail("init etcd client failed: %v", err)
+		}
+		if *history {
+			hist, err = b.VrrpHistory(context.Background(), *vip, *limit)
+		} else {
+			st, err = b.VrrpStatus(context.Background())
+		}
+		if err != nil {
+			return fail("get vrrp state failed: %v", err)
+		}
+	} else if *history {
+		q := url.Values{"vip": {*vip}, "limit": {strconv.Itoa(*limit)}}
+		if err := o.api().do(http.MethodGet, "/v1/vrrp/history?"+q.Encode(), nil, &hist); err != nil {
+			return fail("get vrrp history failed: %v", err)
+		}
+	} else if err := o.api().do(http.MethodGet, "/v1/vrrp", nil, st); err != nil {
+		return fail("get vrrp state failed: %v", err)
+	}
+	if *history {
+		return o.print(hist, func(w *tabwriter.Writer) {
+			vrrpTable(w, hist)
+		})
+	}
+	code := o.print(st, func(w *tabwriter.Writer) {
+		vrrpTable(w, st.States)
+		if len(st.Conflicts) > 0 {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "CONFLICT VIP\tMASTERS")
+			for _, c := range st.Conflicts {
+				fmt.Fprintf(w, "%s\t%s\n", c.Vip, masterNodes(c.Masters))
+			}
+		}
+	})
+	if code == 0 && len(st.Conflicts) > 0 {
+		return 1
+	}
+	return code
+}
+
+func vrrpTable(w *tabwriter.Writer, states []*server.VrrpTransition) {
+	fmt.Fprintln(w, "VIP\tINSTANCE\tSTATE\tPRIORITY\tNODE\tIP\tTIME")
+	for _, t := range states {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", t.Vip, t.Instance, t.State, t.Priority, t.Node, t.IP, t.Time.Format("2006-01-02 15:04:05.000"))
+	}
+}
+
+func masterNodes(masters []*server.VrrpTransition) string {
+	nodes := make([]string, 0, len(masters))
+	for _, t := range masters {
+		nodes = append(nodes, t.Node+"("+t.IP+")")
+	}
+	return strings.Join(nodes, ",")
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+	"system-usability-detection/pkg/server"
+	"system-usability-detection/pkg/status_check"
+
+	"github.com/gorilla/mux"
+)
+
+// Serve 启动守护进程
+func Serve(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath := fs.String("config", defaultConfig, "config file")
+	httpAddr := fs.String("listen", ":12345", "http listen address")
+	metricsAddr := fs.String("metrics", ":12346", "metrics listen address")
+	grpcAddr := fs.String("grpc", ":12347", "grpc listen address")
+	_ = fs.Parse(args)
+
+	// 解析配置文件
+	config.ParseConfig(*configPath)
+	// 启动失败时返回非0，systemd按Restart=on-failure重启
+	if err := NewService(*httpAddr, *metricsAddr, *grpcAddr); err != nil {
+		util.Logger.Error("start service failed:%v", err)
+		return 1
+	}
+	return 0
+}
+
+// NewService 启动服务并阻塞到收到退出信号，初始化失败时返回错误
+func NewService(httpAddr, metricsAddr, grpcAddr string) error {
+	brainServer, err := server.NewBrainServer()
+	if err != nil {
+		return fmt.Errorf("init split brain brainServer: %w", err)
+	}
+	si, err := status_check.Build(config.GlobalConfigInstance.VrrpInstances.Checks(), status_check.NewEnv(brainServer.Etcd()))
+	if err != nil {
+		return fmt.Errorf("build checks: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	brainServer.Start(ctx, si)
+
+	router := mux.NewRouter()
+	router.Methods(http.MethodGet).Path("/check").HandlerFunc(brainServer.BrainCheckHandler)
+	router.Methods(http.MethodGet).Path("/v1/health").HandlerFunc(brainServer.HealthHandler)
+	router.Methods(http.MethodGet).Path("/v1/vips").HandlerFunc(brainServer.VipsHandler)
+	router.Methods(http.MethodGet).Path("/v1/events").HandlerFunc(brainServer.EventsHandler)
+	router.Methods(http.MethodGet).Path("/v1/keepalived").HandlerFunc(brainServer.KeepalivedHandler)
+	// keepalived notify脚本
+	router.Methods(http.MethodPost).Path("/v1/notify").HandlerFunc(brainServer.NotifyHandler)
+	router.Methods(http.MethodGet).Path("/v1/vrrp").HandlerFunc(brainServer.VrrpHandler)
+	router.Methods(http.MethodGet).Path("/v1/vrrp/history").HandlerFunc(brainServer.VrrpHistoryHandler)
+	// 维护模式
+	router.Methods(http.MethodGet).Path("/v1/admin/drain").HandlerFunc(brainServer.ListDrainHandler)
+	router.Methods(http.MethodPost).Path("/v1/admin/drain").HandlerFunc(brainServer.DrainHandler)
+	router.Methods(http.MethodPost).Path("/v1/admin/undrain").HandlerFunc(brainServer.UndrainHandler)
+	router.Methods(http.MethodPost).Path("/v1/admin/move-vip").HandlerFunc(brainServer.MoveVipHandler)
+	// pprof
+	router.Methods(http.MethodGet).Path("/debug/pprof/").HandlerFunc(pprof.Index)
+	router.Methods(http.MethodGet).Path("/debug/pprof/cmdline").HandlerFunc(pprof.Cmdline)
+	router.Methods(http.MethodGet).Path("/debug/pprof/profile").HandlerFunc(pprof.Profile)
+	router.Methods(http.MethodGet).Path("/debug/pprof/symbol").HandlerFunc(pprof.Symbol)
+	router.Methods(http.MethodGet).Path("/debug/pprof/trace").HandlerFunc(pprof.Trace)
+
+	srv := &http.Server{
+		Addr:         httpAddr,
+		WriteTimeout: time.Second * 60,
+		ReadTimeout:  time.Second * 60,
+		IdleTimeout:  time.Second * 120,
+		Handler:      router,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			util.Logger.Error("http brainServer listen failed:%v", err)
+		}
+	}()
+	// grpc服务，与http接口一致
+	grpcServer := brainServer.NewGRPCServer(ctx)
+	go func() {
+		lis, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			util.Logger.Error("grpc brainServer listen failed:%v", err)
+			return
+		}
+		if err := grpcServer.Serve(lis); err != nil {
+			util.Logger.Error("grpc brainServer serve failed:%v", err)
+		}
+	}()
+	util.Logger.Info("started service")
+	//开启指标采集
+	go metrics.StartMetricsServer(metricsAddr)
+	//开启push gateway推送
+	go metrics.LoopPushingMetric("split_brain_check", "", 0)
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	<-c
+	cancel()
+	grpcServer.GracefulStop()
+	if err := srv.Shutdown(context.Background()); err != nil {
+		util.Logger.Warn("shutdown http brainServer failed:%v", err)
+	}
+	util.Logger.Info("shutdown service")
+	return nil
+}
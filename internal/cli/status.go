@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+
+	"system-usability-detection/pkg/server"
+)
+
+// nodeStatus status子命令输出
+type nodeStatus struct {
+	Health *server.HealthReport `json:"health,omitempty"`
+	Vips   []*server.VipStatus  `json:"vips"`
+	Drain  []*server.DrainState `json:"drain,omitempty"`
+}
+
+// Status 本节点检测结果及vip状态，-etcd模式下没有检测结果，额外输出集群维护模式记录
+func Status(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	o := addOptions(fs, true)
+	_ = fs.Parse(args)
+
+	st := &nodeStatus{}
+	if o.etcd {
+		b, err := o.etcdServer()
+		if err != nil {
+			return fail("init etcd client failed: %v", err)
+		}
+		if st.Vips, err = b.ListVips(context.Background()); err != nil {
+			return fail("list vips failed: %v", err)
+		}
+		if st.Drain, err = b.ListDrain(context.Background()); err != nil {
+			return fail("list drain failed: %v", err)
+		}
+	} else {
+		api := o.api()
+		st.Health = &server.HealthReport{}
+		// 不健康时接口返回503，仍然包含检测结果
+		if err := api.do(http.MethodGet, "/v1/health", nil, st.Health); err != nil && st.Health.Time.IsZero() {
+			return fail("get health failed: %v", err)
+		}
+		if err := api.do(http.MethodGet, "/v1/vips", nil, &st.Vips); err != nil {
+			return fail("get vips failed: %v", err)
+		}
+	}
+	code := o.print(st, func(w *tabwriter.Writer) {
+		if st.Health != nil {
+			fmt.Fprintf(w, "HEALTHY:\t%s\t(%s)\n\n", boolText(st.Health.Healthy, "yes", "no"), st.Health.Time.Format("2006-01-02 15:04:05"))
+			fmt.Fprintln(w, "CHECK\tSTATUS\tEXTRA")
+			for _, c := range st.Health.Checks {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, c.Level, firstLine(c.Extra))
+			}
+			fmt.Fprintln(w)
+		}
+		vipsTable(w, st.Vips)
+		if len(st.Drain) > 0 {
+			fmt.Fprintln(w)
+			drainTable(w, st.Drain)
+		}
+	})
+	if code == 0 && st.Health != nil && !st.Health.Healthy {
+		return 1
+	}
+	return code
+}
+
+// Vips 本节点vip及etcd中注册的节点
+func Vips(args []string) int {
+	fs := flag.NewFlagSet("vips", flag.ExitOnError)
+	o := addOptions(fs, true)
+	_ = fs.Parse(args)
+
+	var vips []*server.VipStatus
+	if o.etcd {
+		b, err := o.etcdServer()
+		if err != nil {
+			return fail("init etcd client failed: %v", err)
+		}
+		if vips, err = b.ListVips(context.Background()); err != nil {
+			return fail("list vips failed: %v", err)
+		}
+	} else if err := o.api().do(http.MethodGet, "/v1/vips", nil, &vips); err != nil {
+		return fail("get vips failed: %v", err)
+	}
+	return o.print(vips, func(w *tabwriter.Writer) {
+		vipsTable(w, vips)
+	})
+}
+
+func vipsTable(w *tabwriter.Writer, vips []*server.VipStatus) {
+	fmt.Fprintln(w, "VIP\tLOCAL\tPRIORITY\tREGISTERED\tMASTER\tDRAINED\tMEMBERS")
+	for _, v := range vips {
+		members := make([]string, 0, len(v.Members))
+		for _, m := range v.Members {
+			member := fmt.Sprintf("%s=%d", m.IP, m.Priority)
+			if m.Override {
+				member += "(override)"
+			}
+			members = append(members, member)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", v.Vip, v.LocalIP, v.Priority,
+			boolText(v.Registered, "yes", "no"), boolText(v.Master, "yes", "no"),
+			boolText(v.Drained, "yes", "no"), strings.Join(members, ","))
+	}
+}
@@ -0,0 +1,115 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// DefaultMaxOutput 默认保留的输出字节数
+const DefaultMaxOutput = 4096
+
+// Cmd 待执行的命令，不经过shell
+type Cmd struct {
+	Path string
+	Args []string
+	// 追加的环境变量，KEY=VALUE
+	Env []string
+	// 是否继承当前进程的环境变量
+	InheritEnv bool
+	Dir        string
+	// stdout、stderr各自最多保留的字节数，超出部分丢弃，为0时使用DefaultMaxOutput
+	MaxOutput int
+}
+
+// Result 命令执行结果
+type Result struct {
+	// 进程退出码，未能启动或被信号终止时为-1
+	ExitCode  int
+	StdOutput []byte
+	StdError  []byte
+	// 输出是否被截断
+	Truncated bool
+	err       error
+}
+
+func (r *Result) HasError() bool {
+	return r.err != nil
+}
+
+// Error 启动失败、超时或被取消时的错误，进程非0退出不视为错误，见ExitCode
+func (r *Result) Error() error {
+	return r.err
+}
+
+// Run 执行命令，命令在独立的进程组中运行，ctx结束后终止整个进程组
+func Run(ctx context.Context, c *Cmd) *Result {
+	max := c.MaxOutput
+	if max <= 0 {
+		max = DefaultMaxOutput
+	}
+	stdout, stderr := &limitedBuffer{max: max}, &limitedBuffer{max: max}
+	cmd := exec.CommandContext(ctx, c.Path, c.Args...)
+	cmd.Dir = c.Dir
+	// Env为nil时exec会继承当前进程的环境变量
+	cmd.Env = append([]string{}, c.Env...)
+	if c.InheritEnv {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	// 默认只终止直接子进程，脚本fork出的进程会残留并占用输出管道
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	res := &Result{
+		ExitCode:  -1,
+		StdOutput: stdout.Bytes(),
+		StdError:  stderr.Bytes(),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		res.err = fmt.Errorf("execute command %s canceled: %w", c.Path, ctx.Err())
+	case errors.As(err, &exitErr) && res.ExitCode >= 0:
+		// 非0退出码由调用方处理
+	case err != nil:
+		res.err = fmt.Errorf("execute command %s: %w", c.Path, err)
+	}
+	return res
+}
+
+// limitedBuffer 只保留前max个字节，丢弃的部分不影响命令执行
+// 不内嵌bytes.Buffer，避免io.Copy通过ReadFrom绕过长度限制
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if left := b.max - b.buf.Len(); left < len(p) {
+		b.truncated = true
+		if left > 0 {
+			b.buf.Write(p[:left])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
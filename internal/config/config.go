@@ -1,17 +1,36 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"os"
+	"reflect"
 	"strconv"
-	"system-usability-detection/pkg/status_check"
+	"sync/atomic"
+	"system-usability-detection/internal/util"
+	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 const (
-	keepAlivedPrefix = "/keepalived/"
+	KeepAlivedPrefix = "/keepalived/"
+	// DrainPrefix 维护模式 /drain/<localip>/<vip>
+	DrainPrefix = "/drain/"
+	// OverridePrefix 临时优先级 /override/<vip>/<ip>，存在时替代/keepalived/下注册的优先级
+	OverridePrefix = "/override/"
+	// NodePrefix 节点注册 /nodes/<name> -> ip
+	NodePrefix = "/nodes/"
+	// ClockPrefix 节点时钟 /clock/<name> -> 墙上时间和etcd revision，用于检测时钟偏差
+	ClockPrefix = "/clock/"
+	// VrrpStatePrefix keepalived通过notify脚本上报的当前状态 /vrrp/state/<vip>/<name>
+	VrrpStatePrefix = "/vrrp/state/"
+	// VrrpHistoryPrefix keepalived状态变化记录 /vrrp/history/<vip>/<unixnano>-<name>，保留history_retention
+	VrrpHistoryPrefix = "/vrrp/history/"
 )
 
 type Config struct {
@@ -19,20 +38,89 @@ type Config struct {
 	EtcdEndpoints []string `mapstructure:"etcd"`
 	Dial          int      `mapstructure:"dial"`
 	TTL           int      `mapstructure:"ttl"`
-	Instances     []struct {
+	// 本机keepalived的相关路径
+	Keepalived KeepalivedConfig `mapstructure:"keepalived"`
+	Instances  []struct {
 		Name string `mapstructure:"name"`
 		Vips []struct {
 			Priority int    `mapstructure:"priority"`
 			Vip      string `mapstructure:"vip"`
 		} `mapstructure:"vips"`
-		Check []string `mapstructure:"check"`
+		Check []CheckSpec `mapstructure:"check"`
 	} `mapstructure:"instances"`
 }
 
+// KeepalivedConfig 读取keepalived自身状态所需的路径
+type KeepalivedConfig struct {
+	Binary string `mapstructure:"binary"`
+	// 发送信号的目标进程，keepalived检测未配置pid_file时同样使用该文件
+	PidFile string `mapstructure:"pid_file"`
+	// keepalived收到DATA、STATS信号后写入的文件
+	DataFile  string `mapstructure:"data_file"`
+	StatsFile string `mapstructure:"stats_file"`
+	// 读取keepalived状态并与/check结果比较的周期，为0时不读取
+	StateInterval time.Duration `mapstructure:"state_interval"`
+	// notify脚本上报的状态变化记录在etcd中的保留时间
+	HistoryRetention time.Duration `mapstructure:"history_retention"`
+}
+
+// CheckSpec 检测模块配置，可简写为类型名称
+//
+//	check:
+//	  - nfs
+//	  - type: http
+//	    name: nas
+//	    timeout: 3s
+//	    url: http://localhost:9999/api/status
+type CheckSpec struct {
+	Type string `mapstructure:"type"`
+	// 模块名称，默认与类型相同，同一节点内不能重复
+	Name string `mapstructure:"name"`
+	// 单次检测超时时间，默认status_check.DefaultCheckTimeout
+	Timeout time.Duration `mapstructure:"timeout"`
+	// 其余字段为各类型自己的配置，由status_check按类型解码
+	Options map[string]any `mapstructure:",remain"`
+}
+
+// checkSpecHook 支持检测模块简写为类型名称
+func checkSpecHook(from reflect.Type, to reflect.Type, data any) (any, error) {
+	if from.Kind() == reflect.String && to == reflect.TypeOf(CheckSpec{}) {
+		return map[string]any{"type": data}, nil
+	}
+	return data, nil
+}
+
 type vrrpInstances struct {
-	Instances          []*vrrpInstance
-	etcdPoints, checks []string
-	dial, ttl          int
+	Instances  []*vrrpInstance
+	etcdPoints []string
+	checks     []CheckSpec
+	dial, ttl  int
+}
+
+// Find 根据vip查找本节点的实例
+func (v *vrrpInstances) Find(vip string) *vrrpInstance {
+	for _, ins := range v.Instances {
+		if ins.virtualIP == vip {
+			return ins
+		}
+	}
+	return nil
+}
+
+func (v *vrrpInstances) EtcdPoints() []string {
+	return v.etcdPoints
+}
+
+func (v *vrrpInstances) Checks() []CheckSpec {
+	return v.checks
+}
+
+func (v *vrrpInstances) Dial() int {
+	return v.dial
+}
+
+func (v *vrrpInstances) TTL() int {
+	return v.ttl
 }
 
 type vrrpInstance struct {
@@ -43,37 +131,174 @@ type vrrpInstance struct {
 	KeepAliveCh <-chan *clientv3.LeaseKeepAliveResponse
 	// 标记leaseId和KeepAliveCh是否残留，register失败时会残留
 	HaveResidualInfo bool
+	// 维护模式，为true时注销key且不再注册
+	drained atomic.Bool
+}
+
+func (v *vrrpInstance) IsDrained() bool {
+	return v.drained.Load()
+}
+
+func (v *vrrpInstance) SetDrained(drained bool) {
+	v.drained.Store(drained)
+}
+
+func (v *vrrpInstance) VirtualIP() string {
+	return v.virtualIP
+}
+
+func (v *vrrpInstance) Priority() int {
+	return v.priority
 }
 
 func (v *vrrpInstance) GenerateKV() (string, string) {
-	return fmt.Sprintf("%s%s/%s", keepAlivedPrefix, v.virtualIP, v.LocalIP), strconv.Itoa(v.priority)
+	return fmt.Sprintf("%s%s/%s", KeepAlivedPrefix, v.virtualIP, v.LocalIP), strconv.Itoa(v.priority)
 }
 
 type GlobalConfig struct {
+	// 本节点在配置文件中的名称
+	NodeName string
+	// vrrp网卡上的ip，本节点没有配置vip时同样有效
+	LocalIP          string
 	VrrpInstances    *vrrpInstances
 	InstancesCount   int
 	VrrpNetInterface string
+	Keepalived       KeepalivedConfig
 }
 
 var GlobalConfigInstance *GlobalConfig
 
-func ParseConfig(path string) {
+// Load 读取并解析配置文件
+func Load(path string) (*Config, error) {
 	config := &Config{}
 	v := viper.New()
 	v.SetConfigFile(path)
+	v.SetDefault("keepalived.binary", "keepalived")
+	v.SetDefault("keepalived.pid_file", "/var/run/keepalived.pid")
+	v.SetDefault("keepalived.data_file", "/tmp/keepalived.data")
+	v.SetDefault("keepalived.stats_file", "/tmp/keepalived.stats")
+	v.SetDefault("keepalived.state_interval", "15s")
+	v.SetDefault("keepalived.history_retention", "168h")
 	if err := v.ReadInConfig(); err != nil {
-		log.Panicf("fatal error config file: %w", err)
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		checkSpecHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+	if err := v.Unmarshal(&config, hook); err != nil {
+		return nil, fmt.Errorf("unmarshal config file: %w", err)
+	}
+	return config, nil
+}
+
+// Validate 校验配置内容，返回所有错误
+func (c *Config) Validate() []error {
+	var errs []error
+	if c.Interface == "" {
+		errs = append(errs, errors.New("interface is required"))
+	}
+	if len(c.EtcdEndpoints) == 0 {
+		errs = append(errs, errors.New("etcd endpoints are required"))
+	}
+	if c.Dial <= 0 {
+		errs = append(errs, errors.New("dial must be greater than 0"))
+	}
+	if c.TTL <= 0 {
+		errs = append(errs, errors.New("ttl must be greater than 0"))
+	}
+	if err := c.Keepalived.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(c.Instances) == 0 {
+		errs = append(errs, errors.New("at least one instance is required"))
 	}
-	if err := v.Unmarshal(&config); err != nil {
-		log.Panicf("fatal error unmarshal config file: %w", err)
+	names := make(map[string]bool)
+	for i, ins := range c.Instances {
+		if ins.Name == "" {
+			errs = append(errs, fmt.Errorf("instances[%d]: name is required", i))
+		} else if names[ins.Name] {
+			errs = append(errs, fmt.Errorf("instances[%d]: duplicate name %s", i, ins.Name))
+		}
+		names[ins.Name] = true
+		vips := make(map[string]bool)
+		for j, vip := range ins.Vips {
+			if net.ParseIP(vip.Vip) == nil {
+				errs = append(errs, fmt.Errorf("instances[%d].vips[%d]: invalid vip %q", i, j, vip.Vip))
+			} else if vips[vip.Vip] {
+				errs = append(errs, fmt.Errorf("instances[%d].vips[%d]: duplicate vip %s", i, j, vip.Vip))
+			}
+			vips[vip.Vip] = true
+			// 与keepalived priority取值范围一致
+			if vip.Priority < 1 || vip.Priority > 255 {
+				errs = append(errs, fmt.Errorf("instances[%d].vips[%d]: priority %d out of range 1-255", i, j, vip.Priority))
+			}
+		}
 	}
+	return errs
 }
 
-func GetCheckMode() []status_check.StatusInterface {
-	var si []status_check.StatusInterface
-	si = append(si, status_check.DefaultCheckModule...)
-	for _, ele := range GlobalConfigInstance.VrrpInstances.checks {
-		si = append(si, status_check.GlobalMapping[ele])
+// Validate 校验keepalived相关配置，启动时同样需要校验
+func (k *KeepalivedConfig) Validate() error {
+	// 状态变化记录绑定租约，etcd租约以秒为单位
+	if k.HistoryRetention < time.Second {
+		return fmt.Errorf("keepalived history_retention must be at least 1s, got %v", k.HistoryRetention)
+	}
+	return nil
+}
+
+// ParseConfig 解析配置文件并初始化GlobalConfigInstance，失败时panic
+func ParseConfig(path string) {
+	if err := Setup(path); err != nil {
+		log.Panicf("fatal error config file: %v", err)
+	}
+}
+
+// Setup 解析配置文件，通过主机名匹配本节点的配置并初始化GlobalConfigInstance
+func Setup(path string) error {
+	config, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if err := config.Keepalived.Validate(); err != nil {
+		return err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("get hostname: %w", err)
+	}
+	localIP, err := util.GetIPByName(config.Interface)
+	if err != nil {
+		return fmt.Errorf("get ip of interface %s: %w", config.Interface, err)
+	}
+	// 通过主机名匹配本节点的配置
+	for _, ins := range config.Instances {
+		if ins.Name != hostname {
+			continue
+		}
+		vi := &vrrpInstances{
+			etcdPoints: config.EtcdEndpoints,
+			checks:     ins.Check,
+			dial:       config.Dial,
+			ttl:        config.TTL,
+		}
+		for _, vip := range ins.Vips {
+			vi.Instances = append(vi.Instances, &vrrpInstance{
+				priority:  vip.Priority,
+				virtualIP: vip.Vip,
+				LocalIP:   localIP,
+			})
+		}
+		GlobalConfigInstance = &GlobalConfig{
+			NodeName:         ins.Name,
+			LocalIP:          localIP,
+			VrrpInstances:    vi,
+			InstancesCount:   len(config.Instances),
+			VrrpNetInterface: config.Interface,
+			Keepalived:       config.Keepalived,
+		}
+		return nil
 	}
-	return si
+	return fmt.Errorf("no instance named %s found in config file %s", hostname, path)
 }
@@ -0,0 +1,126 @@
+package keepalived
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"text/template"
+)
+
+// DefaultTemplate 默认的keepalived.conf模板，每个vip一个vrrp_instance，由/check的结果决定是否进入FAULT
+// 所有实例均为BACKUP，由priority选举，/check返回非200时脚本失败，weight为0时实例进入FAULT
+const DefaultTemplate = `# generated by system-usability-detection gen-keepalived for {{.Node}}, do not edit
+global_defs {
+    router_id {{.Node}}
+    script_user root
+    enable_script_security
+}
+{{range .Instances}}
+vrrp_script {{.Script}} {
+    script "{{$.Curl}} -sf -o /dev/null -m {{$.Check.Timeout}} -H 'Vip: {{.Vip}}' -H 'Local: {{$.Interface}}' {{$.Check.URL}}"
+    interval {{$.Check.Interval}}
+    timeout {{$.Check.ScriptTimeout}}
+    fall {{$.Check.Fall}}
+    rise {{$.Check.Rise}}
+    weight {{$.Check.Weight}}
+}
+
+vrrp_instance {{.Name}} {
+    state BACKUP
+    interface {{$.Interface}}
+    virtual_router_id {{.VRID}}
+    priority {{.Priority}}
+    advert_int {{$.AdvertInt}}
+    virtual_ipaddress {
+        {{.Vip}} dev {{$.Interface}}
+    }
+    track_script {
+        {{.Script}}
+    }
+{{- if $.Notify}}
+    notify "{{$.Notify}}"
+{{- end}}
+}
+{{end -}}
+`
+
+// ConfData 渲染keepalived.conf的模板数据
+type ConfData struct {
+	// 本节点在配置文件中的名称
+	Node      string
+	Interface string
+	// curl路径
+	Curl  string
+	Check CheckScript
+	// notify脚本命令，keepalived会追加INSTANCE <instance> <state> <priority>，为空时不配置
+	Notify    string
+	AdvertInt int
+	Instances []ConfInstance
+}
+
+// CheckScript 调用/check的vrrp_script参数，时间单位均为秒
+type CheckScript struct {
+	URL string
+	// curl的超时时间
+	Timeout       int
+	Interval      int
+	ScriptTimeout int
+	Fall, Rise    int
+	Weight        int
+}
+
+// ConfInstance 单个vip对应的vrrp_instance
+type ConfInstance struct {
+	Name string
+	// vrrp_script名称
+	Script   string
+	Vip      string
+	VRID     int
+	Priority int
+}
+
+// NewConfInstance 实例名称和virtual_router_id由vip决定，保证各节点生成的配置一致
+// virtual_router_id取vip的最后一段，同一网段内需要保证不重复
+func NewConfInstance(vip string, priority int) (ConfInstance, error) {
+	ip := net.ParseIP(vip).To4()
+	if ip == nil {
+		return ConfInstance{}, fmt.Errorf("vip %q is not an ipv4 address", vip)
+	}
+	if ip[3] == 0 {
+		return ConfInstance{}, fmt.Errorf("vip %s: virtual_router_id derived from the last octet must not be 0", vip)
+	}
+	suffix := strings.ReplaceAll(ip.String(), ".", "_")
+	return ConfInstance{
+		Name:     "VI_" + suffix,
+		Script:   "chk_" + suffix,
+		Vip:      ip.String(),
+		VRID:     int(ip[3]),
+		Priority: priority,
+	}, nil
+}
+
+// InstanceVip 由NewConfInstance生成的实例名称解析出vip
+func InstanceVip(name string) (string, bool) {
+	suffix, ok := strings.CutPrefix(name, "VI_")
+	if !ok {
+		return "", false
+	}
+	ip := net.ParseIP(strings.ReplaceAll(suffix, "_", ".")).To4()
+	if ip == nil {
+		return "", false
+	}
+	return ip.String(), true
+}
+
+// Render 使用模板渲染keepalived.conf，tmpl为空时使用DefaultTemplate
+func Render(w io.Writer, tmpl string, data *ConfData) error {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	t, err := template.New("keepalived.conf").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, data)
+}
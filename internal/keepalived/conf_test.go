@@ -0,0 +1,56 @@
+package keepalived
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewConfInstance(t *testing.T) {
+	ci, err := NewConfInstance("10.1.1.133", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ConfInstance{Name: "VI_10_1_1_133", Script: "chk_10_1_1_133", Vip: "10.1.1.133", VRID: 133, Priority: 100}
+	if ci != want {
+		t.Fatalf("got %+v, want %+v", ci, want)
+	}
+	if vip, ok := InstanceVip(ci.Name); !ok || vip != ci.Vip {
+		t.Fatalf("InstanceVip(%s) = %q %v", ci.Name, vip, ok)
+	}
+
+	for _, vip := range []string{"10.1.1.0", "fe80::1", "vip", ""} {
+		if _, err := NewConfInstance(vip, 100); err == nil {
+			t.Errorf("%q: expected an error", vip)
+		}
+	}
+}
+
+func TestInstanceVip(t *testing.T) {
+	cases := []struct {
+		name string
+		vip  string
+		ok   bool
+	}{
+		{"VI_192_168_0_10", "192.168.0.10", true},
+		// keepalived默认配置中的实例名称
+		{"VI_1", "", false},
+		{"vi_10_1_1_1", "", false},
+		{"VI_10_1_1", "", false},
+		{"VI_10_1_1_256", "", false},
+	}
+	for _, c := range cases {
+		if vip, ok := InstanceVip(c.name); vip != c.vip || ok != c.ok {
+			t.Errorf("InstanceVip(%s) = %q %v, want %q %v", c.name, vip, ok, c.vip, c.ok)
+		}
+	}
+}
+
+func TestRenderUnknownField(t *testing.T) {
+	var sb strings.Builder
+	if err := Render(&sb, "{{.Node}} {{.Vrid}}\n", &ConfData{Node: "node1"}); err == nil {
+		t.Fatalf("expected an error for an unknown field, got %q", sb.String())
+	}
+	if err := Render(&sb, "{{.Node}\n", &ConfData{}); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
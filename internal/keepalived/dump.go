@@ -0,0 +1,305 @@
+// Package keepalived 读取keepalived自身的状态，通过信号触发keepalived输出data和stats文件并解析
+package keepalived
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"system-usability-detection/internal/command"
+	"system-usability-detection/internal/procfs"
+)
+
+// vrrp实例状态
+const (
+	StateMaster = "MASTER"
+	StateBackup = "BACKUP"
+	StateFault  = "FAULT"
+	StateInit   = "INIT"
+)
+
+// Instance data文件中的vrrp实例
+type Instance struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	WantState string `json:"want_state,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	VRID      int    `json:"vrid"`
+	Priority  int    `json:"priority"`
+	// vrrp_script等调整后的优先级，旧版本没有该字段时与Priority相同
+	EffectivePriority int       `json:"effective_priority"`
+	VIPs              []string  `json:"vips"`
+	LastTransition    time.Time `json:"last_transition"`
+	Stats             *Stats    `json:"stats,omitempty"`
+}
+
+// HasVIP 实例是否配置了vip
+func (i *Instance) HasVIP(vip string) bool {
+	for _, v := range i.VIPs {
+		if v == vip {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats stats文件中的实例统计
+type Stats struct {
+	AdvertReceived uint64 `json:"advert_received"`
+	AdvertSent     uint64 `json:"advert_sent"`
+	BecameMaster   uint64 `json:"became_master"`
+	ReleasedMaster uint64 `json:"released_master"`
+}
+
+// ParseData 解析keepalived --signum=DATA输出的data文件，兼容1.x和2.x的格式
+func ParseData(r io.Reader) ([]*Instance, error) {
+	var (
+		instances []*Instance
+		cur       *Instance
+		inVIPs    bool
+		vipIndent int
+		// 输出了Effective priority的实例，其值可能被调整为0
+		effective = make(map[*Instance]bool)
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		indent := len(raw) - len(strings.TrimLeft(raw, " \t"))
+		if strings.HasPrefix(line, "------<") {
+			// 进入其他段落(如Virtual Server)，实例结束
+			if !strings.Contains(line, "VRRP Topology") {
+				cur = nil
+			}
+			inVIPs = false
+			continue
+		}
+		if k, v, ok := cutField(line); ok && k == "VRRP Instance" {
+			cur = &Instance{Name: v}
+			instances = append(instances, cur)
+			inVIPs = false
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if inVIPs {
+			if indent > vipIndent && line != "" {
+				// 10.1.1.133 dev eth0 scope global 或 10.1.1.133/32 dev eth0
+				addr, _, _ := strings.Cut(strings.Fields(line)[0], "/")
+				if net.ParseIP(addr) != nil {
+					cur.VIPs = append(cur.VIPs, addr)
+				}
+				continue
+			}
+			inVIPs = false
+		}
+		// 2.x: "Virtual IP (1):"，1.x: "Virtual IP = 1"
+		if strings.HasPrefix(line, "Virtual IP") && !strings.HasPrefix(line, "Virtual IP Excluded") {
+			inVIPs, vipIndent = true, indent
+			continue
+		}
+		k, v, ok := cutField(line)
+		if !ok {
+			continue
+		}
+		switch k {
+		case "State":
+			cur.State = v
+		case "Wantstate":
+			cur.WantState = v
+		case "Interface", "Listening device":
+			cur.Interface = v
+		case "Virtual Router ID":
+			cur.VRID, _ = strconv.Atoi(v)
+		case "Priority":
+			cur.Priority, _ = strconv.Atoi(v)
+		case "Effective priority":
+			cur.EffectivePriority, _ = strconv.Atoi(v)
+			effective[cur] = true
+		case "Last transition":
+			// 1697712000.123456 (Thu Oct 19 ...)
+			ts, _, _ := strings.Cut(v, " ")
+			if f, err := strconv.ParseFloat(ts, 64); err == nil {
+				cur.LastTransition = time.Unix(0, int64(f*float64(time.Second)))
+			}
+		}
+	}
+	for _, ins := range instances {
+		if !effective[ins] {
+			ins.EffectivePriority = ins.Priority
+		}
+	}
+	return instances, scanner.Err()
+}
+
+// ParseStats 解析keepalived --signum=STATS输出的stats文件
+func ParseStats(r io.Reader) (map[string]*Stats, error) {
+	stats := make(map[string]*Stats)
+	var (
+		cur     *Stats
+		section string
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k == "VRRP Instance" {
+			cur = &Stats{}
+			stats[v] = cur
+			section = ""
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		if v == "" {
+			section = k
+			continue
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case section == "Advertisements" && k == "Received":
+			cur.AdvertReceived = n
+		case section == "Advertisements" && k == "Sent":
+			cur.AdvertSent = n
+		case k == "Became master":
+			cur.BecameMaster = n
+		case k == "Released master":
+			cur.ReleasedMaster = n
+		}
+	}
+	return stats, scanner.Err()
+}
+
+// cutField 拆分"key = value"
+func cutField(line string) (string, string, bool) {
+	k, v, ok := strings.Cut(line, "=")
+	return strings.TrimSpace(k), strings.TrimSpace(v), ok
+}
+
+// Dumper 触发keepalived输出data和stats文件
+type Dumper struct {
+	// keepalived路径，用于查询信号值
+	Binary    string
+	PidFile   string
+	DataFile  string
+	StatsFile string
+	// 等待文件更新的超时时间
+	Timeout time.Duration
+
+	// 同一时间只触发一次，避免并发读取到对方触发的半成品文件
+	dumpLock sync.Mutex
+	lock     sync.Mutex
+	signals  map[string]syscall.Signal
+}
+
+// Dump 触发并读取data和stats文件，stats读取失败时只返回实例状态
+func (d *Dumper) Dump(ctx context.Context) ([]*Instance, error) {
+	d.dumpLock.Lock()
+	defer d.dumpLock.Unlock()
+	pid, err := procfs.ReadPidFile(d.PidFile)
+	if err != nil {
+		return nil, fmt.Errorf("read pid file: %w", err)
+	}
+	if err := d.trigger(ctx, pid, "DATA", d.DataFile); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(d.DataFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	instances, err := ParseData(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", d.DataFile, err)
+	}
+	if err := d.trigger(ctx, pid, "STATS", d.StatsFile); err != nil {
+		return instances, nil
+	}
+	sf, err := os.Open(d.StatsFile)
+	if err != nil {
+		return instances, nil
+	}
+	defer sf.Close()
+	if stats, err := ParseStats(sf); err == nil {
+		for _, ins := range instances {
+			ins.Stats = stats[ins.Name]
+		}
+	}
+	return instances, nil
+}
+
+// trigger 向keepalived发送信号，等待文件被重新写入且大小不再变化
+func (d *Dumper) trigger(ctx context.Context, pid int, name, path string) error {
+	sig, err := d.signum(ctx, name)
+	if err != nil {
+		return err
+	}
+	var prev time.Time
+	if fi, err := os.Stat(path); err == nil {
+		prev = fi.ModTime()
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("signal keepalived %d: %w", pid, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	size := int64(-1)
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for %s: %w", path, ctx.Err())
+		case <-ticker.C:
+		}
+		fi, err := os.Stat(path)
+		if err != nil || fi.ModTime().Equal(prev) {
+			continue
+		}
+		if fi.Size() > 0 && fi.Size() == size {
+			return nil
+		}
+		size = fi.Size()
+	}
+}
+
+// signum keepalived的信号值因版本而异，通过--signum查询并缓存
+func (d *Dumper) signum(ctx context.Context, name string) (syscall.Signal, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if sig, ok := d.signals[name]; ok {
+		return sig, nil
+	}
+	res := command.Run(ctx, &command.Cmd{Path: d.Binary, Args: []string{"--signum=" + name}})
+	if res.HasError() {
+		return 0, res.Error()
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(res.StdOutput)))
+	if err != nil || res.ExitCode != 0 || n <= 0 {
+		return 0, errors.New("keepalived does not support --signum=" + name)
+	}
+	if d.signals == nil {
+		d.signals = make(map[string]syscall.Signal)
+	}
+	d.signals[name] = syscall.Signal(n)
+	return syscall.Signal(n), nil
+}
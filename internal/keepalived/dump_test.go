@@ -0,0 +1,103 @@
+package keepalived
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func parseDataFile(t *testing.T, name string) []*Instance {
+	t.Helper()
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	instances, err := ParseData(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instances
+}
+
+func TestParseData2x(t *testing.T) {
+	got := parseDataFile(t, "data-2.x.txt")
+	want := []*Instance{
+		{
+			Name: "VI_10_1_1_133", State: StateMaster, WantState: StateMaster, Interface: "eth0",
+			VRID: 133, Priority: 100, EffectivePriority: 100,
+			VIPs:           []string{"10.1.1.133"},
+			LastTransition: time.Unix(1697712000, 123456000),
+		},
+		{
+			// 优先级被vrrp_script调整为0时不能回落为配置的优先级
+			Name: "VI_10_1_1_134", State: StateFault, WantState: StateBackup, Interface: "eth0",
+			VRID: 134, Priority: 90, EffectivePriority: 0,
+			VIPs:           []string{"10.1.1.134", "10.1.1.136"},
+			LastTransition: time.Unix(1697712050, 500000000),
+		},
+	}
+	compareInstances(t, got, want)
+}
+
+func TestParseData1x(t *testing.T) {
+	got := parseDataFile(t, "data-1.x.txt")
+	want := []*Instance{
+		{
+			// 1.x没有Effective priority，与Priority相同
+			Name: "VI_1", State: StateBackup, Interface: "eth1",
+			VRID: 51, Priority: 90, EffectivePriority: 90,
+			VIPs:           []string{"10.1.1.134", "10.1.1.135"},
+			LastTransition: time.Unix(1697712000, 0),
+		},
+	}
+	compareInstances(t, got, want)
+	if !got[0].HasVIP("10.1.1.135") || got[0].HasVIP("10.1.1.140") {
+		t.Fatalf("unexpected vips %v", got[0].VIPs)
+	}
+}
+
+func compareInstances(t *testing.T, got, want []*Instance) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d instances, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := *got[i], *want[i]
+		if !g.LastTransition.Equal(w.LastTransition) {
+			t.Errorf("%s: last transition %v, want %v", w.Name, g.LastTransition, w.LastTransition)
+		}
+		g.LastTransition, w.LastTransition = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("instance %d\n got: %+v\nwant: %+v", i, g, w)
+		}
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	cases := map[string]map[string]*Stats{
+		"stats-2.x.txt": {
+			"VI_10_1_1_133": {AdvertReceived: 12, AdvertSent: 3456, BecameMaster: 2, ReleasedMaster: 1},
+			"VI_10_1_1_134": {AdvertReceived: 789},
+		},
+		// Priority Zero段中的Received、Sent不能覆盖通告计数
+		"stats-1.x.txt": {
+			"VI_1": {AdvertReceived: 100, AdvertSent: 5, BecameMaster: 1, ReleasedMaster: 1},
+		},
+	}
+	for name, want := range cases {
+		f, err := os.Open("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseStats(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s\n got: %v\nwant: %v", name, got, want)
+		}
+	}
+}
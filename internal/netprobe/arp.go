@@ -0,0 +1,111 @@
+package netprobe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	ethPArp    = 0x0806
+	arpRequest = 1
+	arpReply   = 2
+)
+
+// ARP 在iface上广播ARP请求，收到dst的应答即视为二层可达，需要CAP_NET_RAW
+func ARP(ctx context.Context, iface string, dst net.IP, timeout time.Duration) (time.Duration, error) {
+	dst4 := dst.To4()
+	if dst4 == nil {
+		return 0, fmt.Errorf("arp: %s is not an ipv4 address", dst)
+	}
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return 0, fmt.Errorf("arp: %w", err)
+	}
+	if ifi.Flags&net.FlagLoopback != 0 || len(ifi.HardwareAddr) != 6 {
+		return 0, fmt.Errorf("arp: interface %s does not support arp", iface)
+	}
+	src, err := interfaceIPv4(ifi)
+	if err != nil {
+		return 0, err
+	}
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, int(htons(ethPArp)))
+	if err != nil {
+		if errors.Is(err, syscall.EPERM) {
+			return 0, fmt.Errorf("arp: %w", ErrNotPermitted)
+		}
+		return 0, fmt.Errorf("arp: %w", err)
+	}
+	f := os.NewFile(uintptr(fd), "arp")
+	defer f.Close()
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: htons(ethPArp), Ifindex: ifi.Index}); err != nil {
+		return 0, fmt.Errorf("arp: %w", err)
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	tv := syscall.NsecToTimeval(time.Until(deadline).Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return 0, fmt.Errorf("arp: %w", err)
+	}
+
+	// 以太网ARP请求: htype ptype hlen plen oper sha spa tha tpa
+	req := make([]byte, 28)
+	binary.BigEndian.PutUint16(req[0:], 1)
+	binary.BigEndian.PutUint16(req[2:], 0x0800)
+	req[4], req[5] = 6, 4
+	binary.BigEndian.PutUint16(req[6:], arpRequest)
+	copy(req[8:], ifi.HardwareAddr)
+	copy(req[14:], src)
+	copy(req[24:], dst4)
+	to := &syscall.SockaddrLinklayer{
+		Protocol: htons(ethPArp),
+		Ifindex:  ifi.Index,
+		Halen:    6,
+		Addr:     [8]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	start := time.Now()
+	if err := syscall.Sendto(fd, req, 0, to); err != nil {
+		return 0, fmt.Errorf("arp: %w", err)
+	}
+	buf := make([]byte, 128)
+	for time.Now().Before(deadline) {
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("arp: %w", ctx.Err())
+		}
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			return 0, fmt.Errorf("arp: %w", err)
+		}
+		if n >= 28 && binary.BigEndian.Uint16(buf[6:]) == arpReply && net.IP(buf[14:18]).Equal(dst4) {
+			return time.Since(start), nil
+		}
+	}
+	return 0, fmt.Errorf("arp: no reply from %s", dst)
+}
+
+func interfaceIPv4(ifi *net.Interface) (net.IP, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.To4(), nil
+		}
+	}
+	return nil, fmt.Errorf("arp: no ipv4 address on interface %s", ifi.Name)
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
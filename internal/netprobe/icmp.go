@@ -0,0 +1,152 @@
+// Package netprobe 三层、二层可达性探测，探测报文可绑定到指定网卡
+package netprobe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	icmpEchoRequest = 8
+	icmpEchoReply   = 0
+)
+
+// ErrNotPermitted 没有权限发送该类型的探测报文
+var ErrNotPermitted = errors.New("operation not permitted")
+
+// ICMP 发送一次ICMP echo，只支持ipv4；优先使用无需特权的ping socket
+// (受net.ipv4.ping_group_range限制)，失败时使用raw socket
+func ICMP(ctx context.Context, iface string, dst net.IP, timeout time.Duration) (time.Duration, error) {
+	if dst.To4() == nil {
+		return 0, fmt.Errorf("icmp: %s is not an ipv4 address", dst)
+	}
+	conn, raw, err := icmpConn(iface)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	payload := make([]byte, 16)
+	_, _ = rand.Read(payload)
+	id := binary.BigEndian.Uint16(payload)
+	seq := binary.BigEndian.Uint16(payload[2:])
+	pkt := make([]byte, 8+len(payload))
+	pkt[0] = icmpEchoRequest
+	binary.BigEndian.PutUint16(pkt[4:], id)
+	binary.BigEndian.PutUint16(pkt[6:], seq)
+	copy(pkt[8:], payload)
+	binary.BigEndian.PutUint16(pkt[2:], checksum(pkt))
+
+	var addr net.Addr = &net.UDPAddr{IP: dst}
+	if raw {
+		addr = &net.IPAddr{IP: dst}
+	}
+	start := time.Now()
+	if _, err := conn.WriteTo(pkt, addr); err != nil {
+		return 0, fmt.Errorf("icmp: %w", err)
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) && ctx.Err() == nil {
+				return 0, fmt.Errorf("icmp: no reply from %s", dst)
+			}
+			return 0, fmt.Errorf("icmp: %w", err)
+		}
+		reply := buf[:n]
+		if raw {
+			// raw socket收到的报文包含ip头
+			if n < 20 || int(reply[0]&0x0f)*4 > n {
+				continue
+			}
+			reply = reply[int(reply[0]&0x0f)*4:]
+		}
+		if len(reply) < 8+len(payload) || reply[0] != icmpEchoReply || !sameHost(from, dst) {
+			continue
+		}
+		// ping socket的id由内核改写，只比较序号和内容
+		if binary.BigEndian.Uint16(reply[6:]) != seq || string(reply[8:8+len(payload)]) != string(payload) {
+			continue
+		}
+		if raw && binary.BigEndian.Uint16(reply[4:]) != id {
+			continue
+		}
+		return time.Since(start), nil
+	}
+}
+
+// icmpConn 创建ICMP socket并绑定网卡，raw表示是否为raw socket
+func icmpConn(iface string) (net.PacketConn, bool, error) {
+	raw := false
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.IPPROTO_ICMP)
+	if err != nil {
+		raw = true
+		fd, err = syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.IPPROTO_ICMP)
+	}
+	if err != nil {
+		if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES) {
+			return nil, false, fmt.Errorf("icmp: %w", ErrNotPermitted)
+		}
+		return nil, false, fmt.Errorf("icmp: %w", err)
+	}
+	if err := bindToDevice(fd, iface); err != nil {
+		syscall.Close(fd)
+		return nil, false, err
+	}
+	f := os.NewFile(uintptr(fd), "icmp")
+	defer f.Close()
+	conn, err := net.FilePacketConn(f)
+	if err != nil {
+		return nil, false, fmt.Errorf("icmp: %w", err)
+	}
+	return conn, raw, nil
+}
+
+func bindToDevice(fd int, iface string) error {
+	if iface == "" {
+		return nil
+	}
+	if err := syscall.SetsockoptString(fd, syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface); err != nil {
+		return fmt.Errorf("bind to device %s: %w", iface, err)
+	}
+	return nil
+}
+
+func sameHost(addr net.Addr, ip net.IP) bool {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.Equal(ip)
+	case *net.IPAddr:
+		return a.IP.Equal(ip)
+	}
+	return false
+}
+
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,37 @@
+//go:build netns
+
+package netprobe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"system-usability-detection/internal/nstest"
+)
+
+func TestProbesOverVeth(t *testing.T) {
+	v := nstest.NewVeth(t, 2)
+	iface, peer, absent := v.Name, v.IP(2), v.IP(3)
+	ctx := context.Background()
+
+	if _, err := ICMP(ctx, iface, peer, time.Second); err != nil {
+		t.Errorf("icmp %s: %v", peer, err)
+	}
+	if _, err := ARP(ctx, iface, peer, time.Second); err != nil {
+		t.Errorf("arp %s: %v", peer, err)
+	}
+	if _, err := TCP(ctx, iface, peer, 22, time.Second); err != nil {
+		t.Errorf("tcp %s: %v", peer, err)
+	}
+
+	if _, err := ICMP(ctx, iface, absent, 200*time.Millisecond); err == nil {
+		t.Errorf("icmp %s: expected an error", absent)
+	}
+	if _, err := ARP(ctx, iface, absent, 200*time.Millisecond); err == nil {
+		t.Errorf("arp %s: expected an error", absent)
+	}
+	if _, err := TCP(ctx, iface, absent, 22, 200*time.Millisecond); err == nil {
+		t.Errorf("tcp %s: expected an error", absent)
+	}
+}
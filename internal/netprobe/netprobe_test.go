@@ -0,0 +1,63 @@
+package netprobe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"system-usability-detection/internal/nstest"
+)
+
+func TestTCPLoopback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	if _, err := TCP(context.Background(), "", net.IPv4(127, 0, 0, 1), port, time.Second); err != nil {
+		t.Fatalf("listening port: %v", err)
+	}
+
+	// 连接被拒绝同样说明主机可达
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	if _, err := TCP(context.Background(), "", net.IPv4(127, 0, 0, 1), closedPort, time.Second); err != nil {
+		t.Fatalf("refused port: %v", err)
+	}
+}
+
+func TestTCPBoundToLoopback(t *testing.T) {
+	nstest.RequireRoot(t)
+	if _, err := TCP(context.Background(), "lo", net.IPv4(127, 0, 0, 1), 1, time.Second); err != nil {
+		t.Fatalf("loopback: %v", err)
+	}
+	if _, err := TCP(context.Background(), "nonexistent0", net.IPv4(127, 0, 0, 1), 1, time.Second); err == nil {
+		t.Fatal("expected an error for a missing interface")
+	}
+}
+
+func TestICMPLoopback(t *testing.T) {
+	_, err := ICMP(context.Background(), "", net.IPv4(127, 0, 0, 1), time.Second)
+	if errors.Is(err, ErrNotPermitted) {
+		t.Skip("icmp sockets are not permitted")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ICMP(context.Background(), "", net.ParseIP("::1"), time.Second); err == nil {
+		t.Fatal("expected an error for an ipv6 address")
+	}
+}
+
+func TestARPRejectsLoopback(t *testing.T) {
+	if _, err := ARP(context.Background(), "lo", net.IPv4(127, 0, 0, 1), time.Second); err == nil {
+		t.Fatal("expected an error for the loopback interface")
+	}
+}
@@ -0,0 +1,35 @@
+package netprobe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// TCP 发起tcp连接，对端返回RST(连接被拒绝)也说明主机可达
+func TCP(ctx context.Context, iface string, dst net.IP, port int, timeout time.Duration) (time.Duration, error) {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, _ string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) { err = bindToDevice(int(fd), iface) }); cerr != nil {
+				return cerr
+			}
+			return err
+		},
+	}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(dst.String(), strconv.Itoa(port)))
+	if err == nil {
+		conn.Close()
+		return time.Since(start), nil
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return time.Since(start), nil
+	}
+	return 0, fmt.Errorf("tcp: %w", err)
+}
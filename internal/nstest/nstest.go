@@ -0,0 +1,127 @@
+// Package nstest 测试用的网络命名空间，需要root权限。
+//
+// NewVeth会在本机创建netns、veth和路由，使用它的测试放在带netns构建标签的文件中，
+// 只在显式指定时运行：
+//
+//	sudo go test -tags netns ./...
+package nstest
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// 从RFC 2544保留的198.18.0.0/15中选择与本机路由不冲突的/24
+var benchmarkNet = &net.IPNet{IP: net.IPv4(198, 18, 0, 0).To4(), Mask: net.CIDRMask(15, 32)}
+
+var seq atomic.Int32
+
+var routeTypes = map[string]bool{
+	"unicast": true, "local": true, "broadcast": true, "multicast": true, "anycast": true,
+	"blackhole": true, "unreachable": true, "prohibit": true, "throw": true, "nat": true,
+}
+
+// RequireRoot 非root用户运行时跳过测试
+func RequireRoot(t *testing.T) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+}
+
+// Veth 本端在当前netns、对端在独立netns中的一对veth，本端地址为Prefix.1
+type Veth struct {
+	// 本端网卡
+	Name string
+	// /24网段前缀，如198.18.3
+	Prefix string
+}
+
+// IP 网段中的地址
+func (v *Veth) IP(host int) net.IP {
+	return net.ParseIP(fmt.Sprintf("%s.%d", v.Prefix, host))
+}
+
+// NewVeth 创建一对veth，对端放入独立的netns并配置hosts中的地址，测试结束时删除
+func NewVeth(t *testing.T, hosts ...int) *Veth {
+	t.Helper()
+	RequireRoot(t)
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("ip command not found")
+	}
+	subnet, err := freeSubnet()
+	if err != nil {
+		t.Skip(err)
+	}
+	id := fmt.Sprintf("%d-%d", os.Getpid()%100000, seq.Add(1))
+	ns := "nst" + id
+	v := &Veth{Name: "nsta" + id, Prefix: strings.TrimSuffix(subnet.IP.String(), ".0")}
+	peer := "nstb" + id
+	run := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+			t.Fatalf("ip %s: %v %s", strings.Join(args, " "), err, out)
+		}
+	}
+	t.Cleanup(func() {
+		_ = exec.Command("ip", "link", "del", v.Name).Run()
+		_ = exec.Command("ip", "netns", "del", ns).Run()
+	})
+	run("netns", "add", ns)
+	run("link", "add", v.Name, "type", "veth", "peer", "name", peer)
+	run("link", "set", peer, "netns", ns)
+	run("addr", "add", v.Prefix+".1/24", "dev", v.Name)
+	run("link", "set", v.Name, "up")
+	for _, h := range hosts {
+		run("-n", ns, "addr", "add", fmt.Sprintf("%s.%d/24", v.Prefix, h), "dev", peer)
+	}
+	run("-n", ns, "link", "set", peer, "up")
+	run("-n", ns, "link", "set", "lo", "up")
+	return v
+}
+
+// freeSubnet 返回与本机所有路由表中的路由都不重叠的/24，默认路由除外
+func freeSubnet() (*net.IPNet, error) {
+	out, err := exec.Command("ip", "-4", "route", "show", "table", "all").Output()
+	if err != nil {
+		return nil, fmt.Errorf("list routes: %w", err)
+	}
+	var routes []*net.IPNet
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		// 路由类型在目的地址之前，如local 127.0.0.1
+		if len(fields) > 0 && routeTypes[fields[0]] {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 || fields[0] == "default" {
+			continue
+		}
+		dst := fields[0]
+		if !strings.Contains(dst, "/") {
+			dst += "/32"
+		}
+		if _, n, err := net.ParseCIDR(dst); err == nil {
+			routes = append(routes, n)
+		}
+	}
+	base := benchmarkNet.IP.To4()
+	for i := range 512 {
+		c := &net.IPNet{IP: net.IPv4(base[0], base[1]+byte(i/256), byte(i%256), 0).To4(), Mask: net.CIDRMask(24, 32)}
+		free := true
+		for _, r := range routes {
+			if r.Contains(c.IP) || c.Contains(r.IP) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("no free /24 in %s", benchmarkNet)
+}
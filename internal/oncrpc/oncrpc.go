@@ -0,0 +1,203 @@
+// Package oncrpc ONC RPC(RFC 5531)客户端，只支持AUTH_NONE，用于探测rpcbind、nfsd、mountd等服务
+package oncrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// 常用的程序号
+const (
+	ProgPortmap = 100000
+	ProgNFS     = 100003
+	ProgMount   = 100005
+)
+
+const (
+	// ProcNull 所有程序都支持的空过程
+	ProcNull = 0
+	// pmapProcGetPort portmapper v2 GETPORT
+	pmapProcGetPort = 3
+
+	msgCall       = 0
+	msgReply      = 1
+	rpcVersion    = 2
+	replyAccepted = 0
+	ipProtoTCP    = 6
+	ipProtoUDP    = 17
+
+	// tcp记录标记，最高位表示最后一个分片
+	lastFragment = 0x80000000
+	maxReplySize = 64 << 10
+)
+
+// acceptStat 服务端接受请求后的处理结果
+var acceptStat = map[uint32]string{
+	1: "program unavailable",
+	2: "program version mismatch",
+	3: "procedure unavailable",
+	4: "garbage arguments",
+	5: "system error",
+}
+
+// Error 服务端拒绝或未能处理请求
+type Error struct {
+	Prog, Vers uint32
+	Reason     string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc program %d version %d: %s", e.Prog, e.Vers, e.Reason)
+}
+
+// ErrNotRegistered 程序未在rpcbind注册
+var ErrNotRegistered = errors.New("program not registered")
+
+// Call 调用一次远程过程，network为tcp或udp，args为已经XDR编码的参数，返回XDR编码的结果
+func Call(ctx context.Context, network, addr string, prog, vers, proc uint32, args []byte, timeout time.Duration) ([]byte, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	var xidBuf [4]byte
+	_, _ = rand.Read(xidBuf[:])
+	xid := binary.BigEndian.Uint32(xidBuf[:])
+	msg := make([]byte, 0, 40+len(args))
+	for _, v := range []uint32{xid, msgCall, rpcVersion, prog, vers, proc, 0, 0, 0, 0} {
+		msg = binary.BigEndian.AppendUint32(msg, v)
+	}
+	msg = append(msg, args...)
+
+	stream := network != "udp" && network != "udp4" && network != "udp6"
+	if stream {
+		msg = append(binary.BigEndian.AppendUint32(nil, lastFragment|uint32(len(msg))), msg...)
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	for {
+		var reply []byte
+		if stream {
+			reply, err = readRecord(conn)
+		} else {
+			buf := make([]byte, maxReplySize)
+			var n int
+			n, err = conn.Read(buf)
+			reply = buf[:n]
+		}
+		if err != nil {
+			return nil, err
+		}
+		// udp可能收到之前超时请求的应答
+		if len(reply) >= 4 && binary.BigEndian.Uint32(reply) != xid && !stream {
+			continue
+		}
+		return parseReply(reply, xid, prog, vers)
+	}
+}
+
+// Null 调用NULL过程，返回耗时
+func Null(ctx context.Context, network, addr string, prog, vers uint32, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	if _, err := Call(ctx, network, addr, prog, vers, ProcNull, nil, timeout); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// GetPort 通过portmapper v2查询程序在network(tcp、udp)上的端口，rpcAddr为rpcbind地址
+func GetPort(ctx context.Context, rpcNetwork, rpcAddr string, prog, vers uint32, network string, timeout time.Duration) (int, error) {
+	proto := uint32(ipProtoTCP)
+	if network == "udp" {
+		proto = ipProtoUDP
+	}
+	var args []byte
+	for _, v := range []uint32{prog, vers, proto, 0} {
+		args = binary.BigEndian.AppendUint32(args, v)
+	}
+	res, err := Call(ctx, rpcNetwork, rpcAddr, ProgPortmap, 2, pmapProcGetPort, args, timeout)
+	if err != nil {
+		return 0, err
+	}
+	if len(res) < 4 {
+		return 0, errors.New("short getport reply")
+	}
+	port := binary.BigEndian.Uint32(res)
+	if port == 0 || port > 65535 {
+		return 0, ErrNotRegistered
+	}
+	return int(port), nil
+}
+
+// readRecord 读取tcp上的一条记录，合并所有分片
+func readRecord(r io.Reader) ([]byte, error) {
+	var record []byte
+	for {
+		var hdr [4]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, err
+		}
+		h := binary.BigEndian.Uint32(hdr[:])
+		size := h &^ lastFragment
+		if len(record)+int(size) > maxReplySize {
+			return nil, fmt.Errorf("rpc reply exceeds %d bytes", maxReplySize)
+		}
+		frag := make([]byte, size)
+		if _, err := io.ReadFull(r, frag); err != nil {
+			return nil, err
+		}
+		record = append(record, frag...)
+		if h&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+// parseReply 解析应答头，返回结果部分
+func parseReply(b []byte, xid, prog, vers uint32) ([]byte, error) {
+	u := func(i int) uint32 { return binary.BigEndian.Uint32(b[i*4:]) }
+	if len(b) < 12 {
+		return nil, errors.New("short rpc reply")
+	}
+	if u(0) != xid || u(1) != msgReply {
+		return nil, errors.New("unexpected rpc reply")
+	}
+	if u(2) != replyAccepted {
+		return nil, &Error{Prog: prog, Vers: vers, Reason: "request denied"}
+	}
+	// verifier: flavor, length, body(4字节对齐)
+	if len(b) < 20 {
+		return nil, errors.New("short rpc reply")
+	}
+	off := 20 + int(u(4)+3)&^3
+	if len(b) < off+4 {
+		return nil, errors.New("short rpc reply")
+	}
+	if stat := binary.BigEndian.Uint32(b[off:]); stat != 0 {
+		reason, ok := acceptStat[stat]
+		if !ok {
+			reason = fmt.Sprintf("accept status %d", stat)
+		}
+		if stat == 2 && len(b) >= off+12 {
+			reason = fmt.Sprintf("%s, supported %d-%d", reason, binary.BigEndian.Uint32(b[off+4:]), binary.BigEndian.Uint32(b[off+8:]))
+		}
+		return nil, &Error{Prog: prog, Vers: vers, Reason: reason}
+	}
+	return b[off+4:], nil
+}
@@ -0,0 +1,101 @@
+package oncrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"system-usability-detection/internal/oncrpc/oncrpctest"
+)
+
+// reply 只返回一条应答
+func reply(b func(c oncrpctest.Call) []byte) oncrpctest.Handler {
+	return func(c oncrpctest.Call) [][]byte { return [][]byte{b(c)} }
+}
+
+func TestNullTCP(t *testing.T) {
+	addr := oncrpctest.NewServer(t, 0, reply(func(c oncrpctest.Call) []byte {
+		if c.Prog != ProgNFS || c.Vers != 3 || c.Proc != ProcNull || len(c.Args) != 0 {
+			t.Errorf("unexpected call %+v", c)
+		}
+		return oncrpctest.AcceptedReply(c.Xid, 0)
+	})).Addr()
+	if _, err := Null(context.Background(), "tcp", addr, ProgNFS, 3, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetPortFragmentedReply(t *testing.T) {
+	// 应答拆成多个4字节的分片，客户端需要合并
+	addr := oncrpctest.NewServer(t, 4, reply(func(c oncrpctest.Call) []byte {
+		if c.Prog != ProgPortmap || c.Vers != 2 || c.Proc != pmapProcGetPort || len(c.Args) != 16 {
+			t.Errorf("unexpected call %+v", c)
+		}
+		if proto := binary.BigEndian.Uint32(c.Args[8:]); proto != ipProtoUDP {
+			t.Errorf("proto %d, want udp", proto)
+		}
+		return oncrpctest.AcceptedReply(c.Xid, 0, 20048)
+	})).Addr()
+	port, err := GetPort(context.Background(), "tcp", addr, ProgMount, 3, "udp", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != 20048 {
+		t.Fatalf("port %d, want 20048", port)
+	}
+}
+
+func TestGetPortNotRegistered(t *testing.T) {
+	addr := oncrpctest.NewServer(t, 0, reply(func(c oncrpctest.Call) []byte { return oncrpctest.AcceptedReply(c.Xid, 0, 0) })).Addr()
+	if _, err := GetPort(context.Background(), "tcp", addr, ProgMount, 3, "tcp", time.Second); !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("err=%v, want ErrNotRegistered", err)
+	}
+}
+
+func TestNullUDP(t *testing.T) {
+	// 先返回一个xid不匹配的旧应答，客户端应忽略
+	addr := oncrpctest.NewServer(t, 0, func(c oncrpctest.Call) [][]byte {
+		return [][]byte{oncrpctest.AcceptedReply(c.Xid+1, 5), oncrpctest.AcceptedReply(c.Xid, 0)}
+	}).Addr()
+	if _, err := Null(context.Background(), "udp", addr, ProgNFS, 3, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestXidMismatch(t *testing.T) {
+	addr := oncrpctest.NewServer(t, 0, reply(func(c oncrpctest.Call) []byte { return oncrpctest.AcceptedReply(c.Xid+1, 0) })).Addr()
+	if _, err := Null(context.Background(), "tcp", addr, ProgNFS, 3, time.Second); err == nil || !strings.Contains(err.Error(), "unexpected rpc reply") {
+		t.Fatalf("tcp: err=%v, want unexpected rpc reply", err)
+	}
+
+	// udp只收到不匹配的应答时等待到超时
+	_, err := Null(context.Background(), "udp", addr, ProgNFS, 3, 200*time.Millisecond)
+	var nerr net.Error
+	if !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Fatalf("udp: err=%v, want timeout", err)
+	}
+}
+
+func TestRejectedReply(t *testing.T) {
+	addr := oncrpctest.NewServer(t, 0, reply(func(c oncrpctest.Call) []byte { return oncrpctest.DeniedReply(c.Xid) })).Addr()
+	for _, network := range []string{"tcp", "udp"} {
+		_, err := Null(context.Background(), network, addr, ProgNFS, 3, time.Second)
+		var rerr *Error
+		if !errors.As(err, &rerr) || rerr.Reason != "request denied" || rerr.Prog != ProgNFS || rerr.Vers != 3 {
+			t.Errorf("%s: err=%v, want request denied", network, err)
+		}
+	}
+}
+
+func TestAcceptStatus(t *testing.T) {
+	addr := oncrpctest.NewServer(t, 0, reply(func(c oncrpctest.Call) []byte { return oncrpctest.AcceptedReply(c.Xid, 2, 2, 4) })).Addr()
+	_, err := Null(context.Background(), "tcp", addr, ProgNFS, 5, time.Second)
+	var rerr *Error
+	if !errors.As(err, &rerr) || rerr.Reason != "program version mismatch, supported 2-4" {
+		t.Fatalf("err=%v", err)
+	}
+}
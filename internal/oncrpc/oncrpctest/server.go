@@ -0,0 +1,192 @@
+// Package oncrpctest 测试用的ONC RPC服务端，在同一端口上应答tcp和udp请求
+package oncrpctest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+const (
+	msgCall       = 0
+	msgReply      = 1
+	rpcVersion    = 2
+	replyAccepted = 0
+	replyDenied   = 1
+	rpcMismatch   = 0
+
+	lastFragment = 0x80000000
+)
+
+// Call 服务端收到的请求
+type Call struct {
+	Xid, Prog, Vers, Proc uint32
+	// 跳过AUTH_NONE凭证和verifier后的参数
+	Args []byte
+}
+
+// ParseCall 解析请求头，只支持AUTH_NONE
+func ParseCall(b []byte) (Call, error) {
+	if len(b) < 40 {
+		return Call{}, fmt.Errorf("short call %d bytes", len(b))
+	}
+	u := func(i int) uint32 { return binary.BigEndian.Uint32(b[i*4:]) }
+	if u(1) != msgCall || u(2) != rpcVersion {
+		return Call{}, fmt.Errorf("unexpected call header %x", b[:12])
+	}
+	return Call{Xid: u(0), Prog: u(3), Vers: u(4), Proc: u(5), Args: b[40:]}, nil
+}
+
+// AcceptedReply 构造MSG_ACCEPTED应答，verifier为AUTH_NONE，results追加在accept_stat之后
+func AcceptedReply(xid, stat uint32, results ...uint32) []byte {
+	return words(append([]uint32{xid, msgReply, replyAccepted, 0, 0, stat}, results...))
+}
+
+// DeniedReply 构造MSG_DENIED应答，原因为RPC_MISMATCH，只支持rpc版本2
+func DeniedReply(xid uint32) []byte {
+	return words([]uint32{xid, msgReply, replyDenied, rpcMismatch, rpcVersion, rpcVersion})
+}
+
+func words(ws []uint32) []byte {
+	var b []byte
+	for _, v := range ws {
+		b = binary.BigEndian.AppendUint32(b, v)
+	}
+	return b
+}
+
+// Handler 处理一个请求，返回的每条应答在udp上作为单独的数据报、在tcp上作为单独的记录发送
+type Handler func(Call) [][]byte
+
+// Server 在127.0.0.1的同一端口上监听tcp和udp
+type Server struct {
+	t        testing.TB
+	ln       net.Listener
+	pc       net.PacketConn
+	fragSize int
+	handle   Handler
+}
+
+// NewServer 启动服务端，测试结束时关闭。fragSize为tcp应答每个分片的最大长度，0表示不拆分
+func NewServer(t testing.TB, fragSize int, handle Handler) *Server {
+	t.Helper()
+	s := &Server{t: t, fragSize: fragSize, handle: handle}
+	var err error
+	// tcp端口在udp上可能已被占用，重试几次
+	for range 10 {
+		if s.ln, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		if s.pc, err = net.ListenPacket("udp", s.ln.Addr().String()); err == nil {
+			break
+		}
+		s.ln.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.ln.Close()
+		s.pc.Close()
+	})
+	go s.serveTCP()
+	go s.serveUDP()
+	return s
+}
+
+// Addr tcp和udp共用的地址
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Port 端口号，供按端口配置的检测使用
+func (s *Server) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *Server) serveTCP() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				msg, ok := s.readCall(conn)
+				if !ok {
+					return
+				}
+				if _, err := conn.Write(s.records(s.dispatch(msg))); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// readCall 读取一条请求，客户端的请求只有一个分片；连接关闭时返回false
+func (s *Server) readCall(r io.Reader) ([]byte, bool) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, false
+	}
+	h := binary.BigEndian.Uint32(hdr[:])
+	if h&lastFragment == 0 {
+		s.t.Errorf("call is not a single last fragment: %x", h)
+		return nil, false
+	}
+	msg := make([]byte, h&^lastFragment)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, false
+	}
+	return msg, true
+}
+
+// records 按fragSize把每条应答拆成记录标记分片
+func (s *Server) records(replies [][]byte) []byte {
+	var out []byte
+	for _, reply := range replies {
+		for {
+			n := len(reply)
+			if s.fragSize > 0 {
+				n = min(n, s.fragSize)
+			}
+			h := uint32(n)
+			if n == len(reply) {
+				h |= lastFragment
+			}
+			out = binary.BigEndian.AppendUint32(out, h)
+			out = append(out, reply[:n]...)
+			reply = reply[n:]
+			if len(reply) == 0 {
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (s *Server) serveUDP() {
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := s.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		for _, reply := range s.dispatch(buf[:n]) {
+			_, _ = s.pc.WriteTo(reply, addr)
+		}
+	}
+}
+
+func (s *Server) dispatch(msg []byte) [][]byte {
+	c, err := ParseCall(msg)
+	if err != nil {
+		s.t.Error(err)
+		return nil
+	}
+	return s.handle(c)
+}
@@ -0,0 +1,73 @@
+package procfs
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Bond /proc/net/bonding/<bond>中的bond状态
+type Bond struct {
+	Name string
+	Mode string
+	// active-backup模式下当前使用的slave
+	ActiveSlave string
+	MIIStatus   string
+	Slaves      []BondSlave
+}
+
+// BondSlave bond下的slave网卡
+type BondSlave struct {
+	Name             string
+	MIIStatus        string
+	LinkFailureCount int
+}
+
+// Up slave链路是否正常
+func (s *BondSlave) Up() bool {
+	return s.MIIStatus == "up"
+}
+
+// ReadBond 读取bond状态，网卡不是bond或未加载bonding模块时返回os.ErrNotExist
+func ReadBond(name string) (*Bond, error) {
+	f, err := os.Open(Path("net", "bonding", name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	bond := &Bond{Name: name}
+	var slave *BondSlave
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		// Slave Interface之后的字段属于该slave，之前的属于bond本身
+		if k == "Slave Interface" {
+			bond.Slaves = append(bond.Slaves, BondSlave{Name: v})
+			slave = &bond.Slaves[len(bond.Slaves)-1]
+			continue
+		}
+		if slave == nil {
+			switch k {
+			case "Bonding Mode":
+				bond.Mode = v
+			case "Currently Active Slave":
+				bond.ActiveSlave = v
+			case "MII Status":
+				bond.MIIStatus = v
+			}
+			continue
+		}
+		switch k {
+		case "MII Status":
+			slave.MIIStatus = v
+		case "Link Failure Count":
+			slave.LinkFailureCount, _ = strconv.Atoi(v)
+		}
+	}
+	return bond, scanner.Err()
+}
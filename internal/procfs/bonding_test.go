@@ -0,0 +1,48 @@
+package procfs
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestReadBond(t *testing.T) {
+	withRoot(t, "testdata/proc")
+	cases := []struct {
+		name string
+		want *Bond
+	}{
+		{"bond0", &Bond{
+			Name: "bond0", Mode: "fault-tolerance (active-backup)", ActiveSlave: "eth0", MIIStatus: "up",
+			Slaves: []BondSlave{
+				{Name: "eth0", MIIStatus: "up"},
+				{Name: "eth1", MIIStatus: "down", LinkFailureCount: 3},
+			},
+		}},
+		// 802.3ad模式没有Currently Active Slave
+		{"bond1", &Bond{
+			Name: "bond1", Mode: "IEEE 802.3ad Dynamic link aggregation", MIIStatus: "up",
+			Slaves: []BondSlave{
+				{Name: "ens1f0", MIIStatus: "up", LinkFailureCount: 1},
+				{Name: "ens1f1", MIIStatus: "up"},
+			},
+		}},
+	}
+	for _, c := range cases {
+		got, err := ReadBond(c.name)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+	if !(&BondSlave{MIIStatus: "up"}).Up() || (&BondSlave{MIIStatus: "down"}).Up() {
+		t.Error("unexpected BondSlave.Up")
+	}
+	if _, err := ReadBond("eth0"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("eth0: err=%v, want ErrNotExist", err)
+	}
+}
@@ -0,0 +1,142 @@
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Mount /proc/self/mountinfo中的一行，见proc(5)
+type Mount struct {
+	ID       int
+	ParentID int
+	// 设备号 major:minor
+	Device string
+	// 挂载的文件系统内的根目录
+	Root       string
+	MountPoint string
+	// 挂载点选项，如rw、nosuid
+	Options []string
+	FSType  string
+	Source  string
+	// 超级块选项，文件系统级别的只读也体现在这里
+	SuperOptions []string
+}
+
+// HasOption 挂载点选项或超级块选项中是否包含opt，opt可以是name或name=value
+func (m *Mount) HasOption(opt string) bool {
+	return slices.Contains(m.Options, opt) || slices.Contains(m.SuperOptions, opt)
+}
+
+// ReadOnly 挂载点或超级块是否为只读，如ext4出错后被内核remount为ro
+func (m *Mount) ReadOnly() bool {
+	return m.HasOption("ro")
+}
+
+// Mounts 解析本进程的/proc/self/mountinfo
+func Mounts() ([]*Mount, error) {
+	return ReadMountInfo(Path("self", "mountinfo"))
+}
+
+// ReadMountInfo 解析mountinfo格式的文件
+func ReadMountInfo(path string) ([]*Mount, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var mounts []*Mount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m, err := parseMountInfo(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// parseMountInfo 解析一行mountinfo
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountInfo(line string) (*Mount, error) {
+	fields := strings.Fields(line)
+	// 可选字段从第7个字段开始，数量不定，以"-"分隔；挂载点或root本身可能为"-"，不能从头查找
+	sep := -1
+	if len(fields) > 6 {
+		if i := slices.Index(fields[6:], "-"); i >= 0 {
+			sep = i + 6
+		}
+	}
+	if sep < 0 || len(fields) < sep+3 {
+		return nil, fmt.Errorf("malformed mountinfo line %q", line)
+	}
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed mountinfo line %q", line)
+	}
+	parent, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed mountinfo line %q", line)
+	}
+	m := &Mount{
+		ID:         id,
+		ParentID:   parent,
+		Device:     fields[2],
+		Root:       unescapeMount(fields[3]),
+		MountPoint: unescapeMount(fields[4]),
+		Options:    strings.Split(fields[5], ","),
+		FSType:     fields[sep+1],
+		Source:     unescapeMount(fields[sep+2]),
+	}
+	if len(fields) > sep+3 {
+		m.SuperOptions = strings.Split(fields[sep+3], ",")
+	}
+	return m, nil
+}
+
+// unescapeMount 还原内核转义的空格、制表符、换行和反斜杠(\040 \011 \012 \134)
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// FindMount 查找path所在的挂载点，同一挂载点被多次挂载时以最后一次为准
+func FindMount(mounts []*Mount, path string) *Mount {
+	path = filepath.Clean(path)
+	var found *Mount
+	for _, m := range mounts {
+		if !underMount(path, m.MountPoint) {
+			continue
+		}
+		if found == nil || len(m.MountPoint) >= len(found.MountPoint) {
+			found = m
+		}
+	}
+	return found
+}
+
+func underMount(path, mountPoint string) bool {
+	if mountPoint == "/" || path == mountPoint {
+		return true
+	}
+	return strings.HasPrefix(path, mountPoint+"/")
+}
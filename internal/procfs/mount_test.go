@@ -0,0 +1,94 @@
+package procfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	cases := []struct {
+		name string
+		line string
+		want *Mount
+	}{
+		{
+			"proc(5) example",
+			"36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue",
+			&Mount{ID: 36, ParentID: 35, Device: "98:0", Root: "/mnt1", MountPoint: "/mnt2",
+				Options: []string{"rw", "noatime"}, FSType: "ext3", Source: "/dev/root", SuperOptions: []string{"rw", "errors=continue"}},
+		},
+		{
+			"no optional fields",
+			"23 28 0:22 / /proc rw,relatime - proc proc rw",
+			&Mount{ID: 23, ParentID: 28, Device: "0:22", Root: "/", MountPoint: "/proc",
+				Options: []string{"rw", "relatime"}, FSType: "proc", Source: "proc", SuperOptions: []string{"rw"}},
+		},
+		{
+			"multiple optional fields",
+			"29 1 8:2 / / rw,relatime shared:1 master:2 propagate_from:3 - ext4 /dev/sda2 ro,errors=remount-ro",
+			&Mount{ID: 29, ParentID: 1, Device: "8:2", Root: "/", MountPoint: "/",
+				Options: []string{"rw", "relatime"}, FSType: "ext4", Source: "/dev/sda2", SuperOptions: []string{"ro", "errors=remount-ro"}},
+		},
+		{
+			"escaped space and backslash",
+			`100 29 8:17 /data\134x /mnt/my\040disk rw,nosuid shared:60 - fuseblk /dev/sdb\0401 rw,user_id=0`,
+			&Mount{ID: 100, ParentID: 29, Device: "8:17", Root: `/data\x`, MountPoint: "/mnt/my disk",
+				Options: []string{"rw", "nosuid"}, FSType: "fuseblk", Source: "/dev/sdb 1", SuperOptions: []string{"rw", "user_id=0"}},
+		},
+		{
+			// root为"-"时不能当作分隔符
+			"root is a dash",
+			"101 29 0:51 - /mnt/dash rw,relatime shared:61 - tmpfs tmpfs rw",
+			&Mount{ID: 101, ParentID: 29, Device: "0:51", Root: "-", MountPoint: "/mnt/dash",
+				Options: []string{"rw", "relatime"}, FSType: "tmpfs", Source: "tmpfs", SuperOptions: []string{"rw"}},
+		},
+		{
+			"mount point is a dash",
+			"102 29 0:52 / - rw - tmpfs - rw",
+			&Mount{ID: 102, ParentID: 29, Device: "0:52", Root: "/", MountPoint: "-",
+				Options: []string{"rw"}, FSType: "tmpfs", Source: "-", SuperOptions: []string{"rw"}},
+		},
+		{
+			"no super options",
+			"103 29 0:53 / /mnt rw - tmpfs tmpfs",
+			&Mount{ID: 103, ParentID: 29, Device: "0:53", Root: "/", MountPoint: "/mnt",
+				Options: []string{"rw"}, FSType: "tmpfs", Source: "tmpfs"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseMountInfo(c.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %+v\nwant %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseMountInfoMalformed(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 ext3 /dev/root rw",
+		// 分隔符之后缺少文件系统类型和来源
+		"36 35 98:0 /mnt1 /mnt2 rw -",
+		"x 35 98:0 / /mnt rw - ext4 /dev/sda1 rw",
+	} {
+		if m, err := parseMountInfo(line); err == nil {
+			t.Errorf("%q: expected an error, got %+v", line, m)
+		}
+	}
+}
+
+func TestMountReadOnly(t *testing.T) {
+	m, err := parseMountInfo("29 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 ro,errors=remount-ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 挂载点为rw，超级块被内核改为ro
+	if !m.ReadOnly() {
+		t.Fatal("expected a read-only mount")
+	}
+}
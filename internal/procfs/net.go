@@ -0,0 +1,91 @@
+package procfs
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// socket状态，见include/net/tcp_states.h
+const (
+	TCPListen = 0x0a
+	// udp未连接的socket状态为TCP_CLOSE
+	UDPUnconnected = 0x07
+)
+
+// Socket /proc/net/{tcp,tcp6,udp,udp6}中的一行
+type Socket struct {
+	IP    net.IP
+	Port  int
+	State int
+}
+
+// Sockets 读取协议(tcp、udp)的ipv4和ipv6 socket，未开启ipv6时忽略
+func Sockets(proto string) ([]Socket, error) {
+	var sockets []Socket
+	for _, name := range []string{proto, proto + "6"} {
+		s, err := readSockets(Path("net", name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && name != proto {
+				continue
+			}
+			return nil, err
+		}
+		sockets = append(sockets, s...)
+	}
+	return sockets, nil
+}
+
+func readSockets(path string) ([]Socket, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var sockets []Socket
+	scanner := bufio.NewScanner(f)
+	// 跳过表头
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		ip, port, err := parseAddr(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		state, err := strconv.ParseInt(fields[3], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		sockets = append(sockets, Socket{IP: ip, Port: port, State: int(state)})
+	}
+	return sockets, scanner.Err()
+}
+
+// parseAddr 解析"0100007F:0801"格式的地址，ip按32位字以主机字节序(小端)存储
+func parseAddr(s string) (net.IP, int, error) {
+	host, portHex, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, fmt.Errorf("malformed address %q", s)
+	}
+	raw, err := hex.DecodeString(host)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("malformed address %q", s)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("malformed address %q", s)
+	}
+	return ip, int(port), nil
+}
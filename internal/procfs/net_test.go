@@ -0,0 +1,74 @@
+package procfs
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+// withRoot 测试期间从dir读取proc文件
+func withRoot(t *testing.T, dir string) {
+	t.Helper()
+	old := Root
+	Root = dir
+	t.Cleanup(func() { Root = old })
+}
+
+func TestParseAddr(t *testing.T) {
+	cases := []struct {
+		in   string
+		ip   string
+		port int
+	}{
+		{"0100007F:0801", "127.0.0.1", 2049},
+		{"00000000:0016", "0.0.0.0", 22},
+		{"00000000000000000000000001000000:1F90", "::1", 8080},
+		{"0000000000000000FFFF00000202000A:01BD", "10.0.2.2", 445},
+	}
+	for _, c := range cases {
+		ip, port, err := parseAddr(c.in)
+		if err != nil {
+			t.Errorf("%s: %v", c.in, err)
+			continue
+		}
+		if !ip.Equal(net.ParseIP(c.ip)) || port != c.port {
+			t.Errorf("%s = %s:%d, want %s:%d", c.in, ip, port, c.ip, c.port)
+		}
+	}
+	for _, in := range []string{"0100007F", "0100007:0801", "01000070000:0801", "0100007F:10000", "0100007F:zz"} {
+		if _, _, err := parseAddr(in); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestSockets(t *testing.T) {
+	withRoot(t, "testdata/proc")
+	tcp, err := Sockets("tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Socket{
+		{IP: net.IPv4(127, 0, 0, 1).To4(), Port: 18080, State: TCPListen},
+		{IP: net.IPv4zero.To4(), Port: 2049, State: TCPListen},
+		{IP: net.IPv4(10, 0, 2, 2).To4(), Port: 22, State: 1},
+		{IP: net.IPv6loopback, Port: 8080, State: TCPListen},
+		{IP: net.ParseIP("::ffff:10.0.2.2"), Port: 445, State: TCPListen},
+	}
+	if !reflect.DeepEqual(tcp, want) {
+		t.Fatalf("tcp sockets %v, want %v", tcp, want)
+	}
+
+	// 未开启ipv6时没有udp6
+	udp, err := Sockets("udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(udp) != 1 || udp[0].Port != 5353 || udp[0].State != UDPUnconnected {
+		t.Fatalf("udp sockets %v", udp)
+	}
+
+	if _, err := Sockets("raw"); err == nil {
+		t.Fatal("expected an error for a missing ipv4 table")
+	}
+}
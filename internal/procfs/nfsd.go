@@ -0,0 +1,57 @@
+package procfs
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NFSExport 内核nfsd当前生效的导出项，同一路径导出给多个客户端时有多项
+type NFSExport struct {
+	Path    string
+	Client  string
+	Options []string
+}
+
+// NFSExports 读取/proc/fs/nfsd/exports，nfsd文件系统未挂载时返回os.ErrNotExist
+func NFSExports() ([]NFSExport, error) {
+	return ReadNFSExports(Path("fs", "nfsd", "exports"))
+}
+
+// ReadNFSExports 读取exports格式的文件，/var/lib/nfs/etab格式相同
+func ReadNFSExports(path string) ([]NFSExport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var exports []NFSExport
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		e := NFSExport{Path: unescapeMount(fields[0]), Client: fields[1]}
+		if i := strings.IndexByte(fields[1], '('); i >= 0 {
+			e.Client = fields[1][:i]
+			e.Options = strings.Split(strings.TrimSuffix(fields[1][i+1:], ")"), ",")
+		}
+		exports = append(exports, e)
+	}
+	return exports, scanner.Err()
+}
+
+// NFSThreads 读取/proc/fs/nfsd/threads，nfsd停止时为0
+func NFSThreads() (int, error) {
+	data, err := os.ReadFile(Path("fs", "nfsd", "threads"))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
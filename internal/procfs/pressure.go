@@ -0,0 +1,95 @@
+package procfs
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PSIStats 一类压力的统计，avg为百分比
+type PSIStats struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	// 累计阻塞时间，微秒
+	Total uint64
+}
+
+// PSI /proc/pressure/<resource>，cpu的full在5.13之前的内核中不存在
+type PSI struct {
+	Some PSIStats
+	Full *PSIStats
+}
+
+// Pressure 读取/proc/pressure/<resource>，resource为cpu、memory、io；
+// 内核未开启PSI时文件不存在或读取返回EOPNOTSUPP
+func Pressure(resource string) (*PSI, error) {
+	data, err := os.ReadFile(Path("pressure", resource))
+	if err != nil {
+		return nil, err
+	}
+	psi := &PSI{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		kind, rest, _ := strings.Cut(line, " ")
+		st, err := parsePSIStats(rest)
+		if err != nil {
+			return nil, fmt.Errorf("parse pressure %s: %w", resource, err)
+		}
+		switch kind {
+		case "some":
+			psi.Some = *st
+		case "full":
+			psi.Full = st
+		}
+	}
+	return psi, nil
+}
+
+func parsePSIStats(s string) (*PSIStats, error) {
+	st := &PSIStats{}
+	for _, kv := range strings.Fields(s) {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed field %q", kv)
+		}
+		var err error
+		switch k {
+		case "avg10":
+			st.Avg10, err = strconv.ParseFloat(v, 64)
+		case "avg60":
+			st.Avg60, err = strconv.ParseFloat(v, 64)
+		case "avg300":
+			st.Avg300, err = strconv.ParseFloat(v, 64)
+		case "total":
+			st.Total, err = strconv.ParseUint(v, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed field %q", kv)
+		}
+	}
+	return st, nil
+}
+
+// LoadAvg /proc/loadavg
+type LoadAvg struct {
+	Load1, Load5, Load15 float64
+}
+
+func ReadLoadAvg() (*LoadAvg, error) {
+	data, err := os.ReadFile(Path("loadavg"))
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("malformed loadavg %q", data)
+	}
+	var l LoadAvg
+	for i, p := range []*float64{&l.Load1, &l.Load5, &l.Load15} {
+		if *p, err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return nil, fmt.Errorf("malformed loadavg %q", data)
+		}
+	}
+	return &l, nil
+}
@@ -0,0 +1,50 @@
+package procfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPressure(t *testing.T) {
+	withRoot(t, "testdata/proc")
+	cases := []struct {
+		resource string
+		want     *PSI
+	}{
+		// 5.13之前的内核cpu没有full
+		{"cpu", &PSI{Some: PSIStats{Avg10: 2.47, Avg60: 3.09, Avg300: 2.15, Total: 136224746}}},
+		{"memory", &PSI{Full: &PSIStats{}}},
+		{"io", &PSI{
+			Some: PSIStats{Avg10: 12.5, Avg60: 4.31, Avg300: 1.02, Total: 8150152},
+			Full: &PSIStats{Avg10: 9.75, Avg60: 3.2, Avg300: 0.88, Total: 6221426},
+		}},
+	}
+	for _, c := range cases {
+		got, err := Pressure(c.resource)
+		if err != nil {
+			t.Errorf("%s: %v", c.resource, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.resource, got, c.want)
+		}
+	}
+	// bad中的avg60不是数字
+	if _, err := Pressure("bad"); err == nil {
+		t.Error("bad: expected a parse error")
+	}
+	if _, err := Pressure("missing"); err == nil {
+		t.Error("missing: expected an error")
+	}
+}
+
+func TestReadLoadAvg(t *testing.T) {
+	withRoot(t, "testdata/proc")
+	l, err := ReadLoadAvg()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *l != (LoadAvg{Load1: 0.27, Load5: 0.2, Load15: 0.13}) {
+		t.Fatalf("got %+v", l)
+	}
+}
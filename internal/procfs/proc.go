@@ -0,0 +1,122 @@
+// Package procfs 读取/proc下的进程、网络、挂载等信息，不依赖外部命令
+package procfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Root proc文件系统挂载点
+var Root = "/proc"
+
+// Path 拼接proc下的路径
+func Path(elem ...string) string {
+	return filepath.Join(append([]string{Root}, elem...)...)
+}
+
+// Process /proc/<pid>下的进程信息
+type Process struct {
+	Pid  int
+	PPid int
+	// 进程名，与/proc/<pid>/comm一致，最长15个字符
+	Comm string
+	// 进程状态，R S D Z T等
+	State byte
+	// 启动参数，内核线程为空
+	Cmdline []string
+}
+
+// Zombie 是否为僵尸进程
+func (p *Process) Zombie() bool {
+	return p.State == 'Z'
+}
+
+// ReadProcess 读取单个进程信息，进程不存在时返回os.ErrNotExist
+func ReadProcess(pid int) (*Process, error) {
+	dir := Path(strconv.Itoa(pid))
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	p, err := parseStat(stat)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s/stat: %w", dir, err)
+	}
+	cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	cmdline = bytes.TrimRight(cmdline, "\x00")
+	if len(cmdline) > 0 {
+		p.Cmdline = strings.Split(string(cmdline), "\x00")
+	}
+	return p, nil
+}
+
+// parseStat 解析/proc/<pid>/stat，comm可能包含空格和括号，以最后一个')'为界
+func parseStat(data []byte) (*Process, error) {
+	s := string(data)
+	open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return nil, errors.New("malformed stat")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(s[:open]))
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 2 || len(fields[0]) != 1 {
+		return nil, errors.New("malformed stat")
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	return &Process{Pid: pid, PPid: ppid, Comm: s[open+1 : end], State: fields[0][0]}, nil
+}
+
+// Processes 列出所有进程，扫描过程中退出的进程会被忽略
+func Processes() ([]*Process, error) {
+	entries, err := os.ReadDir(Root)
+	if err != nil {
+		return nil, err
+	}
+	procs := make([]*Process, 0, len(entries))
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		p, err := ReadProcess(pid)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.ESRCH) {
+				continue
+			}
+			return nil, err
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// ReadPidFile 读取pid文件
+func ReadPidFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("parse pid file %s: %w", path, err)
+	}
+	if pid <= 0 {
+		return 0, fmt.Errorf("invalid pid %d in %s", pid, path)
+	}
+	return pid, nil
+}
@@ -0,0 +1,77 @@
+package procfs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rtfGateway 路由标志RTF_GATEWAY，见include/uapi/linux/route.h
+const rtfGateway = 0x2
+
+// DefaultGateway 从/proc/net/route读取ipv4默认网关，iface不为空时只查找该网卡的默认路由，
+// 存在多条默认路由时取metric最小的一条
+func DefaultGateway(iface string) (net.IP, error) {
+	f, err := os.Open(Path("net", "route"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		gw     net.IP
+		metric = -1
+	)
+	scanner := bufio.NewScanner(f)
+	// 跳过表头 Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || (iface != "" && fields[0] != iface) {
+			continue
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		m, err := strconv.Atoi(fields[6])
+		if err != nil {
+			continue
+		}
+		ip, err := parseRouteAddr(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		if metric < 0 || m < metric {
+			gw, metric = ip, m
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if gw == nil {
+		if iface != "" {
+			return nil, fmt.Errorf("no default gateway on interface %s", iface)
+		}
+		return nil, fmt.Errorf("no default gateway")
+	}
+	return gw, nil
+}
+
+// parseRouteAddr 地址为主机字节序(小端)的十六进制
+func parseRouteAddr(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4 {
+		return nil, fmt.Errorf("malformed route address %q", s)
+	}
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+	return ip, nil
+}
@@ -0,0 +1,137 @@
+// Package smb2 SMB2协议探测，只实现NEGOTIATE，不进行认证
+package smb2
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// 请求的协议版本，3.1.1需要协商上下文，不在请求之列
+var dialects = []uint16{0x0202, 0x0210, 0x0300, 0x0302}
+
+const (
+	headerSize       = 64
+	commandNegotiate = 0
+	// 响应头flags中的SMB2_FLAGS_SERVER_TO_REDIR
+	flagResponse = 0x1
+	// 响应最大长度，服务端可能附带安全blob
+	maxResponse = 64 << 10
+)
+
+var protocolID = [4]byte{0xfe, 'S', 'M', 'B'}
+
+// NegotiateResult NEGOTIATE响应
+type NegotiateResult struct {
+	// 协商的版本，如0x0302
+	Dialect uint16
+	// 服务端要求签名
+	SigningRequired bool
+	Latency         time.Duration
+}
+
+// DialectString 版本号的常见写法，如3.0.2
+func (r *NegotiateResult) DialectString() string {
+	return fmt.Sprintf("%d.%d.%d", r.Dialect>>8, r.Dialect>>4&0xf, r.Dialect&0xf)
+}
+
+// StatusError 服务端返回非0的NTSTATUS
+type StatusError uint32
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("smb2 negotiate failed with status 0x%08x", uint32(e))
+}
+
+// Negotiate 连接addr并完成一次SMB2 NEGOTIATE交互
+func Negotiate(ctx context.Context, addr string, timeout time.Duration) (*NegotiateResult, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	start := time.Now()
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.Write(negotiateRequest()); err != nil {
+		return nil, err
+	}
+	// direct tcp传输: 1字节0 + 3字节长度
+	var hdr [4]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint32(hdr[:]) & 0xffffff)
+	if hdr[0] != 0 || size < headerSize+8 || size > maxResponse {
+		return nil, fmt.Errorf("invalid smb2 response length %d", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return nil, err
+	}
+	res, err := parseNegotiateResponse(msg)
+	if err != nil {
+		return nil, err
+	}
+	res.Latency = time.Since(start)
+	return res, nil
+}
+
+func negotiateRequest() []byte {
+	le := binary.LittleEndian
+	msg := make([]byte, headerSize+36+2*len(dialects))
+	copy(msg, protocolID[:])
+	le.PutUint16(msg[4:], headerSize)
+	le.PutUint16(msg[12:], commandNegotiate)
+	// CreditRequest
+	le.PutUint16(msg[14:], 1)
+
+	body := msg[headerSize:]
+	le.PutUint16(body[0:], 36)
+	le.PutUint16(body[2:], uint16(len(dialects)))
+	// SMB2_NEGOTIATE_SIGNING_ENABLED
+	le.PutUint16(body[4:], 1)
+	// ClientGuid
+	_, _ = rand.Read(body[12:28])
+	for i, d := range dialects {
+		le.PutUint16(body[36+2*i:], d)
+	}
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...)
+}
+
+func parseNegotiateResponse(msg []byte) (*NegotiateResult, error) {
+	le := binary.LittleEndian
+	if [4]byte(msg[:4]) != protocolID {
+		if msg[0] == 0xff && string(msg[1:4]) == "SMB" {
+			return nil, errors.New("server only supports smb1")
+		}
+		return nil, errors.New("not an smb2 response")
+	}
+	if le.Uint16(msg[12:]) != commandNegotiate || le.Uint32(msg[16:])&flagResponse == 0 {
+		return nil, errors.New("unexpected smb2 response")
+	}
+	if status := le.Uint32(msg[8:]); status != 0 {
+		return nil, StatusError(status)
+	}
+	// 响应体固定部分为64字节，StructureSize包含变长缓冲区的1字节
+	body := msg[headerSize:]
+	if len(body) < 64 || le.Uint16(body[0:]) != 65 {
+		return nil, errors.New("invalid smb2 negotiate response")
+	}
+	return &NegotiateResult{
+		Dialect: le.Uint16(body[4:]),
+		// SMB2_NEGOTIATE_SIGNING_REQUIRED
+		SigningRequired: le.Uint16(body[2:])&0x2 != 0,
+	}, nil
+}
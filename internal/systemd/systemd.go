@@ -0,0 +1,173 @@
+// Package systemd 查询systemd unit状态，通过systemctl或busctl(D-Bus)命令实现，便于替换为其他实现
+package systemd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"system-usability-detection/internal/command"
+)
+
+// UnitState unit的状态，属性含义见systemd.unit(5)和org.freedesktop.systemd1(5)
+type UnitState struct {
+	Name string
+	// loaded、not-found、masked等
+	LoadState string
+	// active、reloading、inactive、failed、activating、deactivating
+	ActiveState string
+	// 与unit类型相关，如service的running、exited、auto-restart
+	SubState string
+	// service自动重启的次数，其他类型的unit为0
+	NRestarts uint64
+}
+
+// Active unit处于可用状态
+func (s *UnitState) Active() bool {
+	return s.ActiveState == "active" || s.ActiveState == "reloading"
+}
+
+// Backend 查询unit状态
+type Backend interface {
+	// UnitStates 按units的顺序返回状态，不存在的unit的LoadState为not-found
+	UnitStates(ctx context.Context, units []string) ([]UnitState, error)
+}
+
+// Systemctl 通过systemctl show查询
+type Systemctl struct {
+	// systemctl路径，为空时从PATH中查找
+	Path string
+}
+
+var _ Backend = (*Systemctl)(nil)
+
+func (s *Systemctl) UnitStates(ctx context.Context, units []string) ([]UnitState, error) {
+	path := s.Path
+	if path == "" {
+		path = "systemctl"
+	}
+	args := append([]string{"show", "--property=Id,LoadState,ActiveState,SubState,NRestarts", "--"}, units...)
+	out, err := run(ctx, path, args)
+	if err != nil {
+		return nil, err
+	}
+	// 每个unit一段key=value，段之间以空行分隔
+	states := make([]UnitState, 0, len(units))
+	st := UnitState{}
+	flush := func() {
+		if st != (UnitState{}) {
+			states = append(states, st)
+			st = UnitState{}
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		k, v, _ := strings.Cut(line, "=")
+		switch k {
+		case "Id":
+			st.Name = v
+		case "LoadState":
+			st.LoadState = v
+		case "ActiveState":
+			st.ActiveState = v
+		case "SubState":
+			st.SubState = v
+		case "NRestarts":
+			st.NRestarts, _ = strconv.ParseUint(v, 10, 64)
+		}
+	}
+	flush()
+	if len(states) != len(units) {
+		return nil, fmt.Errorf("systemctl show returned %d units, expected %d", len(states), len(units))
+	}
+	// Id可能与请求的名称不同(如省略了.service后缀)，以请求的名称为准
+	for i := range states {
+		states[i].Name = units[i]
+	}
+	return states, nil
+}
+
+// Busctl 通过busctl读取org.freedesktop.systemd1上unit对象的属性
+type Busctl struct {
+	// busctl路径，为空时从PATH中查找
+	Path string
+}
+
+var _ Backend = (*Busctl)(nil)
+
+func (b *Busctl) UnitStates(ctx context.Context, units []string) ([]UnitState, error) {
+	path := b.Path
+	if path == "" {
+		path = "busctl"
+	}
+	states := make([]UnitState, 0, len(units))
+	for _, unit := range units {
+		name := unit
+		if !strings.Contains(name, ".") {
+			name += ".service"
+		}
+		obj := "/org/freedesktop/systemd1/unit/" + escapeObjectPath(name)
+		out, err := run(ctx, path, []string{"get-property", "org.freedesktop.systemd1", obj,
+			"org.freedesktop.systemd1.Unit", "LoadState", "ActiveState", "SubState"})
+		if err != nil {
+			return nil, err
+		}
+		// 每行一个属性，如: s "active"
+		values := make([]string, 0, 3)
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			_, v, _ := strings.Cut(line, " ")
+			values = append(values, strings.Trim(v, `"`))
+		}
+		if len(values) != 3 {
+			return nil, fmt.Errorf("unexpected busctl output for %s: %q", unit, out)
+		}
+		st := UnitState{Name: unit, LoadState: values[0], ActiveState: values[1], SubState: values[2]}
+		if strings.HasSuffix(name, ".service") && st.LoadState == "loaded" {
+			out, err := run(ctx, path, []string{"get-property", "org.freedesktop.systemd1", obj,
+				"org.freedesktop.systemd1.Service", "NRestarts"})
+			if err != nil {
+				return nil, err
+			}
+			_, v, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+			st.NRestarts, _ = strconv.ParseUint(v, 10, 64)
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+// escapeObjectPath 按sd_bus_path_encode转义，字母和数字以外的字节写作_xx
+func escapeObjectPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || (i > 0 && '0' <= c && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "_%02x", c)
+	}
+	return b.String()
+}
+
+func run(ctx context.Context, path string, args []string) ([]byte, error) {
+	res := command.Run(ctx, &command.Cmd{Path: path, Args: args, InheritEnv: true, MaxOutput: 64 << 10})
+	if res.HasError() {
+		return nil, res.Error()
+	}
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("%s exited with %d: %s", path, res.ExitCode, strings.TrimSpace(string(res.StdError)))
+	}
+	if res.Truncated {
+		return nil, fmt.Errorf("%s output too large", path)
+	}
+	return res.StdOutput, nil
+}
@@ -0,0 +1,96 @@
+package systemd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeCommand 生成输出固定内容的脚本，并把参数写入args文件
+func fakeCommand(t *testing.T, output string, exitCode int) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	if err := os.WriteFile(out, []byte(output), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "systemctl")
+	content := "#!/bin/sh\necho \"$@\" > " + args + "\ncat " + out + "\necho failed >&2\nexit " + strconv.Itoa(exitCode) + "\n"
+	if err := os.WriteFile(script, []byte(content), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script, args
+}
+
+const showOutput = `NRestarts=2
+Id=nginx.service
+LoadState=loaded
+ActiveState=active
+SubState=running
+
+NRestarts=0
+Id=missing.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+
+Id=data.mount
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+`
+
+func TestSystemctlShow(t *testing.T) {
+	path, args := fakeCommand(t, showOutput, 0)
+	states, err := (&Systemctl{Path: path}).UnitStates(context.Background(), []string{"nginx", "missing.service", "data.mount"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UnitState{
+		{Name: "nginx", LoadState: "loaded", ActiveState: "active", SubState: "running", NRestarts: 2},
+		{Name: "missing.service", LoadState: "not-found", ActiveState: "inactive", SubState: "dead"},
+		{Name: "data.mount", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+	}
+	if !reflect.DeepEqual(states, want) {
+		t.Fatalf("states\n got: %+v\nwant: %+v", states, want)
+	}
+	b, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(b)); got != "show --property=Id,LoadState,ActiveState,SubState,NRestarts -- nginx missing.service data.mount" {
+		t.Fatalf("unexpected args %q", got)
+	}
+}
+
+func TestSystemctlShowErrors(t *testing.T) {
+	// 返回的unit数量与请求不一致
+	path, _ := fakeCommand(t, showOutput, 0)
+	if _, err := (&Systemctl{Path: path}).UnitStates(context.Background(), []string{"nginx"}); err == nil {
+		t.Fatal("expected an error for mismatched unit count")
+	}
+	path, _ = fakeCommand(t, "", 1)
+	_, err := (&Systemctl{Path: path}).UnitStates(context.Background(), []string{"nginx"})
+	if err == nil || !strings.Contains(err.Error(), "exited with 1: failed") {
+		t.Fatalf("err=%v, want exit code error", err)
+	}
+}
+
+func TestEscapeObjectPath(t *testing.T) {
+	cases := map[string]string{
+		"nginx.service":       "nginx_2eservice",
+		"dbus-broker.service": "dbus_2dbroker_2eservice",
+		"1password.service":   "_31password_2eservice",
+		"getty@tty1.service":  "getty_40tty1_2eservice",
+	}
+	for in, want := range cases {
+		if got := escapeObjectPath(in); got != want {
+			t.Errorf("escapeObjectPath(%q)=%q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package textdiff 按行比较文本并输出unified格式的差异，用于比较生成的配置文件
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// op 编辑操作
+type op struct {
+	kind byte // ' ' 相同，'-' 删除，'+' 新增
+	line string
+}
+
+// Unified 返回a到b的unified diff，内容相同时返回空字符串，context为每处差异前后保留的行数
+// 使用最长公共子序列，时间和空间复杂度为O(n*m)，适用于配置文件大小的文本
+func Unified(fromName, toName, a, b string, context int) string {
+	al, bl := splitLines(a), splitLines(b)
+	ops := diff(al, bl)
+	changed := false
+	for _, o := range ops {
+		if o.kind != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	// aPos、bPos为ops[i]对应的行号(从0开始)
+	aPos, bPos := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, o := range ops {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if o.kind != '+' {
+			aPos[i+1]++
+		}
+		if o.kind != '-' {
+			bPos[i+1]++
+		}
+	}
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// 向后合并间隔不超过2*context的差异
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+				continue
+			}
+			if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(ops))
+		aLen, bLen := aPos[end]-aPos[start], bPos[end]-bPos[start]
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aPos[start], aLen), hunkRange(bPos[start], bLen))
+		for _, o := range ops[start:end] {
+			sb.WriteByte(o.kind)
+			sb.WriteString(o.line)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// hunkRange 空范围时行号为前一行
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diff 由最长公共子序列得到编辑序列，同一位置先输出删除再输出新增
+func diff(a, b []string) []op {
+	n, m := len(a), len(b)
+	// lcs[i][j] 为a[i:]和b[j:]的最长公共子序列长度
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	ops := make([]op, 0, max(n, m))
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,36 @@
+package textdiff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	// 期望结果与GNU diff -U<context>的输出一致
+	cases := []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", 3, ""},
+		{"insert", "a\nb\nc\n", "a\nb\nx\nc\n", 3, "@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n"},
+		{"delete", "1\n2\n3\n4\n5\n", "1\n2\n4\n5\n", 1, "@@ -2,3 +2,2 @@\n 2\n-3\n 4\n"},
+		// 两处差异之间的相同行不超过2*context时合并为一个hunk
+		{"merged hunks", "1\n2\n3\n4\n5\n6\n7\n8\n", "1\n2\nx\n4\n5\ny\n7\n8\n", 1,
+			"@@ -2,6 +2,6 @@\n 2\n-3\n+x\n 4\n 5\n-6\n+y\n 7\n"},
+		{"separate hunks", "1\n2\n3\n4\n5\n6\n7\n8\n", "1\nx\n3\n4\n5\n6\ny\n8\n", 1,
+			"@@ -1,3 +1,3 @@\n 1\n-2\n+x\n 3\n@@ -6,3 +6,3 @@\n 6\n-7\n+y\n 8\n"},
+		{"empty from", "", "a\nb\n", 3, "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"empty to", "a\nb\n", "", 3, "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := Unified("a", "b", c.a, c.b, c.context)
+			want := c.want
+			if want != "" {
+				want = "--- a\n+++ b\n" + want
+			}
+			if got != want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -22,3 +22,25 @@ func IsInterfaceDown(name string) (*net.Interface, error) {
 	}
 	return face, nil
 }
+
+// GetIPByName 获取网卡上的第一个IPv4地址
+func GetIPByName(name string) (string, error) {
+	face, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := face.Addrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip := ipNet.IP.To4(); ip != nil {
+			return ip.String(), nil
+		}
+	}
+	return "", errors.New("no ipv4 address found on interface " + name)
+}
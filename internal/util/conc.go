@@ -1,10 +1,7 @@
 package util
 
 import (
-	"log"
-	"os"
 	"sync"
-	"syscall"
 )
 
 type Channel struct {
@@ -23,22 +20,27 @@ func (c *Channel) IsClosed() bool {
 	return c.Closed
 }
 
+// Close 关闭通道，通知所有监听者，重复关闭无副作用
+func (c *Channel) Close() {
+	c.Lock()
+	defer c.Unlock()
+	if c.Closed {
+		return
+	}
+	close(c.Ch)
+	c.Closed = true
+}
+
+// Chan 获取当前通道，Renew之后会返回新的通道
+func (c *Channel) Chan() <-chan any {
+	c.Lock()
+	defer c.Unlock()
+	return c.Ch
+}
+
 func (c *Channel) Renew() {
 	c.Lock()
 	defer c.Unlock()
 	c.Ch = make(chan any)
 	c.Closed = false
 }
-
-// CheckProcessPid 检查进程存活状态
-func CheckProcessPid(pid int) bool {
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		log.Printf("unable to find process %d", pid)
-		return false
-	}
-	if err := process.Signal(syscall.Signal(0)); err != nil {
-		return false
-	}
-	return true
-}
@@ -1,25 +1,55 @@
 package util
 
 import (
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
 )
 
-var Logger *slog.Logger
+// Logger 全局日志，接口为printf风格，底层输出为slog json格式
+var Logger *logger
+
+var programLevel = new(slog.LevelVar)
+
+// SetLogLevel 设置日志级别，命令行工具只输出告警以上的日志
+func SetLogLevel(level slog.Level) {
+	programLevel.Set(level)
+}
+
+type logger struct {
+	s *slog.Logger
+}
+
+func (l *logger) Debug(format string, args ...any) {
+	l.s.Debug(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Info(format string, args ...any) {
+	l.s.Info(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Warn(format string, args ...any) {
+	l.s.Warn(fmt.Sprintf(format, args...))
+}
+
+func (l *logger) Error(format string, args ...any) {
+	l.s.Error(fmt.Sprintf(format, args...))
+}
 
 func init() {
-	var programLevel = new(slog.LevelVar)
 	h := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: programLevel})
-	Logger = slog.New(h)
-	slog.SetDefault(Logger)
+	s := slog.New(h)
+	slog.SetDefault(s)
 	programLevel.Set(slog.LevelDebug)
+	Logger = &logger{s: s}
 
 	logPath := "/var/log/system-usability-detection/system-usability-detection.log"
 	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
-		log.Fatalf("无法打开日志文件: %v", err)
+		// 日志目录不存在时(如命令行工具、开发环境)继续输出到stderr
+		return
 	}
-	defer file.Close()
+	// 进程生命周期内保持打开
 	log.SetOutput(file)
 }
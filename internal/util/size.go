@@ -0,0 +1,57 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize 字节数，配置中可写为整数或带单位的字符串，如512M、10GiB，单位均按1024进制
+type ByteSize uint64
+
+var byteUnits = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+
+func (b ByteSize) String() string {
+	v, i := float64(b), 0
+	for v >= 1024 && i < len(byteUnits)-1 {
+		v /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", uint64(b))
+	}
+	return fmt.Sprintf("%.1f%s", v, byteUnits[i])
+}
+
+// UnmarshalText 解析带单位的字节数，单位不区分大小写，K、KB、KiB含义相同
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid byte size %q", s)
+	}
+	unit = strings.TrimSuffix(unit, "B")
+	// i只能跟在单位之后，如KiB
+	if u, ok := strings.CutSuffix(unit, "I"); ok {
+		if u == "" {
+			return fmt.Errorf("invalid byte size unit in %q", s)
+		}
+		unit = u
+	}
+	shift := strings.Index("KMGTP", unit) + 1
+	if len(unit) > 1 || (unit != "" && shift == 0) {
+		return fmt.Errorf("invalid byte size unit in %q", s)
+	}
+	if unit == "" {
+		shift = 0
+	}
+	*b = ByteSize(v * float64(uint64(1)<<(10*shift)))
+	return nil
+}
@@ -0,0 +1,46 @@
+package util
+
+import "testing"
+
+func TestByteSizeUnmarshalText(t *testing.T) {
+	cases := []struct {
+		in   string
+		want ByteSize
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"1k", 1 << 10},
+		{"1KB", 1 << 10},
+		{"1KiB", 1 << 10},
+		{"512M", 512 << 20},
+		{"1.5G", 3 << 29},
+		{"10 GiB", 10 << 30},
+		{" 2t ", 2 << 40},
+		{"1PiB", 1 << 50},
+	}
+	for _, c := range cases {
+		var b ByteSize
+		if err := b.UnmarshalText([]byte(c.in)); err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if b != c.want {
+			t.Errorf("%q = %d, want %d", c.in, b, c.want)
+		}
+	}
+	for _, in := range []string{"", "-1", "M", "1X", "1MM", "1EiB", "1i", "1iB", "1.2.3G"} {
+		var b ByteSize
+		if err := b.UnmarshalText([]byte(in)); err == nil {
+			t.Errorf("%q: expected an error, got %d", in, b)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	for b, want := range map[ByteSize]string{0: "0B", 1023: "1023B", 1 << 10: "1.0KiB", 3 << 29: "1.5GiB", 1 << 60: "1024.0PiB"} {
+		if got := b.String(); got != want {
+			t.Errorf("%d: %q, want %q", uint64(b), got, want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.2
+// source: guard.proto
+
+package guardpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Guard_Check_FullMethodName       = "/guard.v1.Guard/Check"
+	Guard_ListVips_FullMethodName    = "/guard.v1.Guard/ListVips"
+	Guard_GetHealth_FullMethodName   = "/guard.v1.Guard/GetHealth"
+	Guard_WatchHealth_FullMethodName = "/guard.v1.Guard/WatchHealth"
+)
+
+// GuardClient is the client API for Guard service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Guard 与http接口一致的脑裂检测服务
+type GuardClient interface {
+	// Check 对应 GET /check，判断本节点是否为vip的最高优先级节点
+	Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error)
+	// ListVips 对应 GET /v1/vips，列出本节点配置的vip及etcd中的注册情况
+	ListVips(ctx context.Context, in *ListVipsRequest, opts ...grpc.CallOption) (*ListVipsResponse, error)
+	// GetHealth 对应 GET /v1/health，返回最近一次检测结果
+	GetHealth(ctx context.Context, in *GetHealthRequest, opts ...grpc.CallOption) (*Health, error)
+	// WatchHealth 订阅每一轮检测结果
+	WatchHealth(ctx context.Context, in *WatchHealthRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Health], error)
+}
+
+type guardClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGuardClient(cc grpc.ClientConnInterface) GuardClient {
+	return &guardClient{cc}
+}
+
+func (c *guardClient) Check(ctx context.Context, in *CheckRequest, opts ...grpc.CallOption) (*CheckResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CheckResponse)
+	err := c.cc.Invoke(ctx, Guard_Check_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *guardClient) ListVips(ctx context.Context, in *ListVipsRequest, opts ...grpc.CallOption) (*ListVipsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListVipsResponse)
+	err := c.cc.Invoke(ctx, Guard_ListVips_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *guardClient) GetHealth(ctx context.Context, in *GetHealthRequest, opts ...grpc.CallOption) (*Health, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Health)
+	err := c.cc.Invoke(ctx, Guard_GetHealth_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *guardClient) WatchHealth(ctx context.Context, in *WatchHealthRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Health], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Guard_ServiceDesc.Streams[0], Guard_WatchHealth_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchHealthRequest, Health]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Guard_WatchHealthClient = grpc.ServerStreamingClient[Health]
+
+// GuardServer is the server API for Guard service.
+// All implementations must embed UnimplementedGuardServer
+// for forward compatibility.
+//
+// Guard 与http接口一致的脑裂检测服务
+type GuardServer interface {
+	// Check 对应 GET /check，判断本节点是否为vip的最高优先级节点
+	Check(context.Context, *CheckRequest) (*CheckResponse, error)
+	// ListVips 对应 GET /v1/vips，列出本节点配置的vip及etcd中的注册情况
+	ListVips(context.Context, *ListVipsRequest) (*ListVipsResponse, error)
+	// GetHealth 对应 GET /v1/health，返回最近一次检测结果
+	GetHealth(context.Context, *GetHealthRequest) (*Health, error)
+	// WatchHealth 订阅每一轮检测结果
+	WatchHealth(*WatchHealthRequest, grpc.ServerStreamingServer[Health]) error
+	mustEmbedUnimplementedGuardServer()
+}
+
+// UnimplementedGuardServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGuardServer struct{}
+
+func (UnimplementedGuardServer) Check(context.Context, *CheckRequest) (*CheckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Check not implemented")
+}
+func (UnimplementedGuardServer) ListVips(context.Context, *ListVipsRequest) (*ListVipsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVips not implemented")
+}
+func (UnimplementedGuardServer) GetHealth(context.Context, *GetHealthRequest) (*Health, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHealth not implemented")
+}
+func (UnimplementedGuardServer) WatchHealth(*WatchHealthRequest, grpc.ServerStreamingServer[Health]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchHealth not implemented")
+}
+func (UnimplementedGuardServer) mustEmbedUnimplementedGuardServer() {}
+func (UnimplementedGuardServer) testEmbeddedByValue()               {}
+
+// UnsafeGuardServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GuardServer will
+// result in compilation errors.
+type UnsafeGuardServer interface {
+	mustEmbedUnimplementedGuardServer()
+}
+
+func RegisterGuardServer(s grpc.ServiceRegistrar, srv GuardServer) {
+	// If the following call pancis, it indicates UnimplementedGuardServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Guard_ServiceDesc, srv)
+}
+
+func _Guard_Check_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GuardServer).Check(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Guard_Check_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GuardServer).Check(ctx, req.(*CheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Guard_ListVips_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVipsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GuardServer).ListVips(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Guard_ListVips_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GuardServer).ListVips(ctx, req.(*ListVipsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Guard_GetHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GuardServer).GetHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Guard_GetHealth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GuardServer).GetHealth(ctx, req.(*GetHealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Guard_WatchHealth_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchHealthRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GuardServer).WatchHealth(m, &grpc.GenericServerStream[WatchHealthRequest, Health]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Guard_WatchHealthServer = grpc.ServerStreamingServer[Health]
+
+// Guard_ServiceDesc is the grpc.ServiceDesc for Guard service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Guard_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "guard.v1.Guard",
+	HandlerType: (*GuardServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Check",
+			Handler:    _Guard_Check_Handler,
+		},
+		{
+			MethodName: "ListVips",
+			Handler:    _Guard_ListVips_Handler,
+		},
+		{
+			MethodName: "GetHealth",
+			Handler:    _Guard_GetHealth_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchHealth",
+			Handler:       _Guard_WatchHealth_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "guard.proto",
+}
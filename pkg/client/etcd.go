@@ -15,6 +15,41 @@ import (
 type EtcdClient struct {
 	cli            *clientv3.Client
 	ttl, leaseTime int
+	// 注册、注销结果回调
+	onKeepalive func(KeepaliveEvent)
+}
+
+const (
+	ActionRegister   = "register"
+	ActionUnregister = "unregister"
+)
+
+// KeepaliveEvent vip key的注册、注销事件
+type KeepaliveEvent struct {
+	Action   string
+	Vip      string
+	IP       string
+	Priority int
+	Err      error
+}
+
+// OnKeepalive 设置注册、注销事件回调，需在StartKeepalive之前调用
+func (e *EtcdClient) OnKeepalive(fn func(KeepaliveEvent)) {
+	e.onKeepalive = fn
+}
+
+func (e *EtcdClient) notify(action string, index int, err error) {
+	if e.onKeepalive == nil {
+		return
+	}
+	ins := config.GlobalConfigInstance.VrrpInstances.Instances[index]
+	e.onKeepalive(KeepaliveEvent{
+		Action:   action,
+		Vip:      ins.VirtualIP(),
+		IP:       ins.LocalIP,
+		Priority: ins.Priority(),
+		Err:      err,
+	})
 }
 
 func NewEtcdClient(endpoints []string, dial, ttl int) (*EtcdClient, error) {
@@ -39,13 +74,22 @@ func NewEtcdClient(endpoints []string, dial, ttl int) (*EtcdClient, error) {
 	}, nil
 }
 
-func (e *EtcdClient) get(ctx context.Context, key string) (*clientv3.GetResponse, error) {
+// Client 获取底层etcd客户端
+func (e *EtcdClient) Client() *clientv3.Client {
+	return e.cli
+}
+
+// Get 按前缀获取
+func (e *EtcdClient) Get(ctx context.Context, key string) (*clientv3.GetResponse, error) {
 	getCtx, cancel := context.WithTimeout(ctx, time.Duration(e.ttl)*time.Second)
 	defer cancel()
 	return e.cli.Get(getCtx, key, clientv3.WithPrefix())
 }
 
-func (e *EtcdClient) register(ctx context.Context, index int) error {
+func (e *EtcdClient) register(ctx context.Context, index int) (err error) {
+	defer func() {
+		e.notify(ActionRegister, index, err)
+	}()
 	leaseResp, err := e.cli.Grant(ctx, int64(e.leaseTime))
 	if err != nil {
 		// TODO 配置logger
@@ -58,13 +102,17 @@ func (e *EtcdClient) register(ctx context.Context, index int) error {
 		return err
 	}
 	key, val := config.GlobalConfigInstance.VrrpInstances.Instances[index].GenerateKV()
-	if _, err := e.cli.Put(ctx, key, val, clientv3.WithLease(config.GlobalConfigInstance.VrrpInstances.Instances[index].LeaseID)); err != nil {
-		return nil
+	if _, err = e.cli.Put(ctx, key, val, clientv3.WithLease(config.GlobalConfigInstance.VrrpInstances.Instances[index].LeaseID)); err != nil {
+		log.Printf("put key: %s failed: %v", key, err)
+		return err
 	}
 	return nil
 }
 
-func (e *EtcdClient) unregister(ctx context.Context, index int) error {
+func (e *EtcdClient) unregister(ctx context.Context, index int) (err error) {
+	defer func() {
+		e.notify(ActionUnregister, index, err)
+	}()
 	delCtx, cancel := context.WithTimeout(ctx, time.Duration(e.ttl)*time.Second)
 	defer cancel()
 	if _, err := e.cli.Revoke(delCtx, config.GlobalConfigInstance.VrrpInstances.Instances[index].LeaseID); err != nil {
@@ -79,66 +127,90 @@ func (e *EtcdClient) unregister(ctx context.Context, index int) error {
 	return nil
 }
 
-func (e *EtcdClient) startKeepalive(ctx context.Context) {
+// StartKeepalive 为本节点所有vip注册key并保持续约，收到util.NotifyDown通知后注销
+func (e *EtcdClient) StartKeepalive(ctx context.Context) {
 	var wg sync.WaitGroup
 	for i, ins := range config.GlobalConfigInstance.VrrpInstances.Instances {
 		k, v := ins.GenerateKV()
-		txn := e.cli.Txn(ctx)
-		// 若key不存在则创建
-		txn.If(clientv3.Compare(clientv3.CreateRevision(k), "=", 0)).Then(clientv3.OpPut(k, v)).Else()
-		// 提交事务
-		if _, err := txn.Commit(); err != nil {
-			log.Printf("transcation commit failed: %v", err)
-			// TODO bug-7488 写KV失败不应退出，应在goroutine中定时尝试写
-			// continue
+		// 维护模式下不写入key，退出维护模式后由keepalive注册
+		if ins.IsDrained() {
+			log.Printf("instance[k:%s, v:%s] is drained, skip register", k, v)
+		} else {
+			txn := e.cli.Txn(ctx)
+			// 若key不存在则创建
+			txn.If(clientv3.Compare(clientv3.CreateRevision(k), "=", 0)).Then(clientv3.OpPut(k, v)).Else()
+			// 提交事务
+			if _, err := txn.Commit(); err != nil {
+				log.Printf("transcation commit failed: %v", err)
+				// TODO bug-7488 写KV失败不应退出，应在goroutine中定时尝试写
+				// continue
+			}
 		}
 		wg.Add(1)
-		go func(index int, k, v string) {
-			defer wg.Done()
-			timer := time.NewTimer(time.Duration(e.ttl) * time.Second)
-			defer timer.Stop()
-			for {
-				select {
-				case <-util.NotifyDown.Ch:
-					// 通知取消key续约，并删除key
-					log.Printf("notify cancel key lease[k:%s, v:%s]", k, v)
+		go e.keepalive(ctx, &wg, i, k, v)
+	}
+	wg.Wait()
+}
+
+// keepalive 保持单个vip key的续约，处理通知注销、保活通道关闭和维护模式
+func (e *EtcdClient) keepalive(ctx context.Context, wg *sync.WaitGroup, index int, k, v string) {
+	defer wg.Done()
+	timer := time.NewTimer(time.Duration(e.ttl) * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-util.NotifyDown.Chan():
+			// 通知取消key续约，并删除key
+			log.Printf("notify cancel key lease[k:%s, v:%s]", k, v)
+			if err := e.unregister(ctx, index); err != nil {
+				log.Printf("unregister[k:%s, v:%s] failed:%v", k, v, err)
+				config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo = true
+			}
+			return
+		case res := <-config.GlobalConfigInstance.VrrpInstances.Instances[index].KeepAliveCh:
+			if res != nil {
+				continue
+			}
+			// res为空代表保活通道关闭，置空通道避免已关闭的通道在select中空转，维护模式下不再注册
+			config.GlobalConfigInstance.VrrpInstances.Instances[index].KeepAliveCh = nil
+			if !config.GlobalConfigInstance.VrrpInstances.Instances[index].IsDrained() {
+				log.Printf("try to register[k:%s, v:%s]", k, v)
+				if err := e.register(ctx, index); err != nil {
+					log.Printf("register[k:%s, v:%s] failed: %v", k, v, err)
+					config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo = true
+				}
+			}
+		case <-timer.C:
+			// 定时检查保活状态，keepaliveCh为nil表示保活通道关闭
+			timer.Reset(time.Duration(e.ttl) * time.Second)
+			// 清理残余租约信息
+			if config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo {
+				if err := e.unregister(ctx, index); err != nil {
+					log.Printf("unregister[k:%s, v:%s] failed: %v", k, v, err)
+				} else {
+					log.Printf("unregister[k:%s, v:%s] successful", k, v)
+					config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo = false
+				}
+			}
+			// 维护模式，注销key且不再注册
+			if config.GlobalConfigInstance.VrrpInstances.Instances[index].IsDrained() {
+				if config.GlobalConfigInstance.VrrpInstances.Instances[index].KeepAliveCh != nil {
+					log.Printf("instance drained, unregister[k:%s, v:%s]", k, v)
 					if err := e.unregister(ctx, index); err != nil {
-						log.Printf("unregister[k:%s, v:%s] failed:%v", k, v, err)
+						log.Printf("unregister[k:%s, v:%s] failed: %v", k, v, err)
 						config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo = true
 					}
-					return
-				case res := <-config.GlobalConfigInstance.VrrpInstances.Instances[index].KeepAliveCh:
-					// 如果res为空，代表保活通道关闭
-					if res == nil {
-						log.Printf("try to register[k:%s, v:%s]", k, v)
-						if err := e.register(ctx, index); err != nil {
-							log.Printf("register[k:%s, v:%s] failed: %v", k, v, err)
-							config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo = true
-						}
-					}
-				case <-timer.C:
-					// 定时检查保活状态，keepaliveCh为nil表示保活通道关闭
-					timer.Reset(time.Duration(e.ttl) * time.Second)
-					// 清理残余租约信息
-					if config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo {
-						if err := e.unregister(ctx, index); err != nil {
-							log.Printf("unregister[k:%s, v:%s] failed: %v", k, v, err)
-						} else {
-							log.Printf("unregister[k:%s, v:%s] successful", k, v)
-							config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo = false
-						}
-					}
-					// 如果保活通道关闭，重新注册
-					if config.GlobalConfigInstance.VrrpInstances.Instances[index].KeepAliveCh == nil && !config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo {
-						log.Printf("try to register[k:%s, v:%s]", k, v)
-						if err := e.register(ctx, index); err != nil {
-							log.Printf("register[k:%s, v:%s] failed: %v", k, v, err)
-							config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo = true
-						}
-					}
 				}
+				continue
 			}
-		}(i, k, v)
+			// 如果保活通道关闭，重新注册
+			if config.GlobalConfigInstance.VrrpInstances.Instances[index].KeepAliveCh == nil && !config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo {
+				log.Printf("try to register[k:%s, v:%s]", k, v)
+				if err := e.register(ctx, index); err != nil {
+					log.Printf("register[k:%s, v:%s] failed: %v", k, v, err)
+					config.GlobalConfigInstance.VrrpInstances.Instances[index].HaveResidualInfo = true
+				}
+			}
+		}
 	}
-	wg.Wait()
 }
@@ -13,84 +13,69 @@ import (
 	"time"
 )
 
-const nameSpace = "system-usability-detection"
+// NameSpace 指标命名空间，检测模块自带的指标也使用该命名空间
+const NameSpace = "system_usability_detection"
 
 var (
 	Gather = prometheus.NewRegistry()
 
-	CacheCheckCounter = prometheus.NewCounterVec(
+	CheckCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
-			Namespace: nameSpace,
-			Subsystem: "power_cache",
-			Name:      "cache_check_updates",
-			Help:      "Counter of power_cache updates.",
-		}, []string{"type"}) // power_cache检测计数counter
+			Namespace: NameSpace,
+			Subsystem: "check",
+			Name:      "runs_total",
+			Help:      "Counter of check runs by result.",
+		}, []string{"name", "type", "ok"}) // 检测模块执行计数counter
 
-	FrontInterfaceCheckCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: nameSpace,
-			Subsystem: "front_interface",
-			Name:      "front_interface_check_updates",
-			Help:      "Counter of front_interface updates.",
-		}, []string{"type"}) // 业务网卡检测计数counter
-
-	NasCheckCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: nameSpace,
-			Subsystem: "nas",
-			Name:      "nas_check_updates",
-			Help:      "Counter of nas updates.",
-		}, []string{"type"}) // nas检测计数counter
-
-	ServiceCheckCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: nameSpace,
-			Subsystem: "Service",
-			Name:      "Service_check_updates",
-			Help:      "Counter of Service updates.",
-		}, []string{"type"}) // Service检测计数counter
-
-	NfsCheckCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: nameSpace,
-			Subsystem: "nfs",
-			Name:      "nfs_check_updates",
-			Help:      "Counter of nfs updates.",
-		}, []string{"type"}) // nfs检测计数counter
-
-	SambaCheckCounter = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: nameSpace,
-			Subsystem: "samba",
-			Name:      "samba_check_updates",
-			Help:      "Counter of samba updates.",
-		}, []string{"type"}) // samba检测计数counter
+	CheckDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: NameSpace,
+			Subsystem: "check",
+			Name:      "duration_seconds",
+			Help:      "Bucketed histogram of check duration.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"name", "type"}) // 检测模块耗时histogram
 
 	ExecuteTimeOutGauge = prometheus.NewGauge(
 		prometheus.GaugeOpts{
-			Namespace: nameSpace,
+			Namespace: NameSpace,
 			Subsystem: "server",
 			Name:      "is_timeout",
 			Help:      "execute allcheck is timeout",
 		}) // 检查模块超时gauge
 	RequestHistogram = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
-			Namespace: nameSpace,
+			Namespace: NameSpace,
 			Subsystem: "request",
 			Name:      "request_seconds",
 			Help:      "Bucketed histogram of client request processing time.",
 			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 24),
 		}, []string{"type", "bucket"}) // 客户端请求时间分布histogram
+
+	KeepalivedStateMismatch = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: NameSpace,
+			Subsystem: "keepalived",
+			Name:      "state_mismatch",
+			Help:      "Whether keepalived's MASTER state disagrees with the etcd decision of /check.",
+		}, []string{"vip"}) // keepalived状态与/check结果不一致gauge
+
+	VrrpMasters = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: NameSpace,
+			Subsystem: "keepalived",
+			Name:      "vrrp_masters",
+			Help:      "Number of alive nodes whose keepalived reported MASTER for the vip via the notify script.",
+		}, []string{"vip"}) // notify上报为MASTER的节点数gauge
 )
 
 func init() {
-	Gather.MustRegister(CacheCheckCounter)
-	Gather.MustRegister(FrontInterfaceCheckCounter)
-	Gather.MustRegister(NasCheckCounter)
-	Gather.MustRegister(ServiceCheckCounter)
-	Gather.MustRegister(NfsCheckCounter)
+	Gather.MustRegister(CheckCounter)
+	Gather.MustRegister(CheckDuration)
 	Gather.MustRegister(ExecuteTimeOutGauge)
 	Gather.MustRegister(RequestHistogram)
+	Gather.MustRegister(KeepalivedStateMismatch)
+	Gather.MustRegister(VrrpMasters)
 
 	Gather.MustRegister(collectors.NewGoCollector())
 	Gather.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
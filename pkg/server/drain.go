@@ -0,0 +1,231 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/util"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DrainState 维护模式记录，保存在etcd /drain/<localip>/<vip>，设置过期时间时绑定同样时长的租约
+type DrainState struct {
+	Node      string     `json:"node"`
+	IP        string     `json:"ip"`
+	Vip       string     `json:"vip"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// DrainRequest 进入、退出维护模式请求，Vips为空表示本节点所有vip
+type DrainRequest struct {
+	Vips     []string `json:"vips"`
+	Duration string   `json:"duration"`
+	Reason   string   `json:"reason"`
+}
+
+func localDrainPrefix() string {
+	return config.DrainPrefix + config.GlobalConfigInstance.LocalIP + "/"
+}
+
+// drainVips 校验请求的vip，为空时返回本节点所有vip
+func drainVips(vips []string) ([]string, error) {
+	vi := config.GlobalConfigInstance.VrrpInstances
+	if len(vips) == 0 {
+		for _, ins := range vi.Instances {
+			vips = append(vips, ins.VirtualIP())
+		}
+		return vips, nil
+	}
+	for _, vip := range vips {
+		if vi.Find(vip) == nil {
+			return nil, fmt.Errorf("%w: vip %s is not configured on this node", errInvalidRequest, vip)
+		}
+	}
+	return vips, nil
+}
+
+// Drain 本节点指定vip进入维护模式
+func (b *BrainServer) Drain(ctx context.Context, req *DrainRequest) ([]*DrainState, error) {
+	vips, err := drainVips(req.Vips)
+	if err != nil {
+		return nil, err
+	}
+	var opts []clientv3.OpOption
+	now := time.Now()
+	state := DrainState{
+		Node:      config.GlobalConfigInstance.NodeName,
+		IP:        config.GlobalConfigInstance.LocalIP,
+		Reason:    req.Reason,
+		CreatedAt: now,
+	}
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidRequest, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("%w: drain duration must be at least 1s", errInvalidRequest)
+		}
+		lease, err := b.cli.Client().Grant(ctx, int64(d.Seconds()))
+		if err != nil {
+			return nil, err
+		}
+		expiresAt := now.Add(d)
+		state.ExpiresAt = &expiresAt
+		opts = append(opts, clientv3.WithLease(lease.ID))
+	}
+	var ops []clientv3.Op
+	for _, vip := range vips {
+		state.Vip = vip
+		data, err := json.Marshal(state)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, clientv3.OpPut(localDrainPrefix()+vip, string(data), opts...))
+	}
+	if _, err := b.cli.Client().Txn(ctx).Then(ops...).Commit(); err != nil {
+		return nil, err
+	}
+	util.Logger.Info("drain vips:%v duration:%s reason:%s", vips, req.Duration, req.Reason)
+	return b.LoadDrain(ctx)
+}
+
+// Undrain 本节点指定vip退出维护模式
+func (b *BrainServer) Undrain(ctx context.Context, req *DrainRequest) ([]*DrainState, error) {
+	vips, err := drainVips(req.Vips)
+	if err != nil {
+		return nil, err
+	}
+	var ops []clientv3.Op
+	for _, vip := range vips {
+		ops = append(ops, clientv3.OpDelete(localDrainPrefix()+vip))
+	}
+	if _, err := b.cli.Client().Txn(ctx).Then(ops...).Commit(); err != nil {
+		return nil, err
+	}
+	util.Logger.Info("undrain vips:%v", vips)
+	return b.LoadDrain(ctx)
+}
+
+// ListDrain 列出集群中所有维护模式记录
+func (b *BrainServer) ListDrain(ctx context.Context) ([]*DrainState, error) {
+	return b.getDrain(ctx, config.DrainPrefix)
+}
+
+func (b *BrainServer) getDrain(ctx context.Context, prefix string) ([]*DrainState, error) {
+	resp, err := b.cli.Get(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*DrainState, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		ds := &DrainState{}
+		if err := json.Unmarshal(kv.Value, ds); err != nil {
+			util.Logger.Warn("invalid drain state %s:%v", kv.Key, err)
+			continue
+		}
+		// 租约到期前的短暂窗口内也视为已过期
+		if ds.ExpiresAt != nil && ds.ExpiresAt.Before(time.Now()) {
+			continue
+		}
+		states = append(states, ds)
+	}
+	return states, nil
+}
+
+// LoadDrain 读取etcd中本节点的维护模式记录，更新各vip实例的维护状态
+func (b *BrainServer) LoadDrain(ctx context.Context) ([]*DrainState, error) {
+	states, err := b.getDrain(ctx, localDrainPrefix())
+	if err != nil {
+		return nil, err
+	}
+	drained := make(map[string]bool, len(states))
+	for _, ds := range states {
+		drained[ds.Vip] = true
+	}
+	for _, ins := range config.GlobalConfigInstance.VrrpInstances.Instances {
+		if ins.IsDrained() != drained[ins.VirtualIP()] {
+			util.Logger.Info("vip %s drained:%v", ins.VirtualIP(), drained[ins.VirtualIP()])
+		}
+		ins.SetDrained(drained[ins.VirtualIP()])
+	}
+	return states, nil
+}
+
+// watchDrain 监听本节点的维护模式记录，租约到期或其他节点修改后及时生效
+func (b *BrainServer) watchDrain(ctx context.Context) {
+	for {
+		if _, err := b.LoadDrain(ctx); err != nil {
+			util.Logger.Warn("apply drain state failed:%v", err)
+		}
+		// 定时兜底，处理记录中过期时间已到但租约尚未删除的情况
+		ticker := time.NewTicker(10 * time.Second)
+		wch := b.cli.Client().Watch(ctx, localDrainPrefix(), clientv3.WithPrefix())
+	loop:
+		for {
+			select {
+			case wr, ok := <-wch:
+				if !ok || wr.Err() != nil {
+					util.Logger.Warn("watch %s closed:%v", localDrainPrefix(), wr.Err())
+					break loop
+				}
+			case <-ticker.C:
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+			if _, err := b.LoadDrain(ctx); err != nil {
+				util.Logger.Warn("apply drain state failed:%v", err)
+			}
+		}
+		ticker.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// DrainHandler 进入维护模式
+// curl -X POST -d '{"vips":["10.1.1.133"],"duration":"2h","reason":"disk replacement"}' http://127.0.0.1:12345/v1/admin/drain
+func (b *BrainServer) DrainHandler(w http.ResponseWriter, r *http.Request) {
+	b.handleDrain(w, r, b.Drain)
+}
+
+// UndrainHandler 退出维护模式
+// curl -X POST -d '{"vips":["10.1.1.133"]}' http://127.0.0.1:12345/v1/admin/undrain
+func (b *BrainServer) UndrainHandler(w http.ResponseWriter, r *http.Request) {
+	b.handleDrain(w, r, b.Undrain)
+}
+
+func (b *BrainServer) handleDrain(w http.ResponseWriter, r *http.Request, fn func(context.Context, *DrainRequest) ([]*DrainState, error)) {
+	req := &DrainRequest{}
+	if err := decodeJSON(r, req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	states, err := fn(r.Context(), req)
+	if err != nil {
+		writeJSON(w, errorCode(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, states)
+}
+
+// ListDrainHandler 列出集群中所有维护模式记录
+func (b *BrainServer) ListDrainHandler(w http.ResponseWriter, r *http.Request) {
+	states, err := b.ListDrain(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, states)
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/client"
+	"system-usability-detection/pkg/status_check"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	EventCheck     = "check"     // 检测模块状态变化
+	EventOwnership = "ownership" // vip归属节点变化
+	EventKeepalive = "keepalive" // 本节点vip key注册、注销
+	EventLagged    = "lagged"    // 订阅者消费过慢，有事件被丢弃
+
+	EventKeepalivedState = "keepalived_state" // keepalived的MASTER状态与/check的结果不一致或恢复一致
+	EventVrrpState       = "vrrp_state"       // 节点通过notify脚本上报keepalived状态变化
+	EventVrrpConflict    = "vrrp_conflict"    // 多个节点同时上报为MASTER或冲突解除
+)
+
+// Event 对外推送的事件
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Vip  string    `json:"vip,omitempty"`
+	Data any       `json:"data"`
+}
+
+// CheckEvent 检测模块状态或级别变化，Previous为空表示首次检测
+type CheckEvent struct {
+	Name          string `json:"name"`
+	Status        bool   `json:"status"`
+	Level         string `json:"level"`
+	Previous      *bool  `json:"previous,omitempty"`
+	PreviousLevel string `json:"previous_level,omitempty"`
+	Extra         string `json:"extra,omitempty"`
+}
+
+// OwnershipEvent vip归属变化，Owner为空表示没有节点注册该vip
+type OwnershipEvent struct {
+	Owner         string `json:"owner"`
+	Priority      int    `json:"priority"`
+	PreviousOwner string `json:"previous_owner"`
+}
+
+// KeepaliveEvent 本节点vip key注册、注销结果
+type KeepaliveEvent struct {
+	Action   string `json:"action"`
+	IP       string `json:"ip"`
+	Priority int    `json:"priority"`
+	Error    string `json:"error,omitempty"`
+}
+
+// LaggedEvent 被丢弃的事件数
+type LaggedEvent struct {
+	Dropped uint64 `json:"dropped"`
+}
+
+// publishCheckEvents 对比上一轮检测结果，推送状态发生变化的检测模块
+func (b *BrainServer) publishCheckEvents(prev, cur []status_check.StatusAction) {
+	last := make(map[string]status_check.StatusAction, len(prev))
+	for _, ele := range prev {
+		last[ele.Name] = ele
+	}
+	for _, ele := range cur {
+		ce := CheckEvent{Name: ele.Name, Status: ele.Status, Level: ele.Severity()}
+		if p, ok := last[ele.Name]; ok {
+			if p.Status == ele.Status && p.Severity() == ce.Level {
+				continue
+			}
+			ce.Previous = &p.Status
+			ce.PreviousLevel = p.Severity()
+		}
+		if ele.Extra != nil {
+			ce.Extra = fmt.Sprint(ele.Extra)
+		}
+		b.pubSubSystem.Publish(&Event{Type: EventCheck, Time: ele.Time, Data: ce})
+	}
+}
+
+// publishKeepaliveEvent 推送etcd客户端的注册、注销结果
+func (b *BrainServer) publishKeepaliveEvent(ke client.KeepaliveEvent) {
+	e := KeepaliveEvent{Action: ke.Action, IP: ke.IP, Priority: ke.Priority}
+	if ke.Err != nil {
+		e.Error = ke.Err.Error()
+	}
+	b.pubSubSystem.Publish(&Event{Type: EventKeepalive, Time: time.Now(), Vip: ke.Vip, Data: e})
+}
+
+// watchOwnership 监听etcd中vip的注册情况及临时优先级，归属节点变化时推送事件
+func (b *BrainServer) watchOwnership(ctx context.Context) {
+	owners := make(map[string]string)
+	refresh := func(vip string) {
+		members, err := b.vipMembers(ctx, vip)
+		if err != nil {
+			util.Logger.Warn("get vip %s owner failed:%v", vip, err)
+			return
+		}
+		owner, priority := vipOwner(members)
+		if prev, ok := owners[vip]; ok && prev == owner {
+			return
+		}
+		b.pubSubSystem.Publish(&Event{
+			Type: EventOwnership,
+			Time: time.Now(),
+			Vip:  vip,
+			Data: OwnershipEvent{Owner: owner, Priority: priority, PreviousOwner: owners[vip]},
+		})
+		owners[vip] = owner
+	}
+	changedVips := func(wr clientv3.WatchResponse, prefix string) map[string]struct{} {
+		changed := make(map[string]struct{})
+		for _, ev := range wr.Events {
+			vip, _, ok := strings.Cut(strings.TrimPrefix(string(ev.Kv.Key), prefix), "/")
+			if ok {
+				changed[vip] = struct{}{}
+			}
+		}
+		return changed
+	}
+	for {
+		for _, ins := range config.GlobalConfigInstance.VrrpInstances.Instances {
+			refresh(ins.VirtualIP())
+		}
+		watchCtx, cancel := context.WithCancel(ctx)
+		kch := b.cli.Client().Watch(watchCtx, config.KeepAlivedPrefix, clientv3.WithPrefix())
+		och := b.cli.Client().Watch(watchCtx, config.OverridePrefix, clientv3.WithPrefix())
+	loop:
+		for {
+			var (
+				wr     clientv3.WatchResponse
+				ok     bool
+				prefix string
+			)
+			select {
+			case wr, ok = <-kch:
+				prefix = config.KeepAlivedPrefix
+			case wr, ok = <-och:
+				prefix = config.OverridePrefix
+			}
+			if !ok || wr.Err() != nil {
+				util.Logger.Warn("watch %s closed:%v", prefix, wr.Err())
+				break loop
+			}
+			for vip := range changedVips(wr, prefix) {
+				refresh(vip)
+			}
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// eventFilter 客户端订阅条件，为空表示不过滤
+type eventFilter struct {
+	types, vips, checks map[string]bool
+}
+
+func splitQuery(v string) map[string]bool {
+	if v == "" {
+		return nil
+	}
+	m := make(map[string]bool)
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			m[s] = true
+		}
+	}
+	return m
+}
+
+func (f *eventFilter) match(entry any) bool {
+	e, ok := entry.(*Event)
+	if !ok {
+		return false
+	}
+	if f.types != nil && !f.types[e.Type] {
+		return false
+	}
+	if f.vips != nil && e.Vip != "" && !f.vips[e.Vip] {
+		return false
+	}
+	if ce, ok := e.Data.(CheckEvent); ok && f.checks != nil && !f.checks[ce.Name] {
+		return false
+	}
+	return true
+}
+
+// EventsHandler 以Server-Sent Events推送事件
+// curl -N 'http://127.0.0.1:12345/v1/events?types=check,ownership&vip=10.1.1.133&check=nas'
+func (b *BrainServer) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// 长连接，取消http server的写超时
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		util.Logger.Warn("events disable write deadline failed:%v", err)
+	}
+	q := r.URL.Query()
+	filter := &eventFilter{
+		types:  splitQuery(q.Get("types")),
+		vips:   splitQuery(q.Get("vip")),
+		checks: splitQuery(q.Get("check")),
+	}
+	ch := make(chan interface{}, 64)
+	sub := b.pubSubSystem.Subscribe(ch, r.Context().Done(), filter.match)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		util.Logger.Warn("events flush failed:%v", err)
+		return
+	}
+
+	var id uint64
+	send := func(e *Event) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		id++
+		if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, e.Type, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	sendLagged := func() error {
+		if n := sub.TakeDropped(); n > 0 {
+			return send(&Event{Type: EventLagged, Time: time.Now(), Data: LaggedEvent{Dropped: n}})
+		}
+		return nil
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case entry := <-ch:
+			if err := sendLagged(); err != nil {
+				return
+			}
+			if err := send(entry.(*Event)); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := sendLagged(); err != nil {
+				return
+			}
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
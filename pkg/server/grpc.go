@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/api/guardpb"
+	"system-usability-detection/pkg/status_check"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// guardService grpc接口，与http接口返回一致
+type guardService struct {
+	guardpb.UnimplementedGuardServer
+	b *BrainServer
+	// 服务退出时结束所有WatchHealth流，避免GracefulStop一直等待
+	done <-chan struct{}
+}
+
+// NewGRPCServer 创建grpc服务，注册Guard、健康检查和反射服务，健康状态跟随每一轮检测结果
+func (b *BrainServer) NewGRPCServer(ctx context.Context) *grpc.Server {
+	s := grpc.NewServer()
+	guardpb.RegisterGuardServer(s, &guardService{b: b, done: ctx.Done()})
+
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	hs.SetServingStatus(guardpb.Guard_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(s, hs)
+	reflection.Register(s)
+
+	go b.syncHealthServer(ctx, hs)
+	return s
+}
+
+// syncHealthServer 订阅检测结果，更新grpc健康状态
+func (b *BrainServer) syncHealthServer(ctx context.Context, hs *health.Server) {
+	ch := make(chan interface{}, 1)
+	b.pubSubSystem.Subscribe(ch, ctx.Done(), isStatusAction)
+	for {
+		select {
+		case entry := <-ch:
+			st := healthpb.HealthCheckResponse_NOT_SERVING
+			if NewHealthReport(entry.([]status_check.StatusAction)).Healthy {
+				st = healthpb.HealthCheckResponse_SERVING
+			}
+			hs.SetServingStatus("", st)
+			hs.SetServingStatus(guardpb.Guard_ServiceDesc.ServiceName, st)
+		case <-ctx.Done():
+			hs.Shutdown()
+			return
+		}
+	}
+}
+
+func (g *guardService) Check(ctx context.Context, req *guardpb.CheckRequest) (*guardpb.CheckResponse, error) {
+	res := g.b.checkVip(ctx, req.GetVip(), req.GetInterface())
+	switch res.Code {
+	case http.StatusBadRequest:
+		return nil, status.Error(codes.InvalidArgument, res.Err.Error())
+	case http.StatusInternalServerError:
+		return nil, status.Error(codes.Unavailable, res.Err.Error())
+	}
+	return &guardpb.CheckResponse{
+		Master:      res.Master(),
+		HttpCode:    int32(res.Code),
+		LocalIp:     res.LocalIP,
+		Priority:    int32(res.Priority),
+		MaxPriority: int32(res.MaxPriority),
+	}, nil
+}
+
+func (g *guardService) ListVips(ctx context.Context, _ *guardpb.ListVipsRequest) (*guardpb.ListVipsResponse, error) {
+	vips, err := g.b.ListVips(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	resp := &guardpb.ListVipsResponse{}
+	for _, v := range vips {
+		pv := &guardpb.Vip{
+			Vip:        v.Vip,
+			LocalIp:    v.LocalIP,
+			Priority:   int32(v.Priority),
+			Registered: v.Registered,
+			Master:     v.Master,
+			Drained:    v.Drained,
+		}
+		for _, m := range v.Members {
+			pv.Members = append(pv.Members, &guardpb.VipMember{Ip: m.IP, Priority: int32(m.Priority), Override: m.Override})
+		}
+		resp.Vips = append(resp.Vips, pv)
+	}
+	return resp, nil
+}
+
+func (g *guardService) GetHealth(context.Context, *guardpb.GetHealthRequest) (*guardpb.Health, error) {
+	return healthToProto(g.b.Health()), nil
+}
+
+func (g *guardService) WatchHealth(_ *guardpb.WatchHealthRequest, stream grpc.ServerStreamingServer[guardpb.Health]) error {
+	ctx := stream.Context()
+	ch := make(chan interface{}, 16)
+	g.b.pubSubSystem.Subscribe(ch, ctx.Done(), isStatusAction)
+	// 先推送当前状态
+	if err := stream.Send(healthToProto(g.b.Health())); err != nil {
+		return err
+	}
+	for {
+		select {
+		case entry := <-ch:
+			if err := stream.Send(healthToProto(NewHealthReport(entry.([]status_check.StatusAction)))); err != nil {
+				util.Logger.Warn("WatchHealth send failed:%v", err)
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		case <-g.done:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		}
+	}
+}
+
+func isStatusAction(entry interface{}) bool {
+	_, ok := entry.([]status_check.StatusAction)
+	return ok
+}
+
+func healthToProto(report *HealthReport) *guardpb.Health {
+	h := &guardpb.Health{
+		Healthy: report.Healthy,
+	}
+	if !report.Time.IsZero() {
+		h.Time = timestamppb.New(report.Time)
+	}
+	for _, c := range report.Checks {
+		h.Checks = append(h.Checks, &guardpb.CheckStatus{
+			Name:     c.Name,
+			Status:   c.Status,
+			Extra:    c.Extra,
+			Time:     timestamppb.New(c.Time),
+			TimedOut: c.TimedOut,
+			Level:    c.Level,
+		})
+	}
+	return h
+}
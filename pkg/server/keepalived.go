@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/keepalived"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+)
+
+// keepalivedMismatchRounds 连续多少轮不一致才告警，keepalived切换需要几个通告周期
+const keepalivedMismatchRounds = 2
+
+// keepalivedCheckTimeout 比较时单个vip查询etcd的超时时间
+const keepalivedCheckTimeout = 2 * time.Second
+
+// KeepalivedStatus keepalived自身的状态及与/check结果的比较
+type KeepalivedStatus struct {
+	Time       time.Time              `json:"time"`
+	Instances  []*keepalived.Instance `json:"instances"`
+	Error      string                 `json:"error,omitempty"`
+	Mismatches []KeepalivedStateEvent `json:"mismatches"`
+}
+
+// KeepalivedStateEvent 单个vip在keepalived和etcd中的状态，Mismatch为false表示恢复一致
+type KeepalivedStateEvent struct {
+	Vip string `json:"vip"`
+	// 配置了该vip的keepalived实例，为空表示keepalived中没有该vip
+	Instance string `json:"instance,omitempty"`
+	State    string `json:"state,omitempty"`
+	// 按etcd中的优先级本节点是否为master
+	EtcdMaster bool      `json:"etcd_master"`
+	Mismatch   bool      `json:"mismatch"`
+	Since      time.Time `json:"since"`
+}
+
+// keepalivedWatcher 记录最近一次读取结果和各vip连续不一致的轮数
+type keepalivedWatcher struct {
+	dumper *keepalived.Dumper
+
+	lock   sync.RWMutex
+	status KeepalivedStatus
+	rounds map[string]int
+	since  map[string]time.Time
+}
+
+func newKeepalivedWatcher(kc config.KeepalivedConfig) keepalivedWatcher {
+	return keepalivedWatcher{dumper: &keepalived.Dumper{
+		Binary:    kc.Binary,
+		PidFile:   kc.PidFile,
+		DataFile:  kc.DataFile,
+		StatsFile: kc.StatsFile,
+		Timeout:   2 * time.Second,
+	}}
+}
+
+// instances 最近一次读取到的keepalived实例
+func (w *keepalivedWatcher) instances() []*keepalived.Instance {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.status.Instances
+}
+
+// watchKeepalived 定时读取keepalived的实例状态，与etcd中的决策比较，不一致时告警
+func (b *BrainServer) watchKeepalived(ctx context.Context) {
+	kc := config.GlobalConfigInstance.Keepalived
+	if kc.StateInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(kc.StateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		instances, err := b.keepalived.dumper.Dump(ctx)
+		if err != nil {
+			util.Logger.Warn("read keepalived state failed:%v", err)
+			b.keepalived.lock.Lock()
+			b.keepalived.status.Time = time.Now()
+			b.keepalived.status.Error = err.Error()
+			b.keepalived.lock.Unlock()
+			continue
+		}
+		b.compareKeepalived(ctx, instances)
+	}
+}
+
+// compareKeepalived 比较keepalived的MASTER集合与/check的结果，etcd查询失败的vip不参与比较；
+// etcd查询不持有锁，避免etcd缓慢时阻塞KeepalivedHandler
+func (b *BrainServer) compareKeepalived(ctx context.Context, instances []*keepalived.Instance) {
+	now := time.Now()
+	var events []KeepalivedStateEvent
+	for _, ins := range config.GlobalConfigInstance.VrrpInstances.Instances {
+		vip := ins.VirtualIP()
+		checkCtx, cancel := context.WithTimeout(ctx, keepalivedCheckTimeout)
+		res := b.checkVip(checkCtx, vip, config.GlobalConfigInstance.VrrpNetInterface)
+		cancel()
+		if res.Err != nil {
+			continue
+		}
+		e := KeepalivedStateEvent{Vip: vip, EtcdMaster: res.Master()}
+		for _, ki := range instances {
+			if ki.HasVIP(vip) {
+				e.Instance, e.State = ki.Name, ki.State
+				break
+			}
+		}
+		e.Mismatch = (e.State == keepalived.StateMaster) != e.EtcdMaster
+		events = append(events, e)
+	}
+
+	w := &b.keepalived
+	status := KeepalivedStatus{Time: now, Instances: instances}
+	var publish []KeepalivedStateEvent
+	w.lock.Lock()
+	if w.rounds == nil {
+		w.rounds, w.since = make(map[string]int), make(map[string]time.Time)
+	}
+	for _, e := range events {
+		vip := e.Vip
+		prev := w.rounds[vip]
+		if !e.Mismatch {
+			w.rounds[vip] = 0
+			metrics.KeepalivedStateMismatch.WithLabelValues(vip).Set(0)
+			if prev >= keepalivedMismatchRounds {
+				e.Since = w.since[vip]
+				util.Logger.Info("keepalived state of vip %s agrees with etcd again", vip)
+				publish = append(publish, e)
+			}
+			delete(w.since, vip)
+			continue
+		}
+		if prev == 0 {
+			w.since[vip] = now
+		}
+		w.rounds[vip] = prev + 1
+		e.Since = w.since[vip]
+		if w.rounds[vip] < keepalivedMismatchRounds {
+			continue
+		}
+		metrics.KeepalivedStateMismatch.WithLabelValues(vip).Set(1)
+		status.Mismatches = append(status.Mismatches, e)
+		if w.rounds[vip] == keepalivedMismatchRounds {
+			util.Logger.Warn("keepalived state of vip %s is %q but etcd master is %v since %v", vip, e.State, e.EtcdMaster, e.Since)
+			publish = append(publish, e)
+		}
+	}
+	w.status = status
+	w.lock.Unlock()
+	for _, e := range publish {
+		b.pubSubSystem.Publish(&Event{Type: EventKeepalivedState, Time: now, Vip: e.Vip, Data: e})
+	}
+}
+
+// KeepalivedHandler 返回最近一次读取的keepalived状态
+func (b *BrainServer) KeepalivedHandler(w http.ResponseWriter, r *http.Request) {
+	b.keepalived.lock.RLock()
+	status := b.keepalived.status
+	b.keepalived.lock.RUnlock()
+	if status.Time.IsZero() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "keepalived state not read yet"})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
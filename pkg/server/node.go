@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/util"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// nodeTTL 节点注册租约时长，节点退出后key自动删除
+const nodeTTL = 10
+
+// registerNode 注册 /nodes/<name> -> localip，供按节点名称操作vip时解析ip
+func (b *BrainServer) registerNode(ctx context.Context) {
+	key := config.NodePrefix + config.GlobalConfigInstance.NodeName
+	ip := config.GlobalConfigInstance.LocalIP
+	for {
+		if err := b.keepNode(ctx, key, ip); err != nil {
+			util.Logger.Warn("register node %s failed:%v", key, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// keepNode 注册并续约，租约失效后返回
+func (b *BrainServer) keepNode(ctx context.Context, key, ip string) error {
+	cli := b.cli.Client()
+	lease, err := cli.Grant(ctx, nodeTTL)
+	if err != nil {
+		return err
+	}
+	if _, err = cli.Put(ctx, key, ip, clientv3.WithLease(lease.ID)); err != nil {
+		return err
+	}
+	ch, err := cli.KeepAlive(ctx, lease.ID)
+	if err != nil {
+		return err
+	}
+	for range ch {
+	}
+	return fmt.Errorf("lease of %s expired", key)
+}
+
+// resolveNode 节点名称解析为ip，参数本身为ip时直接返回
+func (b *BrainServer) resolveNode(ctx context.Context, node string) (string, error) {
+	if net.ParseIP(node) != nil {
+		return node, nil
+	}
+	resp, err := b.cli.Client().Get(ctx, config.NodePrefix+node)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", fmt.Errorf("%w: node %s is not registered", errInvalidRequest, node)
+	}
+	return string(resp.Kvs[0].Value), nil
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/util"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	MoveModeRaise = "raise" // 提升目标节点的优先级
+	MoveModeLower = "lower" // 降低其他节点的优先级
+)
+
+// errConflict 同一vip已有切换在进行中
+var errConflict = errors.New("conflict")
+
+// MoveVipRequest 将vip切换到指定节点
+type MoveVipRequest struct {
+	Vip  string `json:"vip"`
+	Node string `json:"node"` // 节点名称或ip
+	Mode string `json:"mode"`
+	// 等待目标节点成为master的超时时间，超时后回滚
+	Timeout string `json:"timeout"`
+	// 目标节点成为master后继续保持临时优先级的时间
+	Hold string `json:"hold"`
+}
+
+// MoveVipResult 切换结果
+type MoveVipResult struct {
+	Vip           string      `json:"vip"`
+	Target        string      `json:"target"`
+	PreviousOwner string      `json:"previous_owner"`
+	Overrides     []VipMember `json:"overrides"`
+	Elapsed       string      `json:"elapsed"`
+}
+
+// vipMembers etcd中注册了vip的节点及其生效的优先级，存在临时优先级时以临时优先级为准
+func (b *BrainServer) vipMembers(ctx context.Context, vip string) ([]VipMember, error) {
+	prefix := config.KeepAlivedPrefix + vip + "/"
+	resp, err := b.cli.Get(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	overridePrefix := config.OverridePrefix + vip + "/"
+	oresp, err := b.cli.Get(ctx, overridePrefix)
+	if err != nil {
+		return nil, err
+	}
+	overrides := make(map[string]int, len(oresp.Kvs))
+	for _, kv := range oresp.Kvs {
+		priority, err := strconv.Atoi(string(kv.Value))
+		if err != nil {
+			continue
+		}
+		overrides[strings.TrimPrefix(string(kv.Key), overridePrefix)] = priority
+	}
+	members := make([]VipMember, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		priority, err := strconv.Atoi(string(kv.Value))
+		if err != nil {
+			continue
+		}
+		m := VipMember{IP: strings.TrimPrefix(string(kv.Key), prefix), Priority: priority}
+		if p, ok := overrides[m.IP]; ok {
+			m.Priority = p
+			m.Override = true
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// vipOwner 返回优先级最高的节点，优先级相同时取key排序靠前的节点
+func vipOwner(members []VipMember) (string, int) {
+	var (
+		owner string
+		max   = -256
+	)
+	for _, m := range members {
+		if owner == "" || m.Priority > max {
+			owner = m.IP
+			max = m.Priority
+		}
+	}
+	return owner, max
+}
+
+// isSoleOwner 按/check的判断方式，目标节点是否为唯一的最高优先级节点
+func isSoleOwner(members []VipMember, ip string) bool {
+	owner, max := vipOwner(members)
+	if owner != ip {
+		return false
+	}
+	for _, m := range members {
+		if m.IP != ip && m.Priority == max {
+			return false
+		}
+	}
+	return true
+}
+
+// MoveVip 通过临时优先级将vip切换到目标节点，目标节点成为master后清除临时优先级，超时回滚
+func (b *BrainServer) MoveVip(ctx context.Context, req *MoveVipRequest) (*MoveVipResult, error) {
+	start := time.Now()
+	timeout, hold, err := parseMoveDurations(req)
+	if err != nil {
+		return nil, err
+	}
+	// 目标节点已是唯一master时直接返回，mode需要提前校验
+	switch req.Mode {
+	case "", MoveModeRaise, MoveModeLower:
+	default:
+		return nil, fmt.Errorf("%w: unknown mode %s", errInvalidRequest, req.Mode)
+	}
+	if req.Vip == "" || req.Node == "" {
+		return nil, fmt.Errorf("%w: vip and node are required", errInvalidRequest)
+	}
+	target, err := b.resolveNode(ctx, req.Node)
+	if err != nil {
+		return nil, err
+	}
+	members, err := b.vipMembers(ctx, req.Vip)
+	if err != nil {
+		return nil, err
+	}
+	res := &MoveVipResult{Vip: req.Vip, Target: target}
+	res.PreviousOwner, _ = vipOwner(members)
+	if isSoleOwner(members, target) {
+		res.Elapsed = time.Since(start).String()
+		return res, nil
+	}
+	res.Overrides, err = moveOverrides(members, target, req.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	// 临时优先级绑定租约，即使本进程异常退出也会自动清除
+	cli := b.cli.Client()
+	lease, err := cli.Grant(ctx, int64((timeout + hold + 10*time.Second).Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	prefix := config.OverridePrefix + req.Vip + "/"
+	var ops []clientv3.Op
+	for _, m := range res.Overrides {
+		ops = append(ops, clientv3.OpPut(prefix+m.IP, strconv.Itoa(m.Priority), clientv3.WithLease(lease.ID)))
+	}
+	txnResp, err := cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(prefix), "=", 0).WithPrefix()).
+		Then(ops...).Commit()
+	if err != nil {
+		b.revokeOverride(lease.ID)
+		return nil, err
+	}
+	if !txnResp.Succeeded {
+		b.revokeOverride(lease.ID)
+		return nil, fmt.Errorf("%w: another move of vip %s is in progress", errConflict, req.Vip)
+	}
+	util.Logger.Info("move vip %s to %s, overrides:%v", req.Vip, target, res.Overrides)
+	// 无论成功与否都清除临时优先级，失败时即回滚
+	defer b.revokeOverride(lease.ID)
+
+	if err := b.waitOwner(ctx, req.Vip, target, timeout); err != nil {
+		util.Logger.Error("move vip %s to %s failed, rollback:%v", req.Vip, target, err)
+		return nil, err
+	}
+	if hold > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(hold):
+		}
+	}
+	res.Elapsed = time.Since(start).String()
+	return res, nil
+}
+
+func parseMoveDurations(req *MoveVipRequest) (timeout, hold time.Duration, err error) {
+	timeout = 30 * time.Second
+	if req.Timeout != "" {
+		if timeout, err = time.ParseDuration(req.Timeout); err != nil {
+			return 0, 0, fmt.Errorf("%w: %v", errInvalidRequest, err)
+		}
+	}
+	if req.Hold != "" {
+		if hold, err = time.ParseDuration(req.Hold); err != nil {
+			return 0, 0, fmt.Errorf("%w: %v", errInvalidRequest, err)
+		}
+	}
+	return timeout, hold, nil
+}
+
+// moveOverrides 计算使目标节点成为唯一最高优先级所需的临时优先级
+func moveOverrides(members []VipMember, target, mode string) ([]VipMember, error) {
+	var (
+		tm  *VipMember
+		max = -256
+	)
+	for i := range members {
+		if members[i].IP == target {
+			tm = &members[i]
+			continue
+		}
+		if members[i].Priority > max {
+			max = members[i].Priority
+		}
+	}
+	if tm == nil {
+		return nil, fmt.Errorf("%w: target %s is not registered, it may be drained or unhealthy", errInvalidRequest, target)
+	}
+	switch mode {
+	case "", MoveModeRaise:
+		return []VipMember{{IP: target, Priority: max + 1, Override: true}}, nil
+	case MoveModeLower:
+		var overrides []VipMember
+		for _, m := range members {
+			if m.IP != target && m.Priority >= tm.Priority {
+				overrides = append(overrides, VipMember{IP: m.IP, Priority: tm.Priority - 1, Override: true})
+			}
+		}
+		return overrides, nil
+	}
+	return nil, fmt.Errorf("%w: unknown mode %s", errInvalidRequest, mode)
+}
+
+// waitOwner 等待目标节点成为vip唯一的master
+func (b *BrainServer) waitOwner(ctx context.Context, vip, target string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		members, err := b.vipMembers(ctx, vip)
+		if err == nil && isSoleOwner(members, target) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait %s to own vip %s: %w", target, vip, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *BrainServer) revokeOverride(id clientv3.LeaseID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := b.cli.Client().Revoke(ctx, id); err != nil {
+		util.Logger.Error("revoke override lease %x failed:%v", id, err)
+	}
+}
+
+// MoveVipHandler 将vip切换到指定节点，请求在切换完成或超时回滚后返回
+// curl -X POST -d '{"vip":"10.1.1.133","node":"node2","timeout":"30s"}' http://127.0.0.1:12345/v1/admin/move-vip
+func (b *BrainServer) MoveVipHandler(w http.ResponseWriter, r *http.Request) {
+	req := &MoveVipRequest{}
+	if err := decodeJSON(r, req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	timeout, hold, err := parseMoveDurations(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + hold + 10*time.Second)); err != nil {
+		util.Logger.Warn("move vip extend write deadline failed:%v", err)
+	}
+	res, err := b.MoveVip(r.Context(), req)
+	if err != nil {
+		writeJSON(w, errorCode(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
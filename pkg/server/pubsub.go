@@ -9,6 +9,13 @@ import (
 type Sub struct {
 	ch     chan any
 	filter func(entry any) bool
+	// 订阅通道已满时丢弃的消息数
+	dropped uint64
+}
+
+// TakeDropped 获取并清零自上次调用以来丢弃的消息数
+func (s *Sub) TakeDropped() uint64 {
+	return atomic.SwapUint64(&s.dropped, 0)
 }
 
 func New() *PubSub {
@@ -31,17 +38,18 @@ func (ps *PubSub) Publish(item interface{}) {
 			select {
 			case sub.ch <- item:
 			default:
+				atomic.AddUint64(&sub.dropped, 1)
 			}
 		}
 	}
 }
 
-// Subscribe 订阅
-func (ps *PubSub) Subscribe(subCh chan interface{}, doneCh <-chan struct{}, filter func(entry interface{}) bool) {
+// Subscribe 订阅，通道已满时消息会被丢弃，可通过返回的Sub获取丢弃数量
+func (ps *PubSub) Subscribe(subCh chan interface{}, doneCh <-chan struct{}, filter func(entry interface{}) bool) *Sub {
 	ps.Lock()
 	defer ps.Unlock()
 
-	sub := &Sub{subCh, filter}
+	sub := &Sub{ch: subCh, filter: filter}
 	ps.subs = append(ps.subs, sub)
 	atomic.AddInt32(&ps.numSubscribers, 1)
 	go func() {
@@ -56,6 +64,7 @@ func (ps *PubSub) Subscribe(subCh chan interface{}, doneCh <-chan struct{}, filt
 		}
 		atomic.AddInt32(&ps.numSubscribers, -1)
 	}()
+	return sub
 }
 
 // NumSubscribers 当前订阅者数量
@@ -2,216 +2,240 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"sync"
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/client"
 	"system-usability-detection/pkg/metrics"
+	"system-usability-detection/pkg/status_check"
 	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 type BrainServer struct {
 	pubSubSystem *PubSub
-	cli          *etcdClient
+	cli          *client.EtcdClient
 	subCh        chan interface{}
+
+	// 最近一次检测结果
+	statusLock sync.RWMutex
+	lastStatus []status_check.StatusAction
+
+	// keepalived自身的状态
+	keepalived keepalivedWatcher
+	// notify上报的变化记录共用的租约
+	historyLease historyLease
 }
 
 func NewBrainServer() (*BrainServer, error) {
 	b := &BrainServer{
 		pubSubSystem: New(),
 		subCh:        make(chan interface{}, 1000),
+		keepalived:   newKeepalivedWatcher(config.GlobalConfigInstance.Keepalived),
 	}
-	cli, err := newEtcdClient(globalVrrpInstances.etcdPoints, globalVrrpInstances.dial, globalVrrpInstances.ttl)
+	vi := config.GlobalConfigInstance.VrrpInstances
+	cli, err := client.NewEtcdClient(vi.EtcdPoints(), vi.Dial(), vi.TTL())
 	if err != nil {
 		return nil, err
 	}
+	cli.OnKeepalive(b.publishKeepaliveEvent)
 	b.cli = cli
 	return b, nil
 }
 
-func (b *BrainServer) Start(ctx context.Context, status []StatusInterface) {
-	go b.cli.startKeepAlived(ctx)
+// Etcd 获取etcd客户端，用于创建检测模块的运行环境
+func (b *BrainServer) Etcd() *clientv3.Client {
+	return b.cli.Client()
+}
+
+func (b *BrainServer) Start(ctx context.Context, status []status_check.StatusInterface) {
+	// 先恢复维护模式，避免重启后短暂注册已维护的vip
+	if _, err := b.LoadDrain(ctx); err != nil {
+		util.Logger.Error("load drain state failed:%v", err)
+	}
+	// 启动检测模块的后台任务，服务退出时停止
+	if err := status_check.StartWorkers(ctx, status); err != nil {
+		util.Logger.Error("start check workers failed:%v", err)
+	}
+	go b.watchDrain(ctx)
+	go b.registerNode(ctx)
+	go b.cli.StartKeepalive(ctx)
 	go b.pubKeepalivedServerStatus(ctx, status)
 	go b.subKeepalivedServerStatus(ctx)
+	go b.watchOwnership(ctx)
+	go b.watchKeepalived(ctx)
+	go b.watchVrrp(ctx)
 }
 
-// curl -sL -m 1 -H 'Vip: 10.1.33.133' -H 'Local: enp101s0f1' -w %{http_code} http://10.1.33.45:12345/check -o /dev/null
-func (b *BrainServer) BrainCheckHandler(w http.ResponseWriter, r *http.Request) {
-	now := time.Now()
-	var httpCode = http.StatusOK
+// CheckResult vip检测结果，http和grpc接口共用
+type CheckResult struct {
+	// http接口返回的状态码
+	Code        int
+	LocalIP     string
+	Priority    int
+	MaxPriority int
+	Err         error
+}
 
-	defer func() {
-		used := time.Since(now)
-		metrics.RequestHistogram.WithLabelValues(fmt.Sprintf("%d", httpCode), "GET").Observe(used.Seconds())
-		logger.Infof("BrainCheckHandler used:%v", used)
-	}()
+// Master 本节点是否为最高优先级节点
+func (c *CheckResult) Master() bool {
+	return c.Code == http.StatusOK
+}
 
-	local := r.Header.Get("Local")
+// checkVip 判断本节点(local网卡)是否为vip在etcd中的最高优先级节点
+func (b *BrainServer) checkVip(ctx context.Context, vip, local string) *CheckResult {
 	if local == "" {
-		httpCode = http.StatusBadRequest
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return &CheckResult{Code: http.StatusBadRequest, Err: errors.New("empty local interface")}
 	}
-	// logger.Infof("local:%s", local)
-	ip, err := getIPByName(local)
+	ip, err := util.GetIPByName(local)
 	if err != nil {
-		logger.Errorf("get ip by interface name failed:%v", err)
-		httpCode = http.StatusBadRequest
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		util.Logger.Error("get ip by interface name failed:%v", err)
+		return &CheckResult{Code: http.StatusBadRequest, Err: err}
 	}
-
-	vip := r.Header.Get("Vip")
 	if vip == "" {
-		httpCode = http.StatusBadRequest
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return &CheckResult{Code: http.StatusBadRequest, LocalIP: ip, Err: errors.New("empty vip")}
 	}
-	prefix := keepAlivedPrefix + vip
-	key := prefix + "/" + ip
-	resp, err := b.cli.get(context.Background(), prefix)
+	// 维护模式下本节点不作为该vip的master
+	if ins := config.GlobalConfigInstance.VrrpInstances.Find(vip); ins != nil && ins.IsDrained() && ins.LocalIP == ip {
+		return &CheckResult{Code: http.StatusForbidden, LocalIP: ip, Priority: ins.Priority()}
+	}
+	members, err := b.vipMembers(ctx, vip)
 	if err != nil {
-		logger.Errorf("get key %s with prefix failed:%v", prefix, err)
-		httpCode = http.StatusInternalServerError
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		util.Logger.Error("get members of vip %s failed:%v", vip, err)
+		return &CheckResult{Code: http.StatusInternalServerError, LocalIP: ip, Err: err}
 	}
-
-	if resp.Count == 0 {
-		httpCode = http.StatusInternalServerError
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	if len(members) == 0 {
+		return &CheckResult{Code: http.StatusInternalServerError, LocalIP: ip, Err: errors.New("no member registered for vip " + vip)}
 	}
-	var (
-		exist    bool
-		priority int
-		max      = -256
-	)
-	for i := range resp.Kvs {
-		k := resp.Kvs[i].Key
-		v := resp.Kvs[i].Value
-		v_priority, err := strconv.Atoi(string(v))
-		if err != nil {
-			continue
-		}
-		if key == string(k) {
-			priority = v_priority
+	res := &CheckResult{Code: http.StatusForbidden, LocalIP: ip, MaxPriority: -256}
+	var exist bool
+	for _, m := range members {
+		if m.IP == ip {
+			res.Priority = m.Priority
 			exist = true
 		}
-		if v_priority >= max {
-			max = v_priority
+		if m.Priority >= res.MaxPriority {
+			res.MaxPriority = m.Priority
 		}
 	}
-	if exist && priority == max {
+	if exist && res.Priority == res.MaxPriority {
 		// 说明当前节点优先级最高
-		w.WriteHeader(http.StatusOK)
+		res.Code = http.StatusOK
+	}
+	return res
+}
+
+// curl -sL -m 1 -H 'Vip: 10.1.33.133' -H 'Local: enp101s0f1' -w %{http_code} http://10.1.33.45:12345/check -o /dev/null
+func (b *BrainServer) BrainCheckHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	var httpCode = http.StatusOK
+
+	defer func() {
+		used := time.Since(now)
+		metrics.RequestHistogram.WithLabelValues(fmt.Sprintf("%d", httpCode), "GET").Observe(used.Seconds())
+		util.Logger.Info("BrainCheckHandler used:%v", used)
+	}()
+
+	res := b.checkVip(context.Background(), r.Header.Get("Vip"), r.Header.Get("Local"))
+	httpCode = res.Code
+	w.WriteHeader(res.Code)
+}
+
+// VipsHandler 列出本节点配置的vip及etcd中的注册情况
+func (b *BrainServer) VipsHandler(w http.ResponseWriter, r *http.Request) {
+	vips, err := b.ListVips(r.Context())
+	if err != nil {
+		util.Logger.Error("list vips failed:%v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	// httpCode = http.StatusForbidden
-	w.WriteHeader(http.StatusForbidden)
+	writeJSON(w, http.StatusOK, vips)
+}
+
+// HealthHandler 返回最近一次检测结果，不健康时返回503
+func (b *BrainServer) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	report := b.Health()
+	code := http.StatusOK
+	if !report.Healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, report)
 }
 
 // 订阅keepalived服务状态
 func (b *BrainServer) subKeepalivedServerStatus(ctx context.Context) {
-	b.pubSubSystem.Subscribe(b.subCh, ctx.Done(), func(entry interface{}) bool {
-		_, ok := entry.([]StatusAction)
-		return ok
-	})
+	b.pubSubSystem.Subscribe(b.subCh, ctx.Done(), isStatusAction)
 	for {
 		select {
 		case status := <-b.subCh:
-			sa, ok := status.([]StatusAction)
+			sa, ok := status.([]status_check.StatusAction)
 			if !ok {
 				continue
 			}
+			b.setStatus(sa)
 			// 聚合状态查询
 			var isOk = true
 			for _, ele := range sa {
 				if !ele.Status {
 					isOk = false
-					logger.Warningf("get status:%v failed", sa)
+					util.Logger.Warn("get status:%v failed", sa)
 					break
 				}
 			}
 			// 如果检查不是running状态
 			if !isOk {
-				notifyDown.close()
+				util.NotifyDown.Close()
 				continue
 			}
 			// running状态,到这还需要判断之前是否关闭过
-			if notifyDown.isClosed() {
-				notifyDown.renew()
-				logger.Infof("get status ok, start keep alive again.")
-				go b.cli.startKeepAlived(ctx)
+			if util.NotifyDown.IsClosed() {
+				util.NotifyDown.Renew()
+				util.Logger.Info("get status ok, start keep alive again.")
+				go b.cli.StartKeepalive(ctx)
 			}
 
 		case <-ctx.Done():
-			logger.Warning("subKeepalivedServerStatus cancel all context")
+			util.Logger.Warn("subKeepalivedServerStatus cancel all context")
 			return
 		}
 	}
 }
 
-// 进入该函数之前,statusCheck已对重复Name进行拦截,获取keepalived服务状态,推送
-func (b *BrainServer) pubKeepalivedServerStatus(ctx context.Context, statusCheck []StatusInterface) {
-	var (
-		oncePower sync.Once
-		onceNas   sync.Once
-	)
+// 进入该函数之前,status_check.Build已对重复Name进行拦截并为每个模块增加超时控制,获取keepalived服务状态,推送
+// 每个检测模块单独限时，超时的模块单独标记为失败，不影响其他模块的结果
+func (b *BrainServer) pubKeepalivedServerStatus(ctx context.Context, statusCheck []status_check.StatusInterface) {
 	var timeTicker = time.NewTimer(5 * time.Second)
 	defer timeTicker.Stop()
 	for {
 		select {
 		case <-timeTicker.C:
 			timeTicker.Reset(5 * time.Second)
-			result := make(chan []StatusAction, 1)
-			go func() {
-				var sts = make([]StatusAction, len(statusCheck))
-				var wg sync.WaitGroup
-				for i := range statusCheck {
-					if statusCheck[i].Name() == "power_cache" {
-						// 启动一个后台power_cache检测任务
-						oncePower.Do(func() {
-							point := statusCheck[i].(*PowerCacheImpl).MountPoint
-							go backGroundPowerCheck(b.cli.cli, point)
-							go aggregationPower(b.cli.cli)
-						})
-					}
-
-					if statusCheck[i].Name() == "nas" {
-						onceNas.Do(func() {
-							addr := statusCheck[i].(*NasImpl).Address
-							go backGroundNasCheck(addr)
-						})
-					}
-
-					wg.Add(1)
-					go func(index int) {
-						defer wg.Done()
-						sts[index] = statusCheck[index].CheckStatus()
-					}(i)
+			var sts = make([]status_check.StatusAction, len(statusCheck))
+			var wg sync.WaitGroup
+			for i := range statusCheck {
+				wg.Add(1)
+				go func(index int) {
+					defer wg.Done()
+					sts[index] = statusCheck[index].CheckStatus(ctx)
+				}(i)
+			}
+			wg.Wait()
+			var timeout float64
+			for _, sa := range sts {
+				if sa.TimedOut {
+					timeout = 1
+					util.Logger.Error("pubKeepalivedServerStatus check %s timeout:%v", sa.Name, sa.Extra)
 				}
-				wg.Wait()
-				result <- sts
-			}()
-			select {
-			case chanResult := <-result:
-				metrics.ExecuteTimeOutGauge.Set(0)
-				b.pubSubSystem.Publish(chanResult)
-			case <-time.After(5 * time.Second):
-				metrics.ExecuteTimeOutGauge.Set(1)
-				b.pubSubSystem.Publish([]StatusAction{
-					{
-						Time:   time.Now(),
-						Status: false,
-						Extra:  "execute all check timeout 5 seconds",
-					},
-				})
-				logger.Error("pubKeepalivedServerStatus execute all check timeout 5 seconds")
 			}
+			metrics.ExecuteTimeOutGauge.Set(timeout)
+			b.pubSubSystem.Publish(sts)
 
 		case <-ctx.Done():
-			logger.Warning("pubKeepalivedServerStatus cancel all context")
+			util.Logger.Warn("pubKeepalivedServerStatus cancel all context")
 			return
 		}
 	}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/pkg/status_check"
+)
+
+// CheckReport 单个检测模块的结果
+type CheckReport struct {
+	Name     string    `json:"name"`
+	Status   bool      `json:"status"`
+	Level    string    `json:"level"`
+	TimedOut bool      `json:"timed_out,omitempty"`
+	Extra    string    `json:"extra,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// HealthReport 一轮检测的聚合结果
+type HealthReport struct {
+	Healthy bool          `json:"healthy"`
+	Time    time.Time     `json:"time"`
+	Checks  []CheckReport `json:"checks"`
+}
+
+// VipMember etcd中注册了某个vip的节点
+type VipMember struct {
+	IP       string `json:"ip"`
+	Priority int    `json:"priority"`
+	// 优先级来自/override/下的临时优先级
+	Override bool `json:"override,omitempty"`
+}
+
+// VipStatus 本节点配置的vip状态
+type VipStatus struct {
+	Vip        string      `json:"vip"`
+	LocalIP    string      `json:"local_ip"`
+	Priority   int         `json:"priority"`
+	Registered bool        `json:"registered"`
+	Master     bool        `json:"master"`
+	Drained    bool        `json:"drained"`
+	Members    []VipMember `json:"members"`
+}
+
+func (b *BrainServer) setStatus(sa []status_check.StatusAction) {
+	b.statusLock.Lock()
+	prev := b.lastStatus
+	b.lastStatus = sa
+	b.statusLock.Unlock()
+	b.publishCheckEvents(prev, sa)
+}
+
+// Health 最近一次检测结果，尚未完成检测时视为不健康
+func (b *BrainServer) Health() *HealthReport {
+	b.statusLock.RLock()
+	defer b.statusLock.RUnlock()
+	return NewHealthReport(b.lastStatus)
+}
+
+func NewHealthReport(sa []status_check.StatusAction) *HealthReport {
+	report := &HealthReport{
+		Healthy: len(sa) > 0,
+		Checks:  make([]CheckReport, 0, len(sa)),
+	}
+	for _, ele := range sa {
+		if !ele.Status {
+			report.Healthy = false
+		}
+		if ele.Time.After(report.Time) {
+			report.Time = ele.Time
+		}
+		cr := CheckReport{
+			Name:     ele.Name,
+			Status:   ele.Status,
+			Level:    ele.Severity(),
+			TimedOut: ele.TimedOut,
+			Time:     ele.Time,
+		}
+		if ele.Extra != nil {
+			cr.Extra = fmt.Sprint(ele.Extra)
+		}
+		report.Checks = append(report.Checks, cr)
+	}
+	return report
+}
+
+// ListVips 本节点配置的vip以及etcd中注册的节点
+func (b *BrainServer) ListVips(ctx context.Context) ([]*VipStatus, error) {
+	var vips []*VipStatus
+	for _, ins := range config.GlobalConfigInstance.VrrpInstances.Instances {
+		vs := &VipStatus{
+			Vip:      ins.VirtualIP(),
+			LocalIP:  ins.LocalIP,
+			Priority: ins.Priority(),
+			Drained:  ins.IsDrained(),
+			Members:  make([]VipMember, 0),
+		}
+		members, err := b.vipMembers(ctx, vs.Vip)
+		if err != nil {
+			return nil, err
+		}
+		vs.Members = append(vs.Members, members...)
+		_, max := vipOwner(members)
+		for _, m := range members {
+			if m.IP == vs.LocalIP {
+				vs.Registered = true
+				// 与/check一致，优先级并列最高时均视为master
+				vs.Master = m.Priority == max
+			}
+		}
+		vips = append(vips, vs)
+	}
+	return vips, nil
+}
+
+// errInvalidRequest 请求参数错误，http接口返回400
+var errInvalidRequest = errors.New("invalid request")
+
+// errorCode 错误对应的http状态码
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, errInvalidRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, errConflict):
+		return http.StatusConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// decodeJSON 解析请求体，请求体为空时保持默认值
+func decodeJSON(r *http.Request, v any) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
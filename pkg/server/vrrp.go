@@ -0,0 +1,456 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/keepalived"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// StateStop keepalived 2.x停止实例时通过notify_stop上报
+const StateStop = "STOP"
+
+// VrrpTransition keepalived通过notify脚本上报的状态变化
+// 当前状态保存在etcd /vrrp/state/<vip>/<name>，变化记录保存在/vrrp/history/<vip>/<unixnano>-<name>
+type VrrpTransition struct {
+	Vip      string    `json:"vip"`
+	Instance string    `json:"instance"`
+	State    string    `json:"state"`
+	Priority int       `json:"priority,omitempty"`
+	Node     string    `json:"node"`
+	IP       string    `json:"ip"`
+	Time     time.Time `json:"time"`
+}
+
+// NotifyRequest notify脚本上报的状态，Instance为keepalived实例名称或vip
+type NotifyRequest struct {
+	Instance string `json:"instance"`
+	State    string `json:"state"`
+	Priority int    `json:"priority"`
+}
+
+// NotifyResult 记录的状态变化及上报后仍存在的多master冲突
+type NotifyResult struct {
+	Transitions []*VrrpTransition `json:"transitions"`
+	Conflicts   []VrrpConflict    `json:"conflicts"`
+}
+
+// VrrpConflict 多个存活节点同时上报为MASTER的vip，作为事件推送时Masters少于2个表示冲突已解除
+type VrrpConflict struct {
+	Vip     string            `json:"vip"`
+	Masters []*VrrpTransition `json:"masters"`
+}
+
+// VrrpStatus 各节点上报的当前状态
+type VrrpStatus struct {
+	States    []*VrrpTransition `json:"states"`
+	Conflicts []VrrpConflict    `json:"conflicts"`
+}
+
+// Notify 记录本节点keepalived实例的状态变化，返回涉及vip的多master冲突
+func (b *BrainServer) Notify(ctx context.Context, req *NotifyRequest) (*NotifyResult, error) {
+	state := strings.ToUpper(req.State)
+	switch state {
+	case keepalived.StateMaster, keepalived.StateBackup, keepalived.StateFault, StateStop:
+	default:
+		return nil, fmt.Errorf("%w: unknown state %q", errInvalidRequest, req.State)
+	}
+	vips, err := b.notifyVips(ctx, req.Instance)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	node := config.GlobalConfigInstance.NodeName
+	res := &NotifyResult{}
+	for _, vip := range vips {
+		res.Transitions = append(res.Transitions, &VrrpTransition{
+			Vip:      vip,
+			Instance: req.Instance,
+			State:    state,
+			Priority: req.Priority,
+			Node:     node,
+			IP:       config.GlobalConfigInstance.LocalIP,
+			Time:     now,
+		})
+	}
+	if err := b.putTransitions(ctx, res.Transitions); err != nil {
+		return nil, err
+	}
+	util.Logger.Info("keepalived instance %s of vips %v is %s", req.Instance, vips, state)
+	for _, vip := range vips {
+		masters, err := b.vrrpMasters(ctx, vip)
+		if err != nil {
+			return nil, err
+		}
+		if len(masters) > 1 {
+			res.Conflicts = append(res.Conflicts, VrrpConflict{Vip: vip, Masters: masters})
+		}
+	}
+	return res, nil
+}
+
+// putTransitions 写入当前状态和变化记录，共用的租约已失效(如etcd恢复数据)时重新申请一次
+func (b *BrainServer) putTransitions(ctx context.Context, transitions []*VrrpTransition) error {
+	retention := config.GlobalConfigInstance.Keepalived.HistoryRetention
+	for retry := 0; ; retry++ {
+		leaseID, err := b.historyLease.get(ctx, b.cli.Client(), time.Now(), retention)
+		if err != nil {
+			return err
+		}
+		var ops []clientv3.Op
+		for _, t := range transitions {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			ops = append(ops,
+				clientv3.OpPut(config.VrrpStatePrefix+t.Vip+"/"+t.Node, string(data)),
+				clientv3.OpPut(fmt.Sprintf("%s%s/%d-%s", config.VrrpHistoryPrefix, t.Vip, t.Time.UnixNano(), t.Node), string(data), clientv3.WithLease(leaseID)))
+		}
+		_, err = b.cli.Client().Txn(ctx).Then(ops...).Commit()
+		if errors.Is(err, rpctypes.ErrLeaseNotFound) && retry == 0 {
+			b.historyLease.reset(leaseID)
+			continue
+		}
+		return err
+	}
+}
+
+// historyLease 变化记录共用的租约，同一时间段内的记录使用同一个租约，避免每次上报都申请一个租约
+type historyLease struct {
+	lock   sync.Mutex
+	bucket int64
+	id     clientv3.LeaseID
+}
+
+// get 返回now所在时间段的租约，时间段长度为保留时间的1/10(至少1s)，
+// 租约时长为保留时间加一个时间段，时间段内最后写入的记录也至少保留retention
+func (l *historyLease) get(ctx context.Context, cli *clientv3.Client, now time.Time, retention time.Duration) (clientv3.LeaseID, error) {
+	width := max(retention/10, time.Second)
+	bucket := now.UnixNano() / int64(width)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.id != clientv3.NoLease && l.bucket == bucket {
+		return l.id, nil
+	}
+	lease, err := cli.Grant(ctx, int64((retention+width+time.Second-1)/time.Second))
+	if err != nil {
+		return clientv3.NoLease, err
+	}
+	l.bucket, l.id = bucket, lease.ID
+	return lease.ID, nil
+}
+
+// reset 租约失效后丢弃，下次重新申请
+func (l *historyLease) reset(id clientv3.LeaseID) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.id == id {
+		l.id = clientv3.NoLease
+	}
+}
+
+// notifyVips 实例名称为vip或由vip生成时直接使用，否则从keepalived的data文件中查找实例的vip
+func (b *BrainServer) notifyVips(ctx context.Context, instance string) ([]string, error) {
+	if instance == "" {
+		return nil, fmt.Errorf("%w: empty instance", errInvalidRequest)
+	}
+	if net.ParseIP(instance) != nil {
+		if config.GlobalConfigInstance.VrrpInstances.Find(instance) == nil {
+			return nil, fmt.Errorf("%w: vip %s is not configured on this node", errInvalidRequest, instance)
+		}
+		return []string{instance}, nil
+	}
+	// gen-keepalived生成的实例名称包含vip
+	if vip, ok := keepalived.InstanceVip(instance); ok && config.GlobalConfigInstance.VrrpInstances.Find(vip) != nil {
+		return []string{vip}, nil
+	}
+	find := func(instances []*keepalived.Instance) []string {
+		for _, ki := range instances {
+			if ki.Name == instance {
+				return ki.VIPs
+			}
+		}
+		return nil
+	}
+	// 优先使用定时读取的结果，实例新增或未开启定时读取时重新触发
+	if vips := find(b.keepalived.instances()); len(vips) > 0 {
+		return vips, nil
+	}
+	instances, err := b.keepalived.dumper.Dump(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read keepalived state: %w", err)
+	}
+	if vips := find(instances); len(vips) > 0 {
+		return vips, nil
+	}
+	return nil, fmt.Errorf("%w: keepalived instance %s not found or has no vip", errInvalidRequest, instance)
+}
+
+// vrrpStates 读取上报的当前状态，prefix为空时读取所有vip
+func (b *BrainServer) vrrpStates(ctx context.Context, prefix string) ([]*VrrpTransition, error) {
+	resp, err := b.cli.Get(ctx, config.VrrpStatePrefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*VrrpTransition, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		t := &VrrpTransition{}
+		if err := json.Unmarshal(kv.Value, t); err != nil {
+			util.Logger.Warn("invalid vrrp state %s:%v", kv.Key, err)
+			continue
+		}
+		states = append(states, t)
+	}
+	return states, nil
+}
+
+// aliveNodes 当前在/nodes/下注册的节点，节点退出后其上报的状态不再参与冲突判断
+func (b *BrainServer) aliveNodes(ctx context.Context) (map[string]bool, error) {
+	resp, err := b.cli.Client().Get(ctx, config.NodePrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]bool, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		nodes[strings.TrimPrefix(string(kv.Key), config.NodePrefix)] = true
+	}
+	return nodes, nil
+}
+
+// vrrpMasters 存活节点中上报为MASTER的记录
+func (b *BrainServer) vrrpMasters(ctx context.Context, vip string) ([]*VrrpTransition, error) {
+	states, err := b.vrrpStates(ctx, vip+"/")
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := b.aliveNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return mastersOf(states, nodes), nil
+}
+
+func mastersOf(states []*VrrpTransition, nodes map[string]bool) []*VrrpTransition {
+	var masters []*VrrpTransition
+	for _, t := range states {
+		if t.State == keepalived.StateMaster && nodes[t.Node] {
+			masters = append(masters, t)
+		}
+	}
+	return masters
+}
+
+// VrrpStatus 所有vip的当前状态及多master冲突
+func (b *BrainServer) VrrpStatus(ctx context.Context) (*VrrpStatus, error) {
+	states, err := b.vrrpStates(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := b.aliveNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &VrrpStatus{States: states}
+	byVip := make(map[string][]*VrrpTransition)
+	var vips []string
+	for _, t := range states {
+		if _, ok := byVip[t.Vip]; !ok {
+			vips = append(vips, t.Vip)
+		}
+		byVip[t.Vip] = append(byVip[t.Vip], t)
+	}
+	for _, vip := range vips {
+		if masters := mastersOf(byVip[vip], nodes); len(masters) > 1 {
+			status.Conflicts = append(status.Conflicts, VrrpConflict{Vip: vip, Masters: masters})
+		}
+	}
+	return status, nil
+}
+
+// VrrpHistory 状态变化记录，按时间倒序，vip为空时返回所有vip，limit为0时不限制条数
+func (b *BrainServer) VrrpHistory(ctx context.Context, vip string, limit int) ([]*VrrpTransition, error) {
+	prefix := config.VrrpHistoryPrefix
+	if vip != "" {
+		prefix += vip + "/"
+	}
+	resp, err := b.cli.Get(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]*VrrpTransition, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		t := &VrrpTransition{}
+		if err := json.Unmarshal(kv.Value, t); err != nil {
+			util.Logger.Warn("invalid vrrp history %s:%v", kv.Key, err)
+			continue
+		}
+		history = append(history, t)
+	}
+	slices.SortStableFunc(history, func(a, b *VrrpTransition) int {
+		return b.Time.Compare(a.Time)
+	})
+	if limit > 0 && len(history) > limit {
+		history = history[:limit]
+	}
+	return history, nil
+}
+
+// watchVrrp 监听各节点上报的状态和节点注册，推送状态变化，多个节点同时为MASTER时告警
+func (b *BrainServer) watchVrrp(ctx context.Context) {
+	conflicts := make(map[string]bool)
+	refresh := func(vips []string) {
+		nodes, err := b.aliveNodes(ctx)
+		if err != nil {
+			util.Logger.Warn("get alive nodes failed:%v", err)
+			return
+		}
+		for _, vip := range vips {
+			states, err := b.vrrpStates(ctx, vip+"/")
+			if err != nil {
+				util.Logger.Warn("get vrrp state of vip %s failed:%v", vip, err)
+				continue
+			}
+			masters := mastersOf(states, nodes)
+			metrics.VrrpMasters.WithLabelValues(vip).Set(float64(len(masters)))
+			conflict := len(masters) > 1
+			if conflict == conflicts[vip] {
+				continue
+			}
+			conflicts[vip] = conflict
+			if conflict {
+				names := make([]string, 0, len(masters))
+				for _, t := range masters {
+					names = append(names, t.Node)
+				}
+				util.Logger.Warn("vip %s is claimed MASTER by multiple nodes:%v", vip, names)
+			} else {
+				util.Logger.Info("vip %s is claimed MASTER by at most one node again", vip)
+			}
+			b.pubSubSystem.Publish(&Event{Type: EventVrrpConflict, Time: time.Now(), Vip: vip, Data: VrrpConflict{Vip: vip, Masters: masters}})
+		}
+	}
+	for {
+		resp, err := b.cli.Client().Get(ctx, config.VrrpStatePrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+		if err != nil {
+			util.Logger.Warn("get %s failed:%v", config.VrrpStatePrefix, err)
+		} else {
+			known := make(map[string]bool)
+			for _, kv := range resp.Kvs {
+				if vip, _, ok := strings.Cut(strings.TrimPrefix(string(kv.Key), config.VrrpStatePrefix), "/"); ok {
+					known[vip] = true
+				}
+			}
+			for vip := range conflicts {
+				known[vip] = true
+			}
+			vips := make([]string, 0, len(known))
+			for vip := range known {
+				vips = append(vips, vip)
+			}
+			refresh(vips)
+		}
+		watchCtx, cancel := context.WithCancel(ctx)
+		sch := b.cli.Client().Watch(watchCtx, config.VrrpStatePrefix, clientv3.WithPrefix())
+		nch := b.cli.Client().Watch(watchCtx, config.NodePrefix, clientv3.WithPrefix())
+	loop:
+		for {
+			select {
+			case wr, ok := <-sch:
+				if !ok || wr.Err() != nil {
+					util.Logger.Warn("watch %s closed:%v", config.VrrpStatePrefix, wr.Err())
+					break loop
+				}
+				var vips []string
+				for _, ev := range wr.Events {
+					t := &VrrpTransition{}
+					if ev.Type != clientv3.EventTypePut || json.Unmarshal(ev.Kv.Value, t) != nil {
+						continue
+					}
+					b.pubSubSystem.Publish(&Event{Type: EventVrrpState, Time: t.Time, Vip: t.Vip, Data: t})
+					vips = append(vips, t.Vip)
+				}
+				refresh(vips)
+			case wr, ok := <-nch:
+				if !ok || wr.Err() != nil {
+					util.Logger.Warn("watch %s closed:%v", config.NodePrefix, wr.Err())
+					break loop
+				}
+				// 节点上下线影响所有vip的冲突判断
+				vips := make([]string, 0, len(conflicts))
+				for vip := range conflicts {
+					vips = append(vips, vip)
+				}
+				refresh(vips)
+			}
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// NotifyHandler 接收notify脚本上报的状态变化
+// curl -X POST -d '{"instance":"VI_1","state":"MASTER","priority":100}' http://127.0.0.1:12345/v1/notify
+func (b *BrainServer) NotifyHandler(w http.ResponseWriter, r *http.Request) {
+	req := &NotifyRequest{}
+	if err := decodeJSON(r, req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	res, err := b.Notify(r.Context(), req)
+	if err != nil {
+		writeJSON(w, errorCode(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// VrrpHandler 各节点上报的当前状态及多master冲突
+func (b *BrainServer) VrrpHandler(w http.ResponseWriter, r *http.Request) {
+	status, err := b.VrrpStatus(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// VrrpHistoryHandler 状态变化记录
+// curl 'http://127.0.0.1:12345/v1/vrrp/history?vip=10.1.1.133&limit=20'
+func (b *BrainServer) VrrpHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit " + v})
+			return
+		}
+		limit = n
+	}
+	history, err := b.VrrpHistory(r.Context(), q.Get("vip"), limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, history)
+}
@@ -0,0 +1,423 @@
+package status_check
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func init() {
+	Register("clock", Factory{
+		Description: "时钟检测，与其他节点及etcd服务端比较时间偏差，并检测本机暂停(虚拟机挂起等)",
+		Config: func() any {
+			return &ClockConfig{
+				Interval:       time.Second,
+				MaxSkew:        500 * time.Millisecond,
+				PauseThreshold: 2 * time.Second,
+				PauseHold:      time.Minute,
+			}
+		},
+		New: func(name string, cfg any, env *Env) (StatusInterface, error) {
+			return &ClockImpl{name: name, cfg: *cfg.(*ClockConfig), env: env, peers: make(map[string]*ClockPeer)}, nil
+		},
+	})
+}
+
+type ClockConfig struct {
+	// 发布本机时间和检测暂停的周期
+	Interval time.Duration `mapstructure:"interval"`
+	// 与其他节点或etcd服务端的时间偏差超过该值时失败
+	MaxSkew time.Duration `mapstructure:"max_skew"`
+	// 相邻两次tick的间隔比interval多出该值时视为本机暂停过
+	PauseThreshold time.Duration `mapstructure:"pause_threshold"`
+	// 暂停后告警持续的时间
+	PauseHold time.Duration `mapstructure:"pause_hold"`
+	// 是否通过etcd http接口响应的Date头比较服务端时间，Date精度为秒；
+	// 请求不经过etcd客户端，不带客户端证书，只适用于etcd以明文http提供/version的部署，默认关闭
+	ServerTime bool `mapstructure:"server_time"`
+}
+
+func (c *ClockConfig) Validate() error {
+	if c.Interval <= 0 || c.MaxSkew <= 0 || c.PauseThreshold <= 0 || c.PauseHold <= 0 {
+		return errors.New("interval, max_skew, pause_threshold and pause_hold must be greater than 0")
+	}
+	return nil
+}
+
+var (
+	clockCheckCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "clock",
+			Name:      "clock_check_updates",
+			Help:      "Counter of clock updates.",
+		}, []string{"type"}) // 时钟检测计数counter
+
+	clockPeerOffset = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "clock",
+			Name:      "peer_offset_seconds",
+			Help:      "Clock of the peer minus local clock, including the etcd propagation delay.",
+		}, []string{"peer"})
+
+	clockPauses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "clock",
+			Name:      "pauses_total",
+			Help:      "Number of detected local pauses.",
+		})
+)
+
+var (
+	_ StatusInterface = (*ClockImpl)(nil)
+	_ Worker          = (*ClockImpl)(nil)
+)
+
+// clockRecord /clock/<name>的值
+type clockRecord struct {
+	Node string `json:"node"`
+	// 发布时的墙上时间，纳秒
+	Wall int64 `json:"wall"`
+	// 上一次发布时etcd响应头中的revision
+	Revision int64 `json:"revision"`
+}
+
+// ClockPeer 其他节点最近一次发布的时间
+type ClockPeer struct {
+	Node string
+	// 对端时间减去本机收到watch事件的时间，包含etcd的传播延迟，因此略小于真实偏差
+	Offset   time.Duration
+	Revision int64
+	Received time.Time
+}
+
+// ClockPause 本机暂停记录
+type ClockPause struct {
+	At time.Time
+	// 相邻两次tick的单调时钟间隔
+	Gap time.Duration
+}
+
+// ClockImpl 时钟检测，后台定时发布本机时间并监听其他节点，同时按单调时钟检测暂停和墙上时间跳变
+type ClockImpl struct {
+	name string
+	cfg  ClockConfig
+	env  *Env
+
+	lock     sync.Mutex
+	started  bool
+	peers    map[string]*ClockPeer
+	pause    *ClockPause
+	jump     *ClockPause
+	revision int64
+	cancel   context.CancelFunc
+}
+
+// ClockResult 作为StatusAction.Extra
+type ClockResult struct {
+	Peers []ClockPeer
+	// etcd服务端时间减去本机时间的范围，未检查时为nil
+	Server  *[2]time.Duration
+	Pause   *ClockPause
+	Reasons []string
+}
+
+func (r *ClockResult) String() string {
+	var s []string
+	for _, p := range r.Peers {
+		s = append(s, fmt.Sprintf("%s=%v", p.Node, p.Offset.Round(time.Millisecond)))
+	}
+	if r.Server != nil {
+		s = append(s, fmt.Sprintf("etcd=[%v,%v]", r.Server[0].Round(time.Millisecond), r.Server[1].Round(time.Millisecond)))
+	}
+	return strings.Join(append(s, r.Reasons...), " ")
+}
+
+func (c *ClockImpl) Name() string {
+	return c.name
+}
+
+func (c *ClockImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{clockCheckCounter, clockPeerOffset, clockPauses}
+}
+
+// Start 启动暂停检测，连接etcd时同时发布本机时间并监听其他节点
+func (c *ClockImpl) Start(ctx context.Context) error {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.lock.Lock()
+	c.started = true
+	c.lock.Unlock()
+	go c.tick(ctx)
+	if c.env == nil || c.env.Etcd == nil {
+		return errors.New("clock requires an etcd client to compare with peers")
+	}
+	go c.publish(ctx)
+	go c.watch(ctx)
+	return nil
+}
+
+func (c *ClockImpl) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+func (c *ClockImpl) CheckStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", c.Name(), used)
+	}()
+	clockCheckCounter.WithLabelValues("total").Inc()
+	sa := StatusAction{
+		Time:   now,
+		Name:   c.Name(),
+		Status: false,
+	}
+	res := &ClockResult{}
+	sa.Extra = res
+	level := LevelOK
+	fail := func(l, format string, args ...any) {
+		res.Reasons = append(res.Reasons, fmt.Sprintf(format, args...))
+		level = worseLevel(level, l)
+	}
+
+	c.lock.Lock()
+	started := c.started
+	for _, p := range c.peers {
+		// 对端停止发布后不再参与比较
+		if now.Sub(p.Received) > 3*c.cfg.Interval {
+			continue
+		}
+		res.Peers = append(res.Peers, *p)
+	}
+	if c.pause != nil && now.Sub(c.pause.At) < c.cfg.PauseHold {
+		res.Pause = c.pause
+		fail(LevelWarn, "paused %v at %s", c.pause.Gap.Round(time.Millisecond), c.pause.At.Format(time.TimeOnly))
+	}
+	if c.jump != nil && now.Sub(c.jump.At) < c.cfg.PauseHold {
+		fail(LevelWarn, "wall clock jumped %v at %s", c.jump.Gap.Round(time.Millisecond), c.jump.At.Format(time.TimeOnly))
+	}
+	c.lock.Unlock()
+
+	sort.Slice(res.Peers, func(i, j int) bool { return res.Peers[i].Node < res.Peers[j].Node })
+	for _, p := range res.Peers {
+		if p.Offset > c.cfg.MaxSkew || p.Offset < -c.cfg.MaxSkew {
+			fail(LevelCritical, "skew with %s %v", p.Node, p.Offset.Round(time.Millisecond))
+		}
+	}
+	if c.cfg.ServerTime && c.env != nil && c.env.Etcd != nil {
+		low, high, err := c.serverOffset(ctx)
+		switch {
+		case err != nil:
+			fail(LevelWarn, "etcd time: %v", err)
+		case low > c.cfg.MaxSkew || high < -c.cfg.MaxSkew:
+			res.Server = &[2]time.Duration{low, high}
+			fail(LevelCritical, "skew with etcd")
+		default:
+			res.Server = &[2]time.Duration{low, high}
+		}
+	}
+	if !started {
+		fail(LevelUnknown, "not started")
+	}
+	sa.Level = level
+	sa.Status = level == LevelOK || level == LevelWarn
+	if !sa.Status {
+		clockCheckCounter.WithLabelValues("failed").Inc()
+	}
+	return sa
+}
+
+// tick 比较相邻两次tick的单调时钟间隔和墙上时间间隔，分别检测本机暂停和时间跳变
+func (c *ClockImpl) tick(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		// Round(0)去掉单调时钟读数，Sub按墙上时间计算
+		gap, wallGap := now.Sub(last), now.Round(0).Sub(last.Round(0))
+		last = now
+		c.lock.Lock()
+		if gap > c.cfg.Interval+c.cfg.PauseThreshold {
+			c.pause = &ClockPause{At: now, Gap: gap}
+			clockPauses.Inc()
+			util.Logger.Warn("clock: local pause of %v detected", gap)
+		}
+		if d := wallGap - gap; d > c.cfg.MaxSkew || d < -c.cfg.MaxSkew {
+			c.jump = &ClockPause{At: now, Gap: d}
+			util.Logger.Warn("clock: wall clock jumped %v", d)
+		}
+		c.lock.Unlock()
+	}
+}
+
+// publish 在租约上发布本机时间，租约失效后重新申请
+func (c *ClockImpl) publish(ctx context.Context) {
+	key := config.ClockPrefix + c.env.NodeName
+	ttl := int64(3 * c.cfg.Interval / time.Second)
+	if ttl < 5 {
+		ttl = 5
+	}
+	for ctx.Err() == nil {
+		if err := c.keepPublishing(ctx, key, ttl); err != nil && ctx.Err() == nil {
+			util.Logger.Warn("clock: publish %s failed:%v", key, err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(c.cfg.Interval):
+		}
+	}
+}
+
+func (c *ClockImpl) keepPublishing(ctx context.Context, key string, ttl int64) error {
+	cli := c.env.Etcd
+	lease, err := cli.Grant(ctx, ttl)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		revokeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, _ = cli.Revoke(revokeCtx, lease.ID)
+	}()
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		c.lock.Lock()
+		rev := c.revision
+		c.lock.Unlock()
+		data, _ := json.Marshal(clockRecord{Node: c.env.NodeName, Wall: time.Now().UnixNano(), Revision: rev})
+		putCtx, cancel := context.WithTimeout(ctx, c.cfg.Interval)
+		resp, err := cli.Put(putCtx, key, string(data), clientv3.WithLease(lease.ID))
+		cancel()
+		if err != nil {
+			return err
+		}
+		c.lock.Lock()
+		c.revision = resp.Header.Revision
+		c.lock.Unlock()
+		// 发布周期小于租约时长，每次发布时续约一次
+		if _, err := cli.KeepAliveOnce(ctx, lease.ID); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// watch 监听其他节点发布的时间，收到事件时的本机时间与对端时间的差即为偏差(含传播延迟)
+func (c *ClockImpl) watch(ctx context.Context) {
+	for ctx.Err() == nil {
+		for wr := range c.env.Etcd.Watch(clientv3.WithRequireLeader(ctx), config.ClockPrefix, clientv3.WithPrefix()) {
+			received := time.Now()
+			if err := wr.Err(); err != nil {
+				util.Logger.Warn("clock: watch failed:%v", err)
+				break
+			}
+			c.lock.Lock()
+			for _, ev := range wr.Events {
+				node := strings.TrimPrefix(string(ev.Kv.Key), config.ClockPrefix)
+				if node == c.env.NodeName {
+					continue
+				}
+				if ev.Type == clientv3.EventTypeDelete {
+					delete(c.peers, node)
+					clockPeerOffset.DeleteLabelValues(node)
+					continue
+				}
+				var r clockRecord
+				if err := json.Unmarshal(ev.Kv.Value, &r); err != nil {
+					util.Logger.Warn("clock: invalid record of %s:%v", node, err)
+					continue
+				}
+				p := &ClockPeer{Node: node, Offset: time.Unix(0, r.Wall).Sub(received), Revision: r.Revision, Received: received}
+				c.peers[node] = p
+				clockPeerOffset.WithLabelValues(node).Set(p.Offset.Seconds())
+			}
+			c.lock.Unlock()
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(c.cfg.Interval):
+		}
+	}
+}
+
+// serverOffset 根据etcd http接口响应的Date头估算服务端时间减去本机时间的范围，
+// Date精度为秒，服务端时间在[Date, Date+1s)之间，本机时间在请求发出和收到响应之间；
+// 同时请求所有endpoint，使用最先返回的结果，避免不可用的endpoint耗尽超时时间
+func (c *ClockImpl) serverOffset(ctx context.Context) (time.Duration, time.Duration, error) {
+	type offset struct {
+		low, high time.Duration
+		err       error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	endpoints := c.env.Etcd.Endpoints()
+	ch := make(chan offset, len(endpoints))
+	for _, ep := range endpoints {
+		go func() {
+			low, high, err := endpointOffset(ctx, ep)
+			ch <- offset{low, high, err}
+		}()
+	}
+	var lastErr error
+	for range endpoints {
+		r := <-ch
+		if r.err == nil {
+			return r.low, r.high, nil
+		}
+		lastErr = r.err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no etcd endpoints")
+	}
+	return 0, 0, lastErr
+}
+
+func endpointOffset(ctx context.Context, ep string) (time.Duration, time.Duration, error) {
+	if !strings.Contains(ep, "://") {
+		ep = "http://" + ep
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep+"/version", nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	end := time.Now()
+	if err != nil {
+		return 0, 0, err
+	}
+	resp.Body.Close()
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse date of %s: %w", ep, err)
+	}
+	return date.Sub(end), date.Add(time.Second).Sub(start), nil
+}
@@ -0,0 +1,166 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"system-usability-detection/internal/command"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("exec", Factory{
+		Description: "执行脚本检测，兼容nagios插件退出码",
+		Config: func() any {
+			return &ExecConfig{InheritEnv: true, MaxOutput: command.DefaultMaxOutput}
+		},
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return &ExecImpl{name: name, cfg: *cfg.(*ExecConfig)}, nil
+		},
+	})
+}
+
+// nagios插件退出码
+const (
+	nagiosOK       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+)
+
+// ExecConfig 执行的命令不经过shell，需要管道等shell语法时使用/bin/sh -c，超时时间为检测的timeout
+//
+//   - type: exec
+//     command: /usr/lib/nagios/plugins/check_disk
+//     args: ["-w", "10%", "-c", "5%", "-p", "/export"]
+type ExecConfig struct {
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	// KEY=VALUE，viper会将map的key转为小写，因此不使用map
+	Env []string `mapstructure:"env"`
+	// 是否继承守护进程的环境变量
+	InheritEnv bool   `mapstructure:"inherit_env"`
+	Dir        string `mapstructure:"dir"`
+	// 输出最多保留的字节数
+	MaxOutput int `mapstructure:"max_output"`
+	// 为true时warning也视为失败，级别记为critical
+	WarnIsFailure bool `mapstructure:"warn_is_failure"`
+}
+
+func (c *ExecConfig) Validate() error {
+	if c.Command == "" {
+		return errors.New("command is required")
+	}
+	for _, kv := range c.Env {
+		if k, _, ok := strings.Cut(kv, "="); !ok || k == "" {
+			return fmt.Errorf("invalid env %q, want KEY=VALUE", kv)
+		}
+	}
+	if c.MaxOutput <= 0 {
+		return errors.New("max_output must be greater than 0")
+	}
+	return nil
+}
+
+var execCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "exec",
+		Name:      "exec_check_updates",
+		Help:      "Counter of exec updates.",
+	}, []string{"type"}) // 脚本检测计数counter
+
+var _ StatusInterface = (*ExecImpl)(nil)
+
+type ExecImpl struct {
+	name string
+	cfg  ExecConfig
+}
+
+// ExecResult 脚本执行结果
+type ExecResult struct {
+	ExitCode int
+	// 截断后的stdout，nagios插件第一行为状态描述
+	Output    string
+	Truncated bool
+	Err       error
+}
+
+func (r *ExecResult) String() string {
+	s := fmt.Sprintf("exit=%d", r.ExitCode)
+	if r.Err != nil {
+		s += " " + r.Err.Error()
+	}
+	if r.Output != "" {
+		s += " " + r.Output
+	}
+	if r.Truncated {
+		s += "...(truncated)"
+	}
+	return s
+}
+
+func (e *ExecImpl) Name() string {
+	return e.name
+}
+
+func (e *ExecImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{execCheckCounter}
+}
+
+func (e *ExecImpl) CheckStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", e.Name(), used)
+	}()
+	execCheckCounter.WithLabelValues("total").Inc()
+	out := command.Run(ctx, &command.Cmd{
+		Path:       e.cfg.Command,
+		Args:       e.cfg.Args,
+		Env:        e.cfg.Env,
+		InheritEnv: e.cfg.InheritEnv,
+		Dir:        e.cfg.Dir,
+		MaxOutput:  e.cfg.MaxOutput,
+	})
+	res := &ExecResult{
+		ExitCode:  out.ExitCode,
+		Output:    strings.TrimSpace(string(out.StdOutput)),
+		Truncated: out.Truncated,
+		Err:       out.Error(),
+	}
+	if res.Output == "" && res.Err == nil {
+		res.Output = strings.TrimSpace(string(out.StdError))
+	}
+	sa := StatusAction{
+		Time:  now,
+		Name:  e.Name(),
+		Extra: res,
+	}
+	switch {
+	case res.Err != nil:
+		sa.Level = LevelUnknown
+	case res.ExitCode == nagiosOK:
+		sa.Level = LevelOK
+	case res.ExitCode == nagiosWarning:
+		sa.Level = LevelWarn
+	case res.ExitCode == nagiosCritical:
+		sa.Level = LevelCritical
+	default:
+		// 3(unknown)以及其他退出码均视为unknown
+		sa.Level = LevelUnknown
+	}
+	if sa.Level == LevelWarn && e.cfg.WarnIsFailure {
+		sa.Level = LevelCritical
+	}
+	sa.Status = sa.Level == LevelOK || sa.Level == LevelWarn
+	if !sa.Status {
+		execCheckCounter.WithLabelValues("failed").Inc()
+	}
+	return sa
+}
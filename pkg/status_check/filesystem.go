@@ -0,0 +1,254 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"system-usability-detection/internal/procfs"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("filesystem", Factory{
+		Description: "文件系统容量、inode及只读检测",
+		Config:      func() any { return &FilesystemConfig{ReadOnly: LevelCritical} },
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return &FilesystemImpl{name: name, cfg: *cfg.(*FilesystemConfig)}, nil
+		},
+	})
+}
+
+// FilesystemConfig 可用空间低于warn阈值时告警，低于fail阈值时检测失败，阈值为0表示不检查
+//
+//   - type: filesystem
+//     paths: [/export/share1]
+//     warn: {free_percent: 10}
+//     fail: {free_bytes: 1GiB, free_inodes: 1000}
+type FilesystemConfig struct {
+	Paths []string            `mapstructure:"paths"`
+	Warn  FilesystemThreshold `mapstructure:"warn"`
+	Fail  FilesystemThreshold `mapstructure:"fail"`
+	// 文件系统被remount为只读时的级别: critical、warn、ok(忽略)
+	ReadOnly string `mapstructure:"read_only"`
+}
+
+type FilesystemThreshold struct {
+	// 非root用户可用的字节数
+	FreeBytes   util.ByteSize `mapstructure:"free_bytes"`
+	FreePercent float64       `mapstructure:"free_percent"`
+	FreeInodes  uint64        `mapstructure:"free_inodes"`
+}
+
+func (c *FilesystemConfig) Validate() error {
+	if len(c.Paths) == 0 {
+		return errors.New("paths are required")
+	}
+	for _, p := range c.Paths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("path %q must be absolute", p)
+		}
+	}
+	switch c.ReadOnly {
+	case LevelCritical, LevelWarn, LevelOK:
+	default:
+		return fmt.Errorf("read_only must be one of critical, warn, ok, got %q", c.ReadOnly)
+	}
+	for _, t := range []FilesystemThreshold{c.Warn, c.Fail} {
+		if t.FreePercent < 0 || t.FreePercent > 100 {
+			return fmt.Errorf("free_percent %v out of range 0-100", t.FreePercent)
+		}
+	}
+	return nil
+}
+
+var (
+	filesystemCheckCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "filesystem",
+			Name:      "filesystem_check_updates",
+			Help:      "Counter of filesystem updates.",
+		}, []string{"type"}) // 文件系统检测计数counter
+
+	filesystemFreeBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "filesystem",
+			Name:      "free_bytes",
+			Help:      "Bytes available to unprivileged users.",
+		}, []string{"path"})
+
+	filesystemFreeInodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "filesystem",
+			Name:      "free_inodes",
+			Help:      "Free inodes.",
+		}, []string{"path"})
+
+	filesystemReadOnly = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "filesystem",
+			Name:      "read_only",
+			Help:      "Whether the filesystem is mounted read-only.",
+		}, []string{"path"})
+)
+
+var _ StatusInterface = (*FilesystemImpl)(nil)
+
+type FilesystemImpl struct {
+	name string
+	cfg  FilesystemConfig
+}
+
+// FilesystemResult 单个路径的检测结果
+type FilesystemResult struct {
+	Path        string
+	MountPoint  string
+	FreeBytes   util.ByteSize
+	FreePercent float64
+	FreeInodes  uint64
+	ReadOnly    bool
+	Level       string
+	// 超出阈值的原因
+	Reasons []string
+	Err     error
+}
+
+func (r *FilesystemResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s: %v", r.Path, r.Err)
+	}
+	mode := "rw"
+	if r.ReadOnly {
+		mode = "ro"
+	}
+	s := fmt.Sprintf("%s: free=%v(%.1f%%) inodes=%d %s", r.Path, r.FreeBytes, r.FreePercent, r.FreeInodes, mode)
+	if len(r.Reasons) > 0 {
+		s += fmt.Sprintf(" [%s: %s]", r.Level, strings.Join(r.Reasons, ", "))
+	}
+	return s
+}
+
+// FilesystemResults 所有路径的检测结果，作为StatusAction.Extra
+type FilesystemResults []*FilesystemResult
+
+func (r FilesystemResults) String() string {
+	s := make([]string, 0, len(r))
+	for _, p := range r {
+		s = append(s, p.String())
+	}
+	return strings.Join(s, "; ")
+}
+
+func (f *FilesystemImpl) Name() string {
+	return f.name
+}
+
+func (f *FilesystemImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{filesystemCheckCounter, filesystemFreeBytes, filesystemFreeInodes, filesystemReadOnly}
+}
+
+func (f *FilesystemImpl) CheckStatus(context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", f.Name(), used)
+	}()
+	filesystemCheckCounter.WithLabelValues("total").Inc()
+	sa := StatusAction{
+		Time:  now,
+		Name:  f.Name(),
+		Level: LevelOK,
+	}
+	mounts, err := procfs.Mounts()
+	if err != nil {
+		// 只读检测不可用，容量检测照常进行
+		util.Logger.Warn("check %s read mountinfo failed:%v", f.Name(), err)
+	}
+	res := make(FilesystemResults, 0, len(f.cfg.Paths))
+	for _, path := range f.cfg.Paths {
+		r := f.check(path, mounts)
+		res = append(res, r)
+		sa.Level = worseLevel(sa.Level, r.Level)
+	}
+	sa.Extra = res
+	sa.Status = sa.Level == LevelOK || sa.Level == LevelWarn
+	if !sa.Status {
+		filesystemCheckCounter.WithLabelValues("failed").Inc()
+	}
+	return sa
+}
+
+// check statfs可能在异常的网络文件系统上阻塞，由检测超时兜底
+func (f *FilesystemImpl) check(path string, mounts []*procfs.Mount) *FilesystemResult {
+	r := &FilesystemResult{Path: path, Level: LevelOK}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		r.Err = err
+		r.Level = LevelCritical
+		return r
+	}
+	bsize := uint64(st.Bsize)
+	r.FreeBytes = util.ByteSize(st.Bavail * bsize)
+	if st.Blocks > 0 {
+		r.FreePercent = float64(st.Bavail) / float64(st.Blocks) * 100
+	} else {
+		r.FreePercent = 100
+	}
+	r.FreeInodes = st.Ffree
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if m := procfs.FindMount(mounts, path); m != nil {
+		r.MountPoint = m.MountPoint
+		r.ReadOnly = m.ReadOnly()
+	}
+	filesystemFreeBytes.WithLabelValues(r.Path).Set(float64(r.FreeBytes))
+	filesystemFreeInodes.WithLabelValues(r.Path).Set(float64(r.FreeInodes))
+	filesystemReadOnly.WithLabelValues(r.Path).Set(boolFloat(r.ReadOnly))
+
+	if r.ReadOnly && f.cfg.ReadOnly != LevelOK {
+		r.Reasons = append(r.Reasons, "read-only mount "+r.MountPoint)
+		r.Level = worseLevel(r.Level, f.cfg.ReadOnly)
+	}
+	// 没有inode概念的文件系统(如btrfs)Files为0，不检查inode
+	for _, t := range []struct {
+		level string
+		th    FilesystemThreshold
+	}{{LevelCritical, f.cfg.Fail}, {LevelWarn, f.cfg.Warn}} {
+		var reasons []string
+		if t.th.FreeBytes > 0 && r.FreeBytes < t.th.FreeBytes {
+			reasons = append(reasons, fmt.Sprintf("free %v < %v", r.FreeBytes, t.th.FreeBytes))
+		}
+		if t.th.FreePercent > 0 && r.FreePercent < t.th.FreePercent {
+			reasons = append(reasons, fmt.Sprintf("free %.1f%% < %v%%", r.FreePercent, t.th.FreePercent))
+		}
+		if t.th.FreeInodes > 0 && st.Files > 0 && r.FreeInodes < t.th.FreeInodes {
+			reasons = append(reasons, fmt.Sprintf("free inodes %d < %d", r.FreeInodes, t.th.FreeInodes))
+		}
+		if len(reasons) > 0 {
+			r.Reasons = append(r.Reasons, reasons...)
+			r.Level = worseLevel(r.Level, t.level)
+			// 达到fail阈值时不再重复报告warn
+			break
+		}
+	}
+	return r
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
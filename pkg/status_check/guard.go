@@ -0,0 +1,96 @@
+package status_check
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"system-usability-detection/pkg/metrics"
+)
+
+// DefaultCheckTimeout 单个检测模块默认超时时间，需小于检测周期
+const DefaultCheckTimeout = 4 * time.Second
+
+// guardedCheck 限制单次检测时长，上一次检测未返回时不再启动新的检测，避免goroutine堆积
+// 同时统一设置检测结果的模块名称并记录通用指标
+type guardedCheck struct {
+	StatusInterface
+	name, typ string
+	timeout   time.Duration
+	running   atomic.Bool
+}
+
+// WithTimeout 为检测模块增加超时控制，超时的检测单独标记为失败
+func WithTimeout(si StatusInterface, timeout time.Duration) StatusInterface {
+	if g, ok := si.(*guardedCheck); ok {
+		return newGuardedCheck(g.name, g.typ, g.StatusInterface, timeout)
+	}
+	return newGuardedCheck(si.Name(), si.Name(), si, timeout)
+}
+
+func newGuardedCheck(name, typ string, si StatusInterface, timeout time.Duration) *guardedCheck {
+	if timeout <= 0 {
+		timeout = DefaultCheckTimeout
+	}
+	return &guardedCheck{StatusInterface: si, name: name, typ: typ, timeout: timeout}
+}
+
+// Unwrap 获取被包装的检测模块
+func Unwrap(si StatusInterface) StatusInterface {
+	if g, ok := si.(*guardedCheck); ok {
+		return g.StatusInterface
+	}
+	return si
+}
+
+func (g *guardedCheck) Name() string {
+	return g.name
+}
+
+// Type 检测类型
+func (g *guardedCheck) Type() string {
+	return g.typ
+}
+
+func (g *guardedCheck) CheckStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	sa := g.checkStatus(ctx)
+	sa.Name = g.name
+	metrics.CheckCounter.WithLabelValues(g.name, g.typ, strconv.FormatBool(sa.Status)).Inc()
+	metrics.CheckDuration.WithLabelValues(g.name, g.typ).Observe(time.Since(now).Seconds())
+	return sa
+}
+
+func (g *guardedCheck) checkStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	if !g.running.CompareAndSwap(false, true) {
+		return StatusAction{
+			Time:     now,
+			Name:     g.Name(),
+			Status:   false,
+			TimedOut: true,
+			Extra:    fmt.Sprintf("check %s is still running since a previous round", g.Name()),
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	result := make(chan StatusAction, 1)
+	go func() {
+		defer g.running.Store(false)
+		result <- g.StatusInterface.CheckStatus(ctx)
+	}()
+	select {
+	case sa := <-result:
+		return sa
+	case <-ctx.Done():
+		return StatusAction{
+			Time:     now,
+			Name:     g.Name(),
+			Status:   false,
+			TimedOut: true,
+			Extra:    fmt.Sprintf("check %s timeout after %v: %v", g.Name(), g.timeout, ctx.Err()),
+		}
+	}
+}
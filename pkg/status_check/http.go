@@ -0,0 +1,400 @@
+package status_check
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerHTTP("http", "http接口检测，支持状态码、响应体正则和json字段断言", HTTPConfig{}, httpCheckCounter)
+}
+
+// registerHTTP 注册基于http检测的类型，defaults为该类型的默认配置，可被配置覆盖
+func registerHTTP(typ, description string, defaults HTTPConfig, counter *prometheus.CounterVec) {
+	Register(typ, Factory{
+		Description: description,
+		Config: func() any {
+			c := defaults
+			if c.Method == "" {
+				c.Method = http.MethodGet
+			}
+			if len(c.ExpectedStatus) == 0 {
+				c.ExpectedStatus = []int{http.StatusOK}
+			}
+			if c.SuccessThreshold == 0 {
+				c.SuccessThreshold = 1
+			}
+			if c.FailureThreshold == 0 {
+				c.FailureThreshold = 1
+			}
+			return &c
+		},
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return NewHTTPCheck(name, cfg.(*HTTPConfig), counter)
+		},
+	})
+}
+
+// maxHTTPBody 响应体最多读取的字节数，用于正则和json断言
+const maxHTTPBody = 1 << 20
+
+type HTTPConfig struct {
+	URL     string            `mapstructure:"url"`
+	Method  string            `mapstructure:"method"`
+	Headers map[string]string `mapstructure:"headers"`
+	// 请求体
+	Body string        `mapstructure:"body"`
+	TLS  HTTPTLSConfig `mapstructure:"tls"`
+	// 期望的状态码，默认200
+	ExpectedStatus []int `mapstructure:"expected_status"`
+	// 响应体需匹配的正则
+	BodyRegex string `mapstructure:"body_regex"`
+	// 响应体为json时对字段的断言
+	JSON []JSONAssertion `mapstructure:"json"`
+	// 连续成功次数达到该值时恢复正常
+	SuccessThreshold int `mapstructure:"success_threshold"`
+	// 连续失败次数达到该值时标记不可用
+	FailureThreshold int `mapstructure:"failure_threshold"`
+}
+
+type HTTPTLSConfig struct {
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	ServerName         string `mapstructure:"server_name"`
+}
+
+// JSONAssertion json字段断言，Equals和Regex都为空时只要求字段存在
+//
+//	json:
+//	  - path: data.nodes[0].state
+//	    equals: running
+type JSONAssertion struct {
+	// 以.分隔的字段路径，数组下标写作[n]
+	Path   string `mapstructure:"path"`
+	Equals any    `mapstructure:"equals"`
+	Regex  string `mapstructure:"regex"`
+}
+
+func (c *HTTPConfig) Validate() error {
+	if !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		return fmt.Errorf("invalid url %q", c.URL)
+	}
+	if c.SuccessThreshold <= 0 || c.FailureThreshold <= 0 {
+		return errors.New("success_threshold and failure_threshold must be greater than 0")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("tls cert_file and key_file must be set together")
+	}
+	if _, err := regexp.Compile(c.BodyRegex); err != nil {
+		return fmt.Errorf("invalid body_regex: %w", err)
+	}
+	for _, a := range c.JSON {
+		if _, err := parseJSONPath(a.Path); err != nil {
+			return err
+		}
+		if _, err := regexp.Compile(a.Regex); err != nil {
+			return fmt.Errorf("invalid regex of json path %s: %w", a.Path, err)
+		}
+	}
+	return nil
+}
+
+var httpCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "http",
+		Name:      "http_check_updates",
+		Help:      "Counter of http updates.",
+	}, []string{"type"}) // http检测计数counter
+
+var _ StatusInterface = (*HTTPImpl)(nil)
+
+// HTTPImpl http接口检测，客户端在创建时生成并复用连接
+type HTTPImpl struct {
+	name      string
+	cfg       HTTPConfig
+	client    *http.Client
+	bodyRegex *regexp.Regexp
+	json      []jsonAssertion
+	counter   *prometheus.CounterVec
+
+	lock      sync.Mutex
+	healthy   bool
+	successes int
+	failures  int
+}
+
+type jsonAssertion struct {
+	JSONAssertion
+	path  []any
+	regex *regexp.Regexp
+}
+
+// HTTPResult http检测结果
+type HTTPResult struct {
+	Code    int
+	Latency time.Duration
+	Err     error
+	// 当前连续成功、失败次数
+	Successes, Failures int
+}
+
+func (r *HTTPResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%v (failures=%d)", r.Err, r.Failures)
+	}
+	return fmt.Sprintf("code=%d latency=%v (successes=%d)", r.Code, r.Latency.Round(time.Microsecond), r.Successes)
+}
+
+// NewHTTPCheck 创建http检测，counter为nil时使用http类型的计数器
+func NewHTTPCheck(name string, cfg *HTTPConfig, counter *prometheus.CounterVec) (*HTTPImpl, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := cfg.TLS.load()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	h := &HTTPImpl{
+		name: name,
+		cfg:  *cfg,
+		// 超时由检测的ctx控制
+		client:  &http.Client{Transport: transport},
+		counter: counter,
+		healthy: true,
+	}
+	if h.counter == nil {
+		h.counter = httpCheckCounter
+	}
+	if cfg.BodyRegex != "" {
+		h.bodyRegex = regexp.MustCompile(cfg.BodyRegex)
+	}
+	for _, a := range cfg.JSON {
+		ja := jsonAssertion{JSONAssertion: a}
+		ja.path, _ = parseJSONPath(a.Path)
+		if a.Regex != "" {
+			ja.regex = regexp.MustCompile(a.Regex)
+		}
+		h.json = append(h.json, ja)
+	}
+	return h, nil
+}
+
+func (c *HTTPTLSConfig) load() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		ServerName:         c.ServerName,
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", c.CAFile)
+		}
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func (h *HTTPImpl) Name() string {
+	return h.name
+}
+
+func (h *HTTPImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{h.counter}
+}
+
+func (h *HTTPImpl) CheckStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", h.Name(), used)
+	}()
+	h.counter.WithLabelValues("total").Inc()
+	res := h.probe(ctx)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if res.Err == nil {
+		h.successes++
+		h.failures = 0
+		if h.successes >= h.cfg.SuccessThreshold {
+			h.healthy = true
+		}
+	} else {
+		h.failures++
+		h.successes = 0
+		if h.failures >= h.cfg.FailureThreshold {
+			h.healthy = false
+		}
+	}
+	res.Successes, res.Failures = h.successes, h.failures
+	if !h.healthy {
+		h.counter.WithLabelValues("failed").Inc()
+	}
+	return StatusAction{
+		Time:   now,
+		Name:   h.Name(),
+		Status: h.healthy,
+		Extra:  res,
+	}
+}
+
+// probe 发送一次请求并校验响应
+func (h *HTTPImpl) probe(ctx context.Context) *HTTPResult {
+	res := &HTTPResult{}
+	start := time.Now()
+	var body io.Reader
+	if h.cfg.Body != "" {
+		body = strings.NewReader(h.cfg.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, h.cfg.Method, h.cfg.URL, body)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	for k, v := range h.cfg.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	// 无论状态码如何都读完并关闭响应体，以便复用连接
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	_, _ = io.Copy(io.Discard, resp.Body)
+	res.Code = resp.StatusCode
+	res.Latency = time.Since(start)
+	if err != nil {
+		res.Err = fmt.Errorf("read body: %w", err)
+		return res
+	}
+	if !slices.Contains(h.cfg.ExpectedStatus, resp.StatusCode) {
+		res.Err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		return res
+	}
+	if h.bodyRegex != nil && !h.bodyRegex.Match(data) {
+		res.Err = fmt.Errorf("body does not match %q", h.cfg.BodyRegex)
+		return res
+	}
+	if len(h.json) > 0 {
+		res.Err = h.assertJSON(data)
+	}
+	return res
+}
+
+func (h *HTTPImpl) assertJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// 数字按原样比较
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("decode json body: %w", err)
+	}
+	for _, a := range h.json {
+		v, ok := lookupJSON(doc, a.path)
+		if !ok {
+			return fmt.Errorf("json path %s not found", a.Path)
+		}
+		s := fmt.Sprint(v)
+		if a.Equals != nil && s != fmt.Sprint(a.Equals) {
+			return fmt.Errorf("json path %s is %q, want %q", a.Path, s, fmt.Sprint(a.Equals))
+		}
+		if a.regex != nil && !a.regex.MatchString(s) {
+			return fmt.Errorf("json path %s is %q, does not match %q", a.Path, s, a.Regex)
+		}
+	}
+	return nil
+}
+
+// parseJSONPath 解析a.b[0].c为[a b 0 c]，字段为string，下标为int
+func parseJSONPath(path string) ([]any, error) {
+	if path == "" {
+		return nil, errors.New("empty json path")
+	}
+	var ret []any
+	for _, part := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key != "" {
+			ret = append(ret, key)
+		} else if rest == "" {
+			return nil, fmt.Errorf("invalid json path %q", path)
+		}
+		for rest != "" {
+			idx, after, ok := strings.Cut(rest, "]")
+			n, err := strconv.Atoi(idx)
+			if !ok || err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid json path %q", path)
+			}
+			ret = append(ret, n)
+			if after == "" {
+				break
+			}
+			if !strings.HasPrefix(after, "[") {
+				return nil, fmt.Errorf("invalid json path %q", path)
+			}
+			rest = after[1:]
+		}
+	}
+	return ret, nil
+}
+
+func lookupJSON(doc any, path []any) (any, bool) {
+	for _, p := range path {
+		switch k := p.(type) {
+		case string:
+			m, ok := doc.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = m[k]; !ok {
+				return nil, false
+			}
+		case int:
+			a, ok := doc.([]any)
+			if !ok || k >= len(a) {
+				return nil, false
+			}
+			doc = a[k]
+		}
+	}
+	return doc, true
+}
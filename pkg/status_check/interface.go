@@ -1,134 +1,52 @@
 package status_check
 
 import (
-	"os"
-	"strconv"
-	"strings"
-	"system-usability-detection/internal/config"
-	"system-usability-detection/internal/util"
-	"system-usability-detection/pkg/metrics"
+	"context"
 	"time"
 )
 
-type StatusAction struct {
-	Time   time.Time
-	Name   string // 模块名称
-	Status bool
-	Extra  interface{} // 预留字段
-}
-
-// StatusInterface 状态检查接口
-type StatusInterface interface {
-	CheckStatus() StatusAction
-	Name() string
-}
-
-// DefaultCheckModule 默认检测模块
-var DefaultCheckModule = []StatusInterface{
-	&FrontInterface{},
-}
-
-func GetAllSupportType() []string {
-	var support []string
-	for k := range GlobalMapping {
-		support = append(support, k)
-	}
-	return support
-}
-
-// GlobalMapping 每新增一个检测模块,需要在这里添加映射
-var GlobalMapping = map[string]StatusInterface{
-	"nas":         &NasImpl{Address: "http://localhost:9999/api/status"}, // nas服务健康状态检测
-	"nfs":         &NFSImpl{},                                            // nfsd服务健康状态检测
-	"power_cache": &PowerCacheImpl{MountPoint: "/var/powercache"},        // powercache服务健康状态检测
-	"service":     &OSSImpl{},                                            // service服务健康状态检测
-	"samba":       &SambaImpl{},                                          // smbd服务健康状态检测
-}
-
-// 取globalMapping交集
-func getValidCheck(a []string) []string {
-	var ret []string
-	for _, s := range a {
-		if _, ok := GlobalMapping[s]; ok {
-			ret = append(ret, s)
-		}
-	}
-	return ret
-}
-
-// 编译检查
-var _ StatusInterface = (*KeepAlivedCheckImpl)(nil)
+// 检测结果级别，与nagios插件的ok/warning/critical/unknown对应
+const (
+	LevelOK       = "ok"
+	LevelWarn     = "warn"
+	LevelCritical = "critical"
+	LevelUnknown  = "unknown"
+)
 
-// KeepAlivedCheckImpl 检查keepalived服务状态的实现
-type KeepAlivedCheckImpl struct {
-	PidFile string
-}
+var levelOrder = map[string]int{LevelOK: 0, LevelWarn: 1, LevelUnknown: 2, LevelCritical: 3}
 
-// NewKeepAlivedCheckImpl 添加聚合方式
-func NewKeepAlivedCheckImpl(pidFile string) StatusInterface {
-	if pidFile == "" {
-		pidFile = "/var/run/keepalived.pid"
-	}
-	return &KeepAlivedCheckImpl{
-		PidFile: pidFile,
+// worseLevel 返回更严重的级别
+func worseLevel(a, b string) string {
+	if levelOrder[b] > levelOrder[a] {
+		return b
 	}
+	return a
 }
 
-// Name 那个模块的检测机制,这里对应模块名称
-func (k *KeepAlivedCheckImpl) Name() string {
-	return "keepalived"
+type StatusAction struct {
+	Time     time.Time
+	Name     string      // 模块名称
+	Status   bool        // 为false时本节点不再作为master，warn级别仍为true
+	Level    string      // 为空时由Status决定，见Severity
+	TimedOut bool        // 检测超时或上一次检测尚未返回
+	Extra    interface{} // 预留字段
 }
 
-func (k *KeepAlivedCheckImpl) CheckStatus() StatusAction {
-	sa := StatusAction{
-		Time:   time.Now(),
-		Name:   k.Name(),
-		Status: false,
-	}
-	data, err := os.ReadFile(k.PidFile)
-	if err != nil {
-		sa.Extra = err
-		return sa
+// Severity 检测结果级别，未设置Level时正常为ok，失败为critical
+func (s *StatusAction) Severity() string {
+	if s.Level != "" {
+		return s.Level
 	}
-	pid, err := strconv.Atoi(strings.TrimSuffix(string(data), "\n"))
-	if err != nil {
-		sa.Extra = err
-		return sa
+	if s.Status {
+		return LevelOK
 	}
-	_, err = os.FindProcess(pid)
-	if err != nil {
-		sa.Extra = err
-		return sa
-	}
-	sa.Status = true
-	return sa
+	return LevelCritical
 }
 
-type FrontInterface struct{}
-
-func (f *FrontInterface) Name() string {
-	return "front_interface"
-}
-
-func (f *FrontInterface) CheckStatus() StatusAction {
-	now := time.Now()
-	defer func() {
-		used := time.Since(now)
-		util.Logger.Info("check %s used:%v", f.Name(), used)
-	}()
-	metrics.FrontInterfaceCheckCounter.WithLabelValues("total").Inc()
-	sa := StatusAction{
-		Time:   time.Now(),
-		Name:   f.Name(),
-		Status: false,
-	}
-	_, err := util.IsInterfaceDown(config.GlobalConfigInstance.VrrpNetInterface)
-	if err != nil {
-		metrics.FrontInterfaceCheckCounter.WithLabelValues("failed").Inc()
-		sa.Extra = err.Error()
-		return sa
-	}
-	sa.Status = true
-	return sa
-
+// StatusInterface 状态检查接口，CheckStatus需在ctx结束后尽快返回
+//
+// 新增检测模块时在模块文件的init中调用Register注册类型，服务端不感知具体类型
+type StatusInterface interface {
+	CheckStatus(ctx context.Context) StatusAction
+	Name() string
 }
@@ -0,0 +1,251 @@
+package status_check
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("io_probe", Factory{
+		Description: "存储写入读回延迟检测",
+		Config: func() any {
+			return &IOProbeConfig{
+				Fsync:      true,
+				Size:       directAlign,
+				Window:     5 * time.Minute,
+				MinSamples: 10,
+			}
+		},
+		New: func(name string, cfg any, env *Env) (StatusInterface, error) {
+			c := cfg.(*IOProbeConfig)
+			return &IOProbeImpl{
+				name: name,
+				cfg:  *c,
+				file: filepath.Join(c.Path, ".io_probe_"+env.NodeName),
+			}, nil
+		},
+	})
+}
+
+// O_DIRECT要求缓冲区地址、长度和偏移按逻辑块大小对齐，这里统一按4096对齐
+const directAlign = 4096
+
+// IOProbeConfig 每次检测在Path目录下写入随机内容并读回校验，
+// 单次失败立即判定失败，窗口内p99延迟超过MaxP99时也判定失败
+//
+//   - type: io_probe
+//     path: /var/powercache
+//     direct: true
+//     max_p99: 500ms
+type IOProbeConfig struct {
+	// 探测文件所在目录
+	Path string `mapstructure:"path"`
+	// 使用O_DIRECT绕过页缓存，tmpfs等文件系统不支持
+	Direct bool `mapstructure:"direct"`
+	// 写入后fsync
+	Fsync bool `mapstructure:"fsync"`
+	// 每次写入的字节数，使用O_DIRECT时需为4096的整数倍
+	Size util.ByteSize `mapstructure:"size"`
+	// 统计p99的时间窗口
+	Window time.Duration `mapstructure:"window"`
+	// 窗口内p99延迟上限，为0时不检查
+	MaxP99 time.Duration `mapstructure:"max_p99"`
+	// 窗口内样本数不少于该值时才检查p99
+	MinSamples int `mapstructure:"min_samples"`
+}
+
+func (c *IOProbeConfig) Validate() error {
+	if !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path %q must be absolute", c.Path)
+	}
+	if c.Size < 32 || c.Size > 16<<20 {
+		return fmt.Errorf("size %v out of range 32B-16MiB", c.Size)
+	}
+	if c.Direct && c.Size%directAlign != 0 {
+		return fmt.Errorf("size must be a multiple of %d with direct", directAlign)
+	}
+	if c.Window <= 0 || c.MinSamples <= 0 || c.MaxP99 < 0 {
+		return errors.New("window and min_samples must be greater than 0, max_p99 must not be negative")
+	}
+	return nil
+}
+
+var (
+	ioProbeCheckCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "io_probe",
+			Name:      "io_probe_check_updates",
+			Help:      "Counter of io_probe updates.",
+		}, []string{"type"}) // 存储探测计数counter
+
+	ioProbeLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "io_probe",
+			Name:      "latency_seconds",
+			Help:      "Bucketed histogram of write, sync and read-back latency.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 18),
+		}, []string{"name"}) // 存储探测延迟histogram
+)
+
+var _ StatusInterface = (*IOProbeImpl)(nil)
+
+type IOProbeImpl struct {
+	name string
+	cfg  IOProbeConfig
+	file string
+
+	lock    sync.Mutex
+	samples []ioSample
+}
+
+type ioSample struct {
+	time    time.Time
+	latency time.Duration
+}
+
+// IOProbeResult 存储探测结果
+type IOProbeResult struct {
+	Latency time.Duration
+	P99     time.Duration
+	Samples int
+	Err     error
+}
+
+func (r *IOProbeResult) String() string {
+	s := fmt.Sprintf("latency=%v p99=%v samples=%d", r.Latency.Round(time.Microsecond), r.P99.Round(time.Microsecond), r.Samples)
+	if r.Err != nil {
+		s = r.Err.Error() + " " + s
+	}
+	return s
+}
+
+func (p *IOProbeImpl) Name() string {
+	return p.name
+}
+
+func (p *IOProbeImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{ioProbeCheckCounter, ioProbeLatency}
+}
+
+func (p *IOProbeImpl) CheckStatus(context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", p.Name(), used)
+	}()
+	ioProbeCheckCounter.WithLabelValues("total").Inc()
+	sa := StatusAction{
+		Time:   now,
+		Name:   p.Name(),
+		Status: false,
+	}
+	res := &IOProbeResult{}
+	sa.Extra = res
+	res.Latency, res.Err = ioProbe(p.file, int(p.cfg.Size), p.cfg.Direct, p.cfg.Fsync)
+	if res.Err == nil {
+		ioProbeLatency.WithLabelValues(p.name).Observe(res.Latency.Seconds())
+	}
+	res.P99, res.Samples = p.record(now, res.Latency, res.Err == nil)
+	if res.Err == nil && p.cfg.MaxP99 > 0 && res.Samples >= p.cfg.MinSamples && res.P99 > p.cfg.MaxP99 {
+		res.Err = fmt.Errorf("p99 latency over %v exceeds %v", p.cfg.Window, p.cfg.MaxP99)
+	}
+	if res.Err != nil {
+		ioProbeCheckCounter.WithLabelValues("failed").Inc()
+		return sa
+	}
+	sa.Status = true
+	return sa
+}
+
+// record 记录样本并返回窗口内的p99延迟和样本数，失败的探测不计入
+func (p *IOProbeImpl) record(now time.Time, latency time.Duration, ok bool) (time.Duration, int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if ok {
+		p.samples = append(p.samples, ioSample{time: now, latency: latency})
+	}
+	expired := 0
+	for expired < len(p.samples) && now.Sub(p.samples[expired].time) > p.cfg.Window {
+		expired++
+	}
+	p.samples = slices.Delete(p.samples, 0, expired)
+	if len(p.samples) == 0 {
+		return 0, 0
+	}
+	latencies := make([]time.Duration, len(p.samples))
+	for i, s := range p.samples {
+		latencies[i] = s.latency
+	}
+	slices.Sort(latencies)
+	idx := int(math.Ceil(float64(len(latencies))*0.99)) - 1
+	return latencies[idx], len(latencies)
+}
+
+// ioProbe 写入随机内容，可选fsync，再读回校验，返回整个过程的耗时
+func ioProbe(path string, size int, direct, fsync bool) (time.Duration, error) {
+	start := time.Now()
+	flag := os.O_RDWR | os.O_CREATE
+	if direct {
+		flag |= syscall.O_DIRECT
+	}
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	buf := alignedBuffer(size)
+	// 内容为nonce和时间戳，不足的部分重复填充
+	line := []byte(hex.EncodeToString(nonce) + " " + start.Format(time.RFC3339Nano) + "\n")
+	for i := 0; i < size; {
+		i += copy(buf[i:], line)
+	}
+	if _, err := f.WriteAt(buf, 0); err != nil {
+		return 0, fmt.Errorf("write: %w", err)
+	}
+	if fsync {
+		if err := f.Sync(); err != nil {
+			return 0, fmt.Errorf("fsync: %w", err)
+		}
+	}
+	read := alignedBuffer(size)
+	if _, err := f.ReadAt(read, 0); err != nil {
+		return 0, fmt.Errorf("read back: %w", err)
+	}
+	if !bytes.Equal(buf, read) {
+		return 0, errors.New("read back content mismatch")
+	}
+	return time.Since(start), nil
+}
+
+// alignedBuffer 分配按directAlign对齐的缓冲区
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+directAlign)
+	off := 0
+	if r := int(uintptr(unsafe.Pointer(&buf[0])) & (directAlign - 1)); r != 0 {
+		off = directAlign - r
+	}
+	return buf[off : off+size]
+}
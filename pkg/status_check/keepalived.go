@@ -0,0 +1,19 @@
+package status_check
+
+func init() {
+	// pid文件中的进程必须存活且为keepalived，避免pid被其他进程复用
+	Register("keepalived", Factory{
+		Description: "keepalived服务状态检测",
+		Config: func() any {
+			return &ProcessConfig{Comm: "keepalived", Min: 1}
+		},
+		New: func(name string, cfg any, env *Env) (StatusInterface, error) {
+			c := cfg.(*ProcessConfig)
+			// 未单独配置pid_file时使用keepalived.pid_file，与状态监听读取同一个文件
+			if c.PidFile == "" && env != nil {
+				c.PidFile = env.KeepalivedPidFile
+			}
+			return NewProcessCheck(name, c, nil)
+		},
+	})
+}
@@ -0,0 +1,16 @@
+package status_check
+
+import "testing"
+
+func TestKeepalivedPidFile(t *testing.T) {
+	env := &Env{KeepalivedPidFile: "/run/keepalived/keepalived.pid"}
+	p := newCheck[*ProcessImpl](t, "keepalived", nil, env)
+	if p.cfg.PidFile != env.KeepalivedPidFile || p.cfg.Comm != "keepalived" {
+		t.Fatalf("unexpected config %+v", p.cfg)
+	}
+	// 单独配置的pid_file优先
+	p = newCheck[*ProcessImpl](t, "keepalived", map[string]any{"pid_file": "/tmp/k.pid"}, env)
+	if p.cfg.PidFile != "/tmp/k.pid" {
+		t.Fatalf("pid_file %q, want /tmp/k.pid", p.cfg.PidFile)
+	}
+}
@@ -0,0 +1,193 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"system-usability-detection/internal/procfs"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("mount", Factory{
+		Description: "挂载点检测，校验文件系统类型、来源、挂载选项并检测nfs句柄失效",
+		Config:      func() any { return &MountConfig{StatTimeout: 2 * time.Second} },
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return &MountImpl{name: name, cfg: *cfg.(*MountConfig)}, nil
+		},
+	})
+}
+
+// MountConfig 挂载点需与mountinfo中的挂载点完全一致，其余条件为空时不检查
+//
+//   - type: mount
+//     path: /export/share1
+//     fstype: [nfs, nfs4]
+//     source: 10.0.0.1:/share1
+//     options: [rw]
+type MountConfig struct {
+	Path string `mapstructure:"path"`
+	// 允许的文件系统类型，任一匹配即可
+	FSType []string `mapstructure:"fstype"`
+	// 挂载来源，如/dev/sdb1、10.0.0.1:/export
+	Source string `mapstructure:"source"`
+	// 必须包含的挂载选项，如rw、name=value
+	Options []string `mapstructure:"options"`
+	// stat挂载点的超时时间，nfs服务端异常时stat会一直阻塞，为0时不stat
+	StatTimeout time.Duration `mapstructure:"stat_timeout"`
+}
+
+func (c *MountConfig) Validate() error {
+	if !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path %q must be absolute", c.Path)
+	}
+	if c.StatTimeout < 0 {
+		return errors.New("stat_timeout must not be negative")
+	}
+	return nil
+}
+
+var mountCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "mount",
+		Name:      "mount_check_updates",
+		Help:      "Counter of mount updates.",
+	}, []string{"type"}) // 挂载点检测计数counter
+
+var _ StatusInterface = (*MountImpl)(nil)
+
+type MountImpl struct {
+	name string
+	cfg  MountConfig
+	// 上一次stat是否尚未返回
+	pending atomic.Bool
+}
+
+// MountResult 挂载点检测结果
+type MountResult struct {
+	MountPoint string
+	FSType     string
+	Source     string
+	Options    string
+	Err        error
+}
+
+func (r *MountResult) String() string {
+	if r.FSType == "" {
+		return fmt.Sprintf("%s: %v", r.MountPoint, r.Err)
+	}
+	s := fmt.Sprintf("%s %s on %s (%s)", r.FSType, r.Source, r.MountPoint, r.Options)
+	if r.Err != nil {
+		s = r.Err.Error() + ": " + s
+	}
+	return s
+}
+
+func (m *MountImpl) Name() string {
+	return m.name
+}
+
+func (m *MountImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{mountCheckCounter}
+}
+
+func (m *MountImpl) CheckStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", m.Name(), used)
+	}()
+	mountCheckCounter.WithLabelValues("total").Inc()
+	res := checkMount(ctx, &m.cfg, &m.pending)
+	sa := StatusAction{
+		Time:   now,
+		Name:   m.Name(),
+		Status: res.Err == nil,
+		Extra:  res,
+	}
+	if !sa.Status {
+		mountCheckCounter.WithLabelValues("failed").Inc()
+	}
+	return sa
+}
+
+// checkMount 按mountinfo校验挂载点，pending用于限制同一挂载点阻塞的stat只有一个
+func checkMount(ctx context.Context, cfg *MountConfig, pending *atomic.Bool) *MountResult {
+	res := &MountResult{MountPoint: filepath.Clean(cfg.Path)}
+	mounts, err := procfs.Mounts()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	var m *procfs.Mount
+	// 同一挂载点被多次挂载时以最后一次为准
+	for _, ele := range mounts {
+		if ele.MountPoint == res.MountPoint {
+			m = ele
+		}
+	}
+	if m == nil {
+		res.Err = errors.New("not mounted")
+		return res
+	}
+	res.FSType, res.Source = m.FSType, m.Source
+	res.Options = strings.Join(m.Options, ",")
+	if len(cfg.FSType) > 0 && !slices.Contains(cfg.FSType, m.FSType) {
+		res.Err = fmt.Errorf("fstype %s, want %s", m.FSType, strings.Join(cfg.FSType, " or "))
+		return res
+	}
+	if cfg.Source != "" && m.Source != cfg.Source {
+		res.Err = fmt.Errorf("source %s, want %s", m.Source, cfg.Source)
+		return res
+	}
+	for _, opt := range cfg.Options {
+		// 超级块只读时挂载点选项仍可能为rw
+		if !m.HasOption(opt) || (opt == "rw" && m.ReadOnly()) {
+			res.Err = fmt.Errorf("missing mount option %s", opt)
+			return res
+		}
+	}
+	if cfg.StatTimeout > 0 {
+		res.Err = boundedStat(ctx, pending, res.MountPoint, cfg.StatTimeout)
+	}
+	return res
+}
+
+// boundedStat 在goroutine中stat，超时后直接返回；阻塞的stat无法取消，
+// 上一次stat仍未返回时不再启动新的goroutine，避免goroutine堆积
+func boundedStat(ctx context.Context, pending *atomic.Bool, path string, timeout time.Duration) error {
+	if !pending.CompareAndSwap(false, true) {
+		return errors.New("previous stat still blocked, mount may be stale or hung")
+	}
+	result := make(chan error, 1)
+	go func() {
+		defer pending.Store(false)
+		_, err := os.Stat(path)
+		result <- err
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		if errors.Is(err, syscall.ESTALE) {
+			return fmt.Errorf("stale file handle: %w", err)
+		}
+		return err
+	case <-timer.C:
+		return fmt.Errorf("stat timed out after %v, mount may be stale or hung", timeout)
+	case <-ctx.Done():
+		return fmt.Errorf("stat canceled: %w", ctx.Err())
+	}
+}
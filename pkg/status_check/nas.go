@@ -1,65 +1,23 @@
 package status_check
 
 import (
-	"errors"
-	"net/http"
-	"time"
-
 	"system-usability-detection/pkg/metrics"
-)
-
-var _ StatusInterface = (*NasImpl)(nil)
 
-// NasImpl nas服务检测
-type NasImpl struct {
-	Address string
-}
-
-func (n *NasImpl) Name() string {
-	return "nas"
-}
-
-func (n *NasImpl) CheckStatus() StatusAction {
-	metrics.NasCheckCounter.WithLabelValues("total").Inc()
-	sa := StatusAction{
-		Time:   time.Now(),
-		Name:   n.Name(),
-		Status: !nasDisable,
-	}
-	if nasDisable && nasErr != nil {
-		sa.Extra = nasErr
-	}
-	return sa
-}
-
-var (
-	nasDisable bool // false
-	nasErr     error
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-func backGroundNasCheck(address string) {
-	var count = 0
-	for {
-		client := &http.Client{
-			Timeout: 5 * time.Second,
-		}
-		resp, err := client.Get(address)
-		if err != nil || resp.StatusCode != http.StatusOK {
-			nasErr = errors.New("check nas failed")
-			count++
-		} else {
-			nasDisable = false
-			nasErr = nil
-			count = 0
-			if resp.Body != nil {
-				resp.Body.Close()
-			}
-		}
-		if count >= 2 {
-			nasDisable = true
-			count = 0
-		}
-		time.Sleep(5 * time.Second)
-	}
-
+func init() {
+	// 连续失败2次标记nas不可用
+	registerHTTP("nas", "nas服务健康状态检测", HTTPConfig{
+		URL:              "http://localhost:9999/api/status",
+		FailureThreshold: 2,
+	}, nasCheckCounter)
 }
+
+var nasCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "nas",
+		Name:      "nas_check_updates",
+		Help:      "Counter of nas updates.",
+	}, []string{"type"}) // nas检测计数counter
@@ -0,0 +1,295 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"system-usability-detection/internal/procfs"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	f := Factory{
+		Description: "业务网卡状态检测，包括网卡状态、carrier抖动、错误和丢包突增、本机ip及bond slave状态",
+		Config: func() any {
+			return &InterfaceConfig{CarrierChanges: 2, Errors: 10, Bond: BondAuto}
+		},
+		New: func(name string, cfg any, env *Env) (StatusInterface, error) {
+			c := *cfg.(*InterfaceConfig)
+			// 未配置网卡时检测vrrp网卡，期望ip默认为注册到etcd的本机ip
+			if c.Interface == "" {
+				c.Interface = env.Interface
+				if c.ExpectedIP == "" {
+					c.ExpectedIP = env.LocalIP
+				}
+			}
+			return &InterfaceImpl{name: name, cfg: c}, nil
+		},
+	}
+	Register("interface", f)
+	// 兼容旧配置
+	f.Description = "同interface，兼容旧配置"
+	Register("front_interface", f)
+}
+
+// bond检测方式
+const (
+	// BondAuto 网卡是bond时检测slave
+	BondAuto = "auto"
+	// BondRequired 网卡必须是bond
+	BondRequired = "required"
+	BondDisabled = "disabled"
+)
+
+type InterfaceConfig struct {
+	// 检测的网卡，默认为vrrp网卡
+	Interface string `mapstructure:"interface"`
+	// 网卡上必须存在的ip，未配置interface时默认为本机ip
+	ExpectedIP string `mapstructure:"expected_ip"`
+	// 相邻两次检测之间计数器增量超过该值时失败，为0时不检测；
+	// carrier_changes为2时允许一次down/up
+	CarrierChanges uint64 `mapstructure:"carrier_changes"`
+	// rx_errors+tx_errors
+	Errors uint64 `mapstructure:"errors"`
+	// rx_dropped+tx_dropped，未知协议等报文也计入rx_dropped，默认不检测
+	Drops uint64 `mapstructure:"drops"`
+	// auto、required、disabled
+	Bond string `mapstructure:"bond"`
+	// up状态的slave少于该值时失败，少于全部slave时告警，为0时至少1个
+	MinSlaves int `mapstructure:"min_slaves"`
+}
+
+func (c *InterfaceConfig) Validate() error {
+	if c.ExpectedIP != "" && net.ParseIP(c.ExpectedIP) == nil {
+		return fmt.Errorf("invalid expected_ip %q", c.ExpectedIP)
+	}
+	if c.Bond != BondAuto && c.Bond != BondRequired && c.Bond != BondDisabled {
+		return fmt.Errorf("unknown bond %q", c.Bond)
+	}
+	if c.MinSlaves < 0 {
+		return errors.New("min_slaves must not be negative")
+	}
+	return nil
+}
+
+var interfaceCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "front_interface",
+		Name:      "front_interface_check_updates",
+		Help:      "Counter of front_interface updates.",
+	}, []string{"type"}) // 业务网卡检测计数counter
+
+var _ StatusInterface = (*InterfaceImpl)(nil)
+
+// sysClassNet 网卡统计信息所在目录
+var sysClassNet = "/sys/class/net"
+
+// interfaceCounters 参与突增检测的计数器
+type interfaceCounters struct {
+	CarrierChanges uint64
+	Errors         uint64
+	Drops          uint64
+}
+
+// InterfaceImpl 业务网卡检测，计数器与上一次检测结果比较，第一次检测只记录不判断
+type InterfaceImpl struct {
+	name string
+	cfg  InterfaceConfig
+
+	lock sync.Mutex
+	prev *interfaceCounters
+}
+
+// InterfaceResult 作为StatusAction.Extra
+type InterfaceResult struct {
+	Interface string
+	// 与上一次检测相比的增量，第一次检测或计数器被重置时为nil
+	Delta   *interfaceCounters
+	Bond    *procfs.Bond
+	Reasons []string
+	Level   string
+}
+
+func (r *InterfaceResult) String() string {
+	s := []string{r.Interface}
+	if r.Delta != nil {
+		s = append(s, fmt.Sprintf("carrier_changes=+%d errors=+%d drops=+%d",
+			r.Delta.CarrierChanges, r.Delta.Errors, r.Delta.Drops))
+	}
+	if r.Bond != nil {
+		up := 0
+		for _, slave := range r.Bond.Slaves {
+			if slave.Up() {
+				up++
+			}
+		}
+		s = append(s, fmt.Sprintf("slaves=%d/%d active=%s", up, len(r.Bond.Slaves), r.Bond.ActiveSlave))
+	}
+	s = append(s, r.Reasons...)
+	return strings.Join(s, " ")
+}
+
+func (f *InterfaceImpl) Name() string {
+	return f.name
+}
+
+func (f *InterfaceImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{interfaceCheckCounter}
+}
+
+func (f *InterfaceImpl) CheckStatus(context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", f.Name(), used)
+	}()
+	interfaceCheckCounter.WithLabelValues("total").Inc()
+	sa := StatusAction{
+		Time:   now,
+		Name:   f.Name(),
+		Status: false,
+	}
+	face, err := util.IsInterfaceDown(f.cfg.Interface)
+	if err != nil {
+		interfaceCheckCounter.WithLabelValues("failed").Inc()
+		sa.Extra = err.Error()
+		return sa
+	}
+	res := &InterfaceResult{Interface: f.cfg.Interface, Level: LevelOK}
+	sa.Extra = res
+	fail := func(level, format string, args ...any) {
+		res.Reasons = append(res.Reasons, fmt.Sprintf(format, args...))
+		res.Level = worseLevel(res.Level, level)
+	}
+	if f.cfg.ExpectedIP != "" {
+		if ok, err := hasIP(face, net.ParseIP(f.cfg.ExpectedIP)); err != nil {
+			fail(LevelCritical, "read addresses: %v", err)
+		} else if !ok {
+			fail(LevelCritical, "ip %s not assigned", f.cfg.ExpectedIP)
+		}
+	}
+	if err := f.checkCounters(res, fail); err != nil {
+		fail(LevelCritical, "read counters: %v", err)
+	}
+	f.checkBond(res, fail)
+	sa.Level = res.Level
+	sa.Status = res.Level != LevelCritical
+	if !sa.Status {
+		interfaceCheckCounter.WithLabelValues("failed").Inc()
+	}
+	return sa
+}
+
+// checkCounters 计数器增量超过阈值时失败，计数器变小说明网卡被重建，重新开始计数
+func (f *InterfaceImpl) checkCounters(res *InterfaceResult, fail func(level, format string, args ...any)) error {
+	cur, err := readInterfaceCounters(f.cfg.Interface)
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	prev := f.prev
+	f.prev = cur
+	f.lock.Unlock()
+	if prev == nil || cur.CarrierChanges < prev.CarrierChanges || cur.Errors < prev.Errors || cur.Drops < prev.Drops {
+		return nil
+	}
+	res.Delta = &interfaceCounters{
+		CarrierChanges: cur.CarrierChanges - prev.CarrierChanges,
+		Errors:         cur.Errors - prev.Errors,
+		Drops:          cur.Drops - prev.Drops,
+	}
+	for _, c := range []struct {
+		name       string
+		delta, max uint64
+	}{
+		{"carrier_changes", res.Delta.CarrierChanges, f.cfg.CarrierChanges},
+		{"errors", res.Delta.Errors, f.cfg.Errors},
+		{"drops", res.Delta.Drops, f.cfg.Drops},
+	} {
+		if c.max > 0 && c.delta > c.max {
+			fail(LevelCritical, "%s +%d > %d", c.name, c.delta, c.max)
+		}
+	}
+	return nil
+}
+
+// checkBond up状态的slave少于min_slaves时失败，部分slave down时告警
+func (f *InterfaceImpl) checkBond(res *InterfaceResult, fail func(level, format string, args ...any)) {
+	if f.cfg.Bond == BondDisabled {
+		return
+	}
+	bond, err := procfs.ReadBond(f.cfg.Interface)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) || f.cfg.Bond == BondRequired {
+			fail(LevelCritical, "read bond: %v", err)
+		}
+		return
+	}
+	res.Bond = bond
+	var down []string
+	for _, slave := range bond.Slaves {
+		if !slave.Up() {
+			down = append(down, slave.Name)
+		}
+	}
+	min := f.cfg.MinSlaves
+	if min == 0 {
+		min = 1
+	}
+	switch up := len(bond.Slaves) - len(down); {
+	case up < min:
+		fail(LevelCritical, "%d slaves up, need %d", up, min)
+	case len(down) > 0:
+		fail(LevelWarn, "slaves down: %s", strings.Join(down, ","))
+	}
+}
+
+func readInterfaceCounters(name string) (*interfaceCounters, error) {
+	c := &interfaceCounters{}
+	for _, v := range []struct {
+		file string
+		dst  *uint64
+	}{
+		{"carrier_changes", &c.CarrierChanges},
+		{"statistics/rx_errors", &c.Errors},
+		{"statistics/tx_errors", &c.Errors},
+		{"statistics/rx_dropped", &c.Drops},
+		{"statistics/tx_dropped", &c.Drops},
+	} {
+		data, err := os.ReadFile(filepath.Join(sysClassNet, name, v.file))
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", v.file, err)
+		}
+		*v.dst += n
+	}
+	return c, nil
+}
+
+func hasIP(face *net.Interface, ip net.IP) (bool, error) {
+	addrs, err := face.Addrs()
+	if err != nil {
+		return false, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
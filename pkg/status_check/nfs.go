@@ -1,61 +1,285 @@
 package status_check
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"system-usability-detection/internal/oncrpc"
+	"system-usability-detection/internal/procfs"
+	"system-usability-detection/internal/util"
 	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("nfs", Factory{
+		Description: "nfs服务检测，向rpcbind、nfsd、mountd发送RPC NULL请求，并检查导出目录和nfsd线程数",
+		Config: func() any {
+			return &NFSConfig{
+				Host:          "127.0.0.1",
+				RPCBindPort:   111,
+				Services:      []string{NFSServiceRPCBind, NFSServiceNFS, NFSServiceMountd},
+				Protocols:     []string{"tcp"},
+				NFSVersion:    3,
+				NFSPort:       2049,
+				MountdVersion: 3,
+				RPCTimeout:    time.Second,
+				Etab:          "/var/lib/nfs/etab",
+				MinThreads:    1,
+			}
+		},
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return &NFSImpl{name: name, cfg: *cfg.(*NFSConfig)}, nil
+		},
+	})
+}
+
+// nfs相关的rpc服务
+const (
+	NFSServiceRPCBind = "rpcbind"
+	NFSServiceNFS     = "nfs"
+	NFSServiceMountd  = "mountd"
+)
+
+type NFSConfig struct {
+	Host        string `mapstructure:"host"`
+	RPCBindPort int    `mapstructure:"rpcbind_port"`
+	// 探测的服务，只提供nfsv4的服务端不需要rpcbind和mountd
+	Services []string `mapstructure:"services"`
+	// tcp、udp，nfsv4不支持udp，nfs-utils 2.x起默认关闭udp，默认只探测tcp
+	Protocols  []string `mapstructure:"protocols"`
+	NFSVersion int      `mapstructure:"nfs_version"`
+	// 为0时通过rpcbind查询
+	NFSPort       int `mapstructure:"nfs_port"`
+	MountdVersion int `mapstructure:"mountd_version"`
+	// 为0时通过rpcbind查询
+	MountdPort int `mapstructure:"mountd_port"`
+	// 单次rpc调用的超时时间
+	RPCTimeout time.Duration `mapstructure:"rpc_timeout"`
+	// 必须处于导出状态的目录
+	Exports []string `mapstructure:"exports"`
+	// /proc/fs/nfsd未挂载时从exportfs维护的etab读取导出状态
+	Etab string `mapstructure:"etab"`
+	// nfsd线程数少于该值时失败，为0时不检查
+	MinThreads int `mapstructure:"min_threads"`
+}
+
+func (c *NFSConfig) Validate() error {
+	for _, s := range c.Services {
+		if s != NFSServiceRPCBind && s != NFSServiceNFS && s != NFSServiceMountd {
+			return fmt.Errorf("unknown service %q", s)
+		}
+	}
+	for _, p := range c.Protocols {
+		if p != "tcp" && p != "udp" {
+			return fmt.Errorf("unknown protocol %q", p)
+		}
+	}
+	if len(c.Services) > 0 && len(c.Protocols) == 0 {
+		return errors.New("protocols are required")
+	}
+	for _, port := range []int{c.RPCBindPort, c.NFSPort, c.MountdPort} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	if c.RPCBindPort == 0 {
+		return errors.New("rpcbind_port is required")
+	}
+	if c.NFSVersion <= 0 || c.MountdVersion <= 0 {
+		return errors.New("nfs_version and mountd_version must be greater than 0")
+	}
+	if c.RPCTimeout <= 0 {
+		return errors.New("rpc_timeout must be greater than 0")
+	}
+	if c.MinThreads < 0 {
+		return errors.New("min_threads must not be negative")
+	}
+	return nil
+}
+
+var (
+	nfsCheckCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "nfs",
+			Name:      "nfs_check_updates",
+			Help:      "Counter of nfs updates.",
+		}, []string{"type"}) // nfs检测计数counter
+
+	nfsThreads = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "nfs",
+			Name:      "threads",
+			Help:      "Number of nfsd threads from /proc/fs/nfsd/threads.",
+		})
 )
 
 var _ StatusInterface = (*NFSImpl)(nil)
 
-// NFSImpl nfs服务检测
+// NFSImpl nfs服务检测，nfsd线程存在但rpc无响应时同样视为失败
 type NFSImpl struct {
+	name string
+	cfg  NFSConfig
+}
+
+// NFSRPCResult 单个服务单个协议的探测结果
+type NFSRPCResult struct {
+	Service string
+	Proto   string
+	Port    int
+	Latency time.Duration
+	Err     error
+}
+
+// NFSResult 作为StatusAction.Extra
+type NFSResult struct {
+	RPC []NFSRPCResult
+	// 未检查时为-1
+	Threads int
+	// 未导出的目录
+	MissingExports []string
+	Reasons        []string
+}
+
+func (r *NFSResult) String() string {
+	var s []string
+	for _, p := range r.RPC {
+		if p.Err != nil {
+			s = append(s, fmt.Sprintf("%s/%s=%v", p.Service, p.Proto, p.Err))
+		} else {
+			s = append(s, fmt.Sprintf("%s/%s:%d=ok(%v)", p.Service, p.Proto, p.Port, p.Latency.Round(time.Microsecond)))
+		}
+	}
+	if r.Threads >= 0 {
+		s = append(s, "threads="+strconv.Itoa(r.Threads))
+	}
+	return strings.Join(append(s, r.Reasons...), " ")
 }
 
 func (n *NFSImpl) Name() string {
-	return "nfs"
+	return n.name
 }
 
-// 缓存PID列表
-var nfsCachePidList = map[int]int{}
+func (n *NFSImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{nfsCheckCounter, nfsThreads}
+}
 
-func (n *NFSImpl) CheckStatus() StatusAction {
+func (n *NFSImpl) CheckStatus(ctx context.Context) StatusAction {
 	now := time.Now()
 	defer func() {
 		used := time.Since(now)
-		logger.Infof("check %s used:%v", n.Name(), used)
+		util.Logger.Info("check %s used:%v", n.Name(), used)
 	}()
-	metrics.NfsCheckCounter.WithLabelValues("total").Inc()
+	nfsCheckCounter.WithLabelValues("total").Inc()
 	sa := StatusAction{
-		Time:   time.Now(),
+		Time:   now,
 		Name:   n.Name(),
 		Status: false,
 	}
-	for k, v := range nfsCachePidList {
-		alived := checkProcessPid(v)
-		if !alived {
-			delete(nfsCachePidList, k)
-			continue
+	res := &NFSResult{Threads: -1, RPC: n.probeAll(ctx)}
+	sa.Extra = res
+	for _, p := range res.RPC {
+		if p.Err != nil {
+			res.Reasons = append(res.Reasons, fmt.Sprintf("%s/%s unavailable", p.Service, p.Proto))
 		}
-		sa.Status = true
-		return sa
 	}
-	result := command.ExecBinBashCmd(5*time.Second, `pgrep nfsd`)
-	if result.HasError() {
-		metrics.NfsCheckCounter.WithLabelValues("failed").Inc()
-		sa.Extra = result.Error()
-		return sa
+	if n.cfg.MinThreads > 0 {
+		threads, err := procfs.NFSThreads()
+		switch {
+		case err != nil:
+			res.Reasons = append(res.Reasons, fmt.Sprintf("read nfsd threads: %v", err))
+		case threads < n.cfg.MinThreads:
+			res.Reasons = append(res.Reasons, fmt.Sprintf("nfsd threads %d < %d", threads, n.cfg.MinThreads))
+		}
+		if err == nil {
+			res.Threads = threads
+			nfsThreads.Set(float64(threads))
+		}
 	}
-	if len(result.StdOutput) == 0 {
-		metrics.NfsCheckCounter.WithLabelValues("failed").Inc()
-		sa.Extra = errors.New("has no nfsd process")
-		return sa
+	if len(n.cfg.Exports) > 0 {
+		if err := n.checkExports(res); err != nil {
+			res.Reasons = append(res.Reasons, fmt.Sprintf("read exports: %v", err))
+		}
 	}
-	split, err := bytesToIntSlice(string(result.StdOutput), "\n")
-	logger.Infof("all nfsd pids:%v message:%v", split, err)
-	for i := range split {
-		nfsCachePidList[i] = split[i]
+	if len(res.Reasons) > 0 {
+		nfsCheckCounter.WithLabelValues("failed").Inc()
+		return sa
 	}
 	sa.Status = true
 	return sa
 }
+
+// probeAll 并发探测所有服务和协议的组合
+func (n *NFSImpl) probeAll(ctx context.Context) []NFSRPCResult {
+	res := make([]NFSRPCResult, 0, len(n.cfg.Services)*len(n.cfg.Protocols))
+	for _, s := range n.cfg.Services {
+		for _, p := range n.cfg.Protocols {
+			res = append(res, NFSRPCResult{Service: s, Proto: p})
+		}
+	}
+	var wg sync.WaitGroup
+	for i := range res {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.probe(ctx, &res[i])
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// probe 确定端口后发送NULL请求，未配置端口的服务通过rpcbind(tcp)查询
+func (n *NFSImpl) probe(ctx context.Context, r *NFSRPCResult) {
+	var prog, vers uint32
+	switch r.Service {
+	case NFSServiceRPCBind:
+		prog, vers, r.Port = oncrpc.ProgPortmap, 2, n.cfg.RPCBindPort
+	case NFSServiceNFS:
+		prog, vers, r.Port = oncrpc.ProgNFS, uint32(n.cfg.NFSVersion), n.cfg.NFSPort
+	case NFSServiceMountd:
+		prog, vers, r.Port = oncrpc.ProgMount, uint32(n.cfg.MountdVersion), n.cfg.MountdPort
+	}
+	if r.Port == 0 {
+		rpcbind := net.JoinHostPort(n.cfg.Host, strconv.Itoa(n.cfg.RPCBindPort))
+		port, err := oncrpc.GetPort(ctx, "tcp", rpcbind, prog, vers, r.Proto, n.cfg.RPCTimeout)
+		if err != nil {
+			r.Err = fmt.Errorf("getport: %w", err)
+			return
+		}
+		r.Port = port
+	}
+	addr := net.JoinHostPort(n.cfg.Host, strconv.Itoa(r.Port))
+	r.Latency, r.Err = oncrpc.Null(ctx, r.Proto, addr, prog, vers, n.cfg.RPCTimeout)
+}
+
+// checkExports 优先读取内核当前生效的导出表，nfsd文件系统未挂载时读取etab
+func (n *NFSImpl) checkExports(res *NFSResult) error {
+	exports, err := procfs.NFSExports()
+	if errors.Is(err, os.ErrNotExist) && n.cfg.Etab != "" {
+		exports, err = procfs.ReadNFSExports(n.cfg.Etab)
+	}
+	if err != nil {
+		return err
+	}
+	for _, path := range n.cfg.Exports {
+		if !slices.ContainsFunc(exports, func(e procfs.NFSExport) bool { return e.Path == path }) {
+			res.MissingExports = append(res.MissingExports, path)
+		}
+	}
+	if len(res.MissingExports) > 0 {
+		res.Reasons = append(res.Reasons, "not exported: "+strings.Join(res.MissingExports, ","))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package status_check
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"system-usability-detection/internal/oncrpc/oncrpctest"
+)
+
+// fakeRPC 在同一端口上监听tcp和udp，对程序号为denied的请求返回MSG_DENIED，其余请求正常应答
+func fakeRPC(t *testing.T, denied uint32) int {
+	return oncrpctest.NewServer(t, 0, func(c oncrpctest.Call) [][]byte {
+		if c.Prog == denied {
+			return [][]byte{oncrpctest.DeniedReply(c.Xid)}
+		}
+		return [][]byte{oncrpctest.AcceptedReply(c.Xid, 0)}
+	}).Port()
+}
+
+// newNFSCheck 不检查nfsd线程数，测试环境没有运行nfsd
+func newNFSCheck(t *testing.T, options map[string]any) *NFSImpl {
+	t.Helper()
+	options["min_threads"] = 0
+	return newCheck[*NFSImpl](t, "nfs", options, nil)
+}
+
+func TestNFSDefaultProtocols(t *testing.T) {
+	n := newNFSCheck(t, map[string]any{})
+	if !slices.Equal(n.cfg.Protocols, []string{"tcp"}) {
+		t.Fatalf("default protocols %v, want [tcp]", n.cfg.Protocols)
+	}
+}
+
+func TestNFSCheck(t *testing.T) {
+	port := fakeRPC(t, 0)
+	n := newNFSCheck(t, map[string]any{
+		"rpcbind_port": port,
+		"nfs_port":     port,
+		"mountd_port":  port,
+		// 与配置文件一样可以写成逗号分隔的字符串
+		"protocols": "tcp,udp",
+	})
+	sa := n.CheckStatus(context.Background())
+	res := sa.Extra.(*NFSResult)
+	if !sa.Status || len(res.RPC) != 6 {
+		t.Fatalf("status=%v: %s", sa.Status, res)
+	}
+}
+
+func TestNFSCheckDenied(t *testing.T) {
+	// mountd拒绝请求，nfs正常
+	port := fakeRPC(t, 100005)
+	n := newNFSCheck(t, map[string]any{
+		"services":     []string{NFSServiceNFS, NFSServiceMountd},
+		"rpcbind_port": port,
+		"nfs_port":     port,
+		"mountd_port":  port,
+		"protocols":    []string{"tcp", "udp"},
+	})
+	sa := n.CheckStatus(context.Background())
+	res := sa.Extra.(*NFSResult)
+	if sa.Status {
+		t.Fatalf("expected failure: %s", res)
+	}
+	want := []string{"mountd/tcp unavailable", "mountd/udp unavailable"}
+	if !slices.Equal(res.Reasons, want) {
+		t.Fatalf("reasons %v, want %v", res.Reasons, want)
+	}
+}
+
+func TestNFSCheckUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	n := newNFSCheck(t, map[string]any{
+		"services":    NFSServiceNFS,
+		"nfs_port":    port,
+		"rpc_timeout": "200ms",
+	})
+	sa := n.CheckStatus(context.Background())
+	res := sa.Extra.(*NFSResult)
+	if sa.Status || res.RPC[0].Err == nil {
+		t.Fatalf("expected failure on closed port %d: %s", port, res)
+	}
+}
@@ -0,0 +1,328 @@
+package status_check
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"system-usability-detection/internal/sigv4"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("oss", Factory{
+		Description: "对象存储服务检测，对bucket或对象发送HEAD/GET请求，可选写入、读取、删除探测对象",
+		Config: func() any {
+			return &OSSConfig{
+				Endpoint:       "http://127.0.0.1",
+				Method:         http.MethodHead,
+				PathStyle:      true,
+				Region:         "us-east-1",
+				ExpectedStatus: []int{http.StatusOK},
+				CanaryPrefix:   ".system-usability-detection/",
+			}
+		},
+		New: func(name string, cfg any, env *Env) (StatusInterface, error) {
+			return NewOSSCheck(name, cfg.(*OSSConfig), env.NodeName)
+		},
+	})
+	// 早期版本以进程检测代替对象存储检测，保留类型名称兼容旧配置
+	Register("service", Factory{
+		Description: "同process，兼容旧配置，对象存储服务请使用oss",
+		Config: func() any {
+			return &ServiceConfig{ProcessConfig: ProcessConfig{Min: 1}}
+		},
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return NewProcessCheck(name, &cfg.(*ServiceConfig).ProcessConfig, processCheckCounter)
+		},
+	})
+}
+
+// ServiceConfig service类型的配置，同process
+type ServiceConfig struct {
+	ProcessConfig `mapstructure:",squash"`
+}
+
+// Validate 早期版本的service检测不做任何检查，没有可沿用的默认匹配条件，未配置时提示迁移
+func (c *ServiceConfig) Validate() error {
+	if c.Comm == "" && c.Cmdline == "" && c.PidFile == "" && c.Parent == "" {
+		return errors.New("service no longer has a default matcher: set comm, cmdline, pid_file or parent, or use type oss to probe the object storage gateway")
+	}
+	return c.ProcessConfig.Validate()
+}
+
+type OSSConfig struct {
+	// 本机对象存储网关地址
+	Endpoint string `mapstructure:"endpoint"`
+	Bucket   string `mapstructure:"bucket"`
+	// 为空时请求bucket本身
+	Object string `mapstructure:"object"`
+	// HEAD或GET
+	Method string `mapstructure:"method"`
+	// true: endpoint/bucket/object；false: bucket.endpoint/object
+	PathStyle bool   `mapstructure:"path_style"`
+	Region    string `mapstructure:"region"`
+	// 为空时匿名访问，否则使用SigV4签名
+	AccessKey string        `mapstructure:"access_key"`
+	SecretKey string        `mapstructure:"secret_key"`
+	TLS       HTTPTLSConfig `mapstructure:"tls"`
+	// HEAD/GET期望的状态码，默认200
+	ExpectedStatus []int `mapstructure:"expected_status"`
+	// 是否写入、读取并删除探测对象，对象名为canary_prefix加节点名称
+	Canary       bool   `mapstructure:"canary"`
+	CanaryPrefix string `mapstructure:"canary_prefix"`
+}
+
+func (c *OSSConfig) Validate() error {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q", c.Endpoint)
+	}
+	if c.Bucket == "" {
+		return errors.New("bucket is required")
+	}
+	if c.Method != http.MethodHead && c.Method != http.MethodGet {
+		return fmt.Errorf("method must be HEAD or GET, got %q", c.Method)
+	}
+	if (c.AccessKey == "") != (c.SecretKey == "") {
+		return errors.New("access_key and secret_key must be set together")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("tls cert_file and key_file must be set together")
+	}
+	if c.Canary && c.CanaryPrefix == "" {
+		return errors.New("canary_prefix is required")
+	}
+	return nil
+}
+
+var (
+	ossCheckCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "oss",
+			Name:      "oss_check_updates",
+			Help:      "Counter of oss updates.",
+		}, []string{"type"}) // 对象存储检测计数counter
+
+	ossRequestLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "oss",
+			Name:      "request_latency_seconds",
+			Help:      "Latency of the last object storage request.",
+		}, []string{"bucket", "op"})
+
+	ossRequestErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "oss",
+			Name:      "request_errors_total",
+			Help:      "Failed object storage requests by error code.",
+		}, []string{"bucket", "op", "code"})
+)
+
+var _ StatusInterface = (*OSSImpl)(nil)
+
+// maxOSSBody 读取的响应体上限，用于解析错误码和校验探测对象
+const maxOSSBody = 64 << 10
+
+// OSSImpl 对象存储检测，进程存在但网关或后端存储异常时同样视为失败
+type OSSImpl struct {
+	name      string
+	cfg       OSSConfig
+	canaryKey string
+	client    *http.Client
+}
+
+// OSSOp 单次请求的结果
+type OSSOp struct {
+	Op     string
+	Status int
+	// 服务端返回的错误码，如NoSuchBucket、AccessDenied
+	Code    string
+	Latency time.Duration
+	Err     error
+}
+
+// OSSResult 作为StatusAction.Extra
+type OSSResult []OSSOp
+
+func (r OSSResult) String() string {
+	s := make([]string, 0, len(r))
+	for _, op := range r {
+		switch {
+		case op.Err != nil:
+			s = append(s, fmt.Sprintf("%s=%v", op.Op, op.Err))
+		default:
+			s = append(s, fmt.Sprintf("%s=%d(%v)", op.Op, op.Status, op.Latency.Round(time.Microsecond)))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// NewOSSCheck 创建对象存储检测，node用于生成各节点独立的探测对象名
+func NewOSSCheck(name string, cfg *OSSConfig, node string) (*OSSImpl, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := cfg.TLS.load()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &OSSImpl{
+		name:      name,
+		cfg:       *cfg,
+		canaryKey: cfg.CanaryPrefix + node,
+		// 超时由检测的ctx控制
+		client: &http.Client{Transport: transport},
+	}, nil
+}
+
+func (o *OSSImpl) Name() string {
+	return o.name
+}
+
+func (o *OSSImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{ossCheckCounter, ossRequestLatency, ossRequestErrors}
+}
+
+func (o *OSSImpl) CheckStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", o.Name(), used)
+	}()
+	ossCheckCounter.WithLabelValues("total").Inc()
+	sa := StatusAction{
+		Time:   now,
+		Name:   o.Name(),
+		Status: false,
+	}
+	op := o.do(ctx, strings.ToLower(o.cfg.Method), o.cfg.Method, o.cfg.Object, nil, o.cfg.ExpectedStatus)
+	res := OSSResult{op.OSSOp}
+	ok := op.Err == nil
+	if ok && o.cfg.Canary {
+		ok = o.canary(ctx, &res)
+	}
+	sa.Extra = res
+	if !ok {
+		ossCheckCounter.WithLabelValues("failed").Inc()
+		return sa
+	}
+	sa.Status = true
+	return sa
+}
+
+// canary 写入、读取、删除探测对象，读取的内容必须与写入一致，写入成功后总是尝试删除
+func (o *OSSImpl) canary(ctx context.Context, res *OSSResult) bool {
+	body := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+	put := o.do(ctx, "put", http.MethodPut, o.canaryKey, body, []int{http.StatusOK})
+	*res = append(*res, put.OSSOp)
+	if put.Err != nil {
+		return false
+	}
+	get := o.do(ctx, "get", http.MethodGet, o.canaryKey, nil, []int{http.StatusOK})
+	if get.Err == nil && !bytes.Equal(get.body, body) {
+		get.Err = errors.New("canary content mismatch")
+	}
+	*res = append(*res, get.OSSOp)
+	del := o.do(ctx, "delete", http.MethodDelete, o.canaryKey, nil, []int{http.StatusOK, http.StatusNoContent})
+	*res = append(*res, del.OSSOp)
+	return get.Err == nil && del.Err == nil
+}
+
+type ossResponse struct {
+	OSSOp
+	body []byte
+}
+
+// do 发送一次请求，状态码不在expected中时解析错误码
+func (o *OSSImpl) do(ctx context.Context, op, method, key string, body []byte, expected []int) *ossResponse {
+	r := &ossResponse{OSSOp: OSSOp{Op: op}}
+	defer func() {
+		if r.Err != nil {
+			code := r.Code
+			if code == "" && r.Status == 0 {
+				code = "request_failed"
+			} else if code == "" {
+				code = http.StatusText(r.Status)
+			}
+			ossRequestErrors.WithLabelValues(o.cfg.Bucket, op, code).Inc()
+		} else {
+			ossRequestLatency.WithLabelValues(o.cfg.Bucket, op).Set(r.Latency.Seconds())
+		}
+	}()
+	req, err := http.NewRequestWithContext(ctx, method, o.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if body == nil {
+		req.Body, req.ContentLength = http.NoBody, 0
+	}
+	if o.cfg.AccessKey != "" {
+		sigv4.Sign(req, sigv4.Credentials{AccessKey: o.cfg.AccessKey, SecretKey: o.cfg.SecretKey},
+			o.cfg.Region, "s3", sigv4.PayloadHash(body), time.Now())
+	}
+	start := time.Now()
+	resp, err := o.client.Do(req)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer resp.Body.Close()
+	r.body, err = io.ReadAll(io.LimitReader(resp.Body, maxOSSBody))
+	// 读完剩余内容以便复用连接
+	_, _ = io.Copy(io.Discard, resp.Body)
+	r.Latency = time.Since(start)
+	r.Status = resp.StatusCode
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	if !slices.Contains(expected, resp.StatusCode) {
+		r.Code = s3ErrorCode(r.body)
+		if r.Code != "" {
+			r.Err = fmt.Errorf("status %d %s", resp.StatusCode, r.Code)
+		} else {
+			r.Err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+	}
+	return r
+}
+
+func (o *OSSImpl) objectURL(key string) string {
+	u, _ := url.Parse(o.cfg.Endpoint)
+	if o.cfg.PathStyle {
+		u.Path = "/" + o.cfg.Bucket + "/" + key
+	} else {
+		u.Host = o.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return u.String()
+}
+
+// s3ErrorCode 解析S3错误响应中的Code，HEAD请求没有响应体
+func s3ErrorCode(body []byte) string {
+	var e struct {
+		Code string `xml:"Code"`
+	}
+	if err := xml.Unmarshal(body, &e); err != nil {
+		return ""
+	}
+	return e.Code
+}
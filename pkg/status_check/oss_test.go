@@ -0,0 +1,282 @@
+package status_check
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/internal/sigv4"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// fakeS3 path style的S3服务，校验SigV4签名并保存对象
+type fakeS3 struct {
+	t      *testing.T
+	bucket string
+	mu     sync.Mutex
+	// 对象内容
+	objects map[string][]byte
+	// 按顺序记录的请求，如PUT /bucket/key
+	requests []string
+	// 非空时对指定方法返回该状态码和错误码
+	fail map[string]s3Failure
+}
+
+type s3Failure struct {
+	status int
+	code   string
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	s := &fakeS3{t: t, bucket: bucket, objects: make(map[string][]byte), fail: make(map[string]s3Failure)}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	if code := s.verify(r, body); code != "" {
+		s.writeError(w, http.StatusForbidden, code)
+		return
+	}
+	if f, ok := s.fail[r.Method]; ok {
+		s.writeError(w, f.status, f.code)
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		s.writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	switch r.Method {
+	case http.MethodHead:
+		if _, ok := s.objects[key]; key != "" && !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		_, _ = w.Write(data)
+	case http.MethodPut:
+		s.objects[key] = body
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verify 按签名中的SignedHeaders和X-Amz-Date重新计算签名，返回S3错误码
+func (s *fakeS3) verify(r *http.Request, body []byte) string {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return "AccessDenied"
+	}
+	if r.Header.Get("X-Amz-Content-Sha256") != sigv4.PayloadHash(body) {
+		return "XAmzContentSHA256Mismatch"
+	}
+	now, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return "AccessDenied"
+	}
+	_, signed, ok := strings.Cut(auth, "SignedHeaders=")
+	if !ok {
+		return "AccessDenied"
+	}
+	signed, _, _ = strings.Cut(signed, ",")
+	req, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	for _, h := range strings.Split(signed, ";") {
+		if h != "host" {
+			req.Header[http.CanonicalHeaderKey(h)] = r.Header.Values(h)
+		}
+	}
+	sigv4.Sign(req, sigv4.Credentials{AccessKey: testAccessKey, SecretKey: testSecretKey},
+		"us-east-1", "s3", r.Header.Get("X-Amz-Content-Sha256"), now)
+	if req.Header.Get("Authorization") != auth {
+		return "SignatureDoesNotMatch"
+	}
+	return ""
+}
+
+func (s *fakeS3) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if code != "" {
+		fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<Error><Code>%s</Code><Message>test</Message></Error>", code)
+	}
+}
+
+// newOSSCheck 访问fake服务端的data bucket，options覆盖其中的配置，其余配置使用默认值
+func newOSSCheck(t *testing.T, endpoint string, options map[string]any) *OSSImpl {
+	t.Helper()
+	opts := map[string]any{
+		"endpoint":   endpoint,
+		"bucket":     "data",
+		"access_key": testAccessKey,
+		"secret_key": testSecretKey,
+	}
+	maps.Copy(opts, options)
+	return newCheck[*OSSImpl](t, "oss", opts, &Env{NodeName: "node1"})
+}
+
+func TestOSSCanary(t *testing.T) {
+	s3, srv := newFakeS3(t, "data")
+	o := newOSSCheck(t, srv.URL, map[string]any{"canary": true})
+	sa := o.CheckStatus(context.Background())
+	res := sa.Extra.(OSSResult)
+	if !sa.Status {
+		t.Fatalf("expected success: %s", res)
+	}
+	want := []string{
+		"HEAD /data/",
+		"PUT /data/.system-usability-detection/node1",
+		"GET /data/.system-usability-detection/node1",
+		"DELETE /data/.system-usability-detection/node1",
+	}
+	if strings.Join(s3.requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("requests:\n%s\nwant:\n%s", strings.Join(s3.requests, "\n"), strings.Join(want, "\n"))
+	}
+	if len(s3.objects) != 0 {
+		t.Fatalf("canary object was not deleted: %v", s3.objects)
+	}
+	if len(res) != 4 || res[3].Op != "delete" || res[3].Status != http.StatusNoContent {
+		t.Fatalf("unexpected result %s", res)
+	}
+}
+
+func TestOSSBadSignature(t *testing.T) {
+	_, srv := newFakeS3(t, "data")
+	o := newOSSCheck(t, srv.URL, map[string]any{"secret_key": "wrong"})
+	sa := o.CheckStatus(context.Background())
+	res := sa.Extra.(OSSResult)
+	// HEAD没有响应体，只能得到状态码
+	if sa.Status || res[0].Status != http.StatusForbidden || res[0].Code != "" {
+		t.Fatalf("unexpected result %s", res)
+	}
+
+	o = newOSSCheck(t, srv.URL, map[string]any{"secret_key": "wrong", "method": http.MethodGet})
+	before := testutil.ToFloat64(ossRequestErrors.WithLabelValues("data", "get", "SignatureDoesNotMatch"))
+	sa = o.CheckStatus(context.Background())
+	res = sa.Extra.(OSSResult)
+	if sa.Status || res[0].Code != "SignatureDoesNotMatch" || res[0].Err.Error() != "status 403 SignatureDoesNotMatch" {
+		t.Fatalf("unexpected result %s", res)
+	}
+	if after := testutil.ToFloat64(ossRequestErrors.WithLabelValues("data", "get", "SignatureDoesNotMatch")); after != before+1 {
+		t.Fatalf("request_errors_total %v, want %v", after, before+1)
+	}
+}
+
+func TestOSSErrorCodes(t *testing.T) {
+	cases := []struct {
+		name   string
+		bucket string
+		fail   map[string]s3Failure
+		op     string
+		status int
+		code   string
+		// request_errors_total的code标签
+		label string
+	}{
+		{"no such bucket", "other", nil, "get", http.StatusNotFound, "NoSuchBucket", "NoSuchBucket"},
+		{"put slow down", "data", map[string]s3Failure{http.MethodPut: {http.StatusServiceUnavailable, "SlowDown"}}, "put", http.StatusServiceUnavailable, "SlowDown", "SlowDown"},
+		{"get without body", "data", map[string]s3Failure{http.MethodGet: {http.StatusInternalServerError, ""}}, "get", http.StatusInternalServerError, "", "Internal Server Error"},
+		{"delete denied", "data", map[string]s3Failure{http.MethodDelete: {http.StatusForbidden, "AccessDenied"}}, "delete", http.StatusForbidden, "AccessDenied", "AccessDenied"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s3, srv := newFakeS3(t, c.bucket)
+			for k, v := range c.fail {
+				s3.fail[k] = v
+			}
+			o := newOSSCheck(t, srv.URL, map[string]any{
+				"method": http.MethodGet,
+				"canary": c.fail != nil,
+				// 检测bucket本身时GET返回NoSuchKey，这里探测canary之外已存在的对象
+				"object": "exists",
+			})
+			s3.objects["exists"] = []byte("ok")
+			counter := ossRequestErrors.WithLabelValues("data", c.op, c.label)
+			before := testutil.ToFloat64(counter)
+			sa := o.CheckStatus(context.Background())
+			res := sa.Extra.(OSSResult)
+			if sa.Status {
+				t.Fatalf("expected failure: %s", res)
+			}
+			var op *OSSOp
+			for i := range res {
+				if res[i].Op == c.op {
+					op = &res[i]
+				}
+			}
+			if op == nil || op.Status != c.status || op.Code != c.code || op.Err == nil {
+				t.Fatalf("unexpected result %s", res)
+			}
+			if after := testutil.ToFloat64(counter); after != before+1 {
+				t.Fatalf("request_errors_total{code=%q} %v, want %v", c.label, after, before+1)
+			}
+		})
+	}
+}
+
+func TestOSSCanaryMismatch(t *testing.T) {
+	s3, srv := newFakeS3(t, "data")
+	o := newOSSCheck(t, srv.URL, map[string]any{"canary": true})
+	// 读取时返回其他内容，删除仍需执行
+	o.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err == nil && r.Method == http.MethodGet {
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader([]byte("stale")))
+		}
+		return resp, err
+	})
+	sa := o.CheckStatus(context.Background())
+	res := sa.Extra.(OSSResult)
+	if sa.Status || len(res) != 4 || res[2].Err == nil || res[2].Err.Error() != "canary content mismatch" {
+		t.Fatalf("unexpected result %s", res)
+	}
+	if len(s3.objects) != 0 {
+		t.Fatalf("canary object was not deleted: %v", s3.objects)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestServiceAlias(t *testing.T) {
+	_, _, _, err := decode(config.CheckSpec{Type: "service"})
+	if err == nil || !strings.Contains(err.Error(), "use type oss") {
+		t.Fatalf("err=%v, want a migration error naming oss", err)
+	}
+	_, _, cfg, err := decode(config.CheckSpec{Type: "service", Options: map[string]any{"cmdline": "oss-server"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := cfg.(*ServiceConfig); c.Cmdline != "oss-server" || c.Min != 1 {
+		t.Fatalf("unexpected config %+v", c)
+	}
+}
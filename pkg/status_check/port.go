@@ -0,0 +1,260 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"system-usability-detection/internal/procfs"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("tcp", Factory{
+		Description: "tcp端口检测，主动连接或检查本机监听",
+		Config: func() any {
+			return &PortConfig{Mode: PortModeConnect, ConnectTimeout: time.Second, Require: PortRequireAll}
+		},
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return &PortImpl{name: name, proto: "tcp", cfg: *cfg.(*PortConfig)}, nil
+		},
+	})
+	Register("udp", Factory{
+		Description: "udp端口检测，检查本机绑定",
+		Config:      func() any { return &PortConfig{Mode: PortModeListen, Require: PortRequireAll, udp: true} },
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return &PortImpl{name: name, proto: "udp", cfg: *cfg.(*PortConfig)}, nil
+		},
+	})
+}
+
+const (
+	// PortModeConnect 主动连接目标地址
+	PortModeConnect = "connect"
+	// PortModeListen 读取/proc/net检查本机是否监听
+	PortModeListen = "listen"
+
+	PortRequireAll = "all"
+	PortRequireAny = "any"
+)
+
+type PortConfig struct {
+	Mode string `mapstructure:"mode"`
+	// connect模式为host:port；listen模式为port或ip:port，只写端口时匹配任意地址
+	Targets []string `mapstructure:"targets"`
+	// connect模式单个连接的超时时间
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
+	// all: 所有目标正常才算正常；any: 任一目标正常即可
+	Require string `mapstructure:"require"`
+
+	udp bool
+}
+
+func (c *PortConfig) Validate() error {
+	if len(c.Targets) == 0 {
+		return errors.New("targets are required")
+	}
+	switch c.Mode {
+	case PortModeConnect:
+		if c.udp {
+			return errors.New("udp only supports listen mode")
+		}
+		if c.ConnectTimeout <= 0 {
+			return errors.New("connect_timeout must be greater than 0")
+		}
+	case PortModeListen:
+	default:
+		return fmt.Errorf("unknown mode %q", c.Mode)
+	}
+	if c.Require != PortRequireAll && c.Require != PortRequireAny {
+		return fmt.Errorf("unknown require %q", c.Require)
+	}
+	for _, t := range c.Targets {
+		if _, _, err := splitTarget(t, c.Mode == PortModeListen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitTarget 解析目标地址，listen模式允许省略地址
+func splitTarget(target string, listen bool) (net.IP, int, error) {
+	host, portStr := "", target
+	if strings.Contains(target, ":") {
+		var err error
+		if host, portStr, err = net.SplitHostPort(target); err != nil {
+			return nil, 0, fmt.Errorf("invalid target %q: %w", target, err)
+		}
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || port == 0 {
+		return nil, 0, fmt.Errorf("invalid port in target %q", target)
+	}
+	if !listen {
+		if host == "" {
+			return nil, 0, fmt.Errorf("host is required in target %q", target)
+		}
+		return nil, int(port), nil
+	}
+	if host == "" {
+		return nil, int(port), nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, 0, fmt.Errorf("listen target %q must be an ip address", target)
+	}
+	return ip, int(port), nil
+}
+
+var portCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "port",
+		Name:      "port_check_updates",
+		Help:      "Counter of port updates.",
+	}, []string{"proto", "type"}) // 端口检测计数counter
+
+var _ StatusInterface = (*PortImpl)(nil)
+
+// PortImpl tcp/udp端口检测
+type PortImpl struct {
+	name  string
+	proto string
+	cfg   PortConfig
+}
+
+// PortResult 单个目标的检测结果
+type PortResult struct {
+	Target string
+	OK     bool
+	// connect模式的连接耗时
+	Latency time.Duration
+	Err     error
+}
+
+// PortResults 所有目标的检测结果，作为StatusAction.Extra
+type PortResults []PortResult
+
+func (r PortResults) String() string {
+	s := make([]string, 0, len(r))
+	for _, p := range r {
+		switch {
+		case p.Err != nil:
+			s = append(s, fmt.Sprintf("%s=%v", p.Target, p.Err))
+		case p.Latency > 0:
+			s = append(s, fmt.Sprintf("%s=ok(%v)", p.Target, p.Latency.Round(time.Microsecond)))
+		default:
+			s = append(s, p.Target+"=ok")
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func (p *PortImpl) Name() string {
+	return p.name
+}
+
+func (p *PortImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{portCheckCounter}
+}
+
+func (p *PortImpl) CheckStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", p.Name(), used)
+	}()
+	portCheckCounter.WithLabelValues(p.proto, "total").Inc()
+	sa := StatusAction{
+		Time:   now,
+		Name:   p.Name(),
+		Status: false,
+	}
+	var res PortResults
+	if p.cfg.Mode == PortModeListen {
+		var err error
+		if res, err = p.listen(); err != nil {
+			portCheckCounter.WithLabelValues(p.proto, "failed").Inc()
+			sa.Extra = err
+			return sa
+		}
+	} else {
+		res = p.connect(ctx)
+	}
+	sa.Extra = res
+	var ok int
+	for _, r := range res {
+		if r.OK {
+			ok++
+		}
+	}
+	if ok == len(res) || (p.cfg.Require == PortRequireAny && ok > 0) {
+		sa.Status = true
+		return sa
+	}
+	portCheckCounter.WithLabelValues(p.proto, "failed").Inc()
+	return sa
+}
+
+// connect 并发连接所有目标
+func (p *PortImpl) connect(ctx context.Context) PortResults {
+	res := make(PortResults, len(p.cfg.Targets))
+	dialer := &net.Dialer{Timeout: p.cfg.ConnectTimeout}
+	var wg sync.WaitGroup
+	for i, target := range p.cfg.Targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			res[i].Target = target
+			conn, err := dialer.DialContext(ctx, p.proto, target)
+			if err != nil {
+				res[i].Err = err
+				return
+			}
+			res[i].Latency = time.Since(start)
+			res[i].OK = true
+			conn.Close()
+		}()
+	}
+	wg.Wait()
+	return res
+}
+
+// listen 检查本机监听(tcp)或绑定(udp)的socket，监听在通配地址时匹配任意目标地址
+func (p *PortImpl) listen() (PortResults, error) {
+	sockets, err := procfs.Sockets(p.proto)
+	if err != nil {
+		return nil, err
+	}
+	state := procfs.TCPListen
+	if p.proto == "udp" {
+		state = procfs.UDPUnconnected
+	}
+	res := make(PortResults, len(p.cfg.Targets))
+	for i, target := range p.cfg.Targets {
+		res[i].Target = target
+		ip, port, _ := splitTarget(target, true)
+		for _, s := range sockets {
+			if s.State != state || s.Port != port {
+				continue
+			}
+			if ip == nil || s.IP.IsUnspecified() || s.IP.Equal(ip) {
+				res[i].OK = true
+				break
+			}
+		}
+		if !res[i].OK {
+			res[i].Err = errors.New("not listening")
+		}
+	}
+	return res, nil
+}
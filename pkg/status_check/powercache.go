@@ -1,46 +1,124 @@
 package status_check
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
-	clientv3 "go.etcd.io/etcd/client/v3"
 	"os"
 	"path/filepath"
-	"strings"
-	"system-usability-detection/internal/config"
+	"sync/atomic"
+	"time"
+
 	"system-usability-detection/internal/util"
 	"system-usability-detection/pkg/metrics"
-	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func init() {
+	Register("power_cache", Factory{
+		Description: "powercache服务健康状态检测",
+		Config: func() any {
+			return &PowerCacheConfig{
+				MountPoint:       "/var/powercache",
+				ProbeTimeout:     25 * time.Second,
+				Interval:         5 * time.Second,
+				FailureThreshold: 5,
+			}
+		},
+		New: func(name string, cfg any, env *Env) (StatusInterface, error) {
+			c := cfg.(*PowerCacheConfig)
+			return &PowerCacheImpl{name: name, MountPoint: c.MountPoint, cfg: *c, env: env}, nil
+		},
+	})
+}
+
+type PowerCacheConfig struct {
+	MountPoint string `mapstructure:"mount_point"`
+	// 单次写检测超时时间
+	ProbeTimeout time.Duration `mapstructure:"probe_timeout"`
+	// 两次写检测的间隔
+	Interval time.Duration `mapstructure:"interval"`
+	// 连续失败次数达到该值时标记power_cache不可用并推送到etcd
+	FailureThreshold int `mapstructure:"failure_threshold"`
+}
+
+func (c *PowerCacheConfig) Validate() error {
+	if c.ProbeTimeout <= 0 || c.Interval <= 0 || c.FailureThreshold <= 0 {
+		return errors.New("probe_timeout, interval and failure_threshold must be greater than 0")
+	}
+	return nil
+}
+
+var cacheCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "power_cache",
+		Name:      "cache_check_updates",
+		Help:      "Counter of power_cache updates.",
+	}, []string{"type"}) // power_cache检测计数counter
+
+var (
+	_ StatusInterface = (*PowerCacheImpl)(nil)
+	_ Worker          = (*PowerCacheImpl)(nil)
 )
 
-var _ StatusInterface = (*PowerCacheImpl)(nil)
+// /disable_power_cache/localip ---> time.Now().String()
+var powerPrefix = "/disable_power_cache/"
 
-// PowerCacheImpl powercache 服务检测
+// PowerCacheImpl powercache 服务检测，后台定时写挂载点下的文件，
+// 不可用时推送到etcd，并聚合其他节点的结果判断是否有必要切换VIP
 type PowerCacheImpl struct {
+	name       string
 	MountPoint string //  /var/powercache
+	cfg        PowerCacheConfig
+	env        *Env
+
+	// 标记power_cache是否可用,true 表示不能用 false 表示可用
+	disable atomic.Bool
+	// 当disable为true的时候,需要判断该值，如果该值为true:说明有其他节点的power_cache可用,可以切换
+	// false:说明power_cache都不可用,此时切换VIP没有任何意义
+	hasAvailable atomic.Bool
+
+	// 通知aggregationPower函数执行聚合
+	notifyAggregation chan struct{}
+	cancel            context.CancelFunc
+}
+
+// powerCacheProbeSize 每次写入的字节数
+const powerCacheProbeSize = 512
+
+type resultFlag struct {
+	flag int64
+	used time.Duration
+	err  error
 }
 
 func (p *PowerCacheImpl) Name() string {
-	return "power_cache"
+	return p.name
+}
+
+func (p *PowerCacheImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{cacheCheckCounter}
 }
-func (p *PowerCacheImpl) CheckStatus() StatusAction {
-	util.Logger.Info("powerCacheDisable:%v ,hasAvailablePowerCache:%v", powerCacheDisable, hasAvailablePowerCache)
-	metrics.CacheCheckCounter.WithLabelValues("total").Inc()
+
+func (p *PowerCacheImpl) CheckStatus(context.Context) StatusAction {
+	disable, hasAvailable := p.disable.Load(), p.hasAvailable.Load()
+	util.Logger.Info("powerCacheDisable:%v ,hasAvailablePowerCache:%v", disable, hasAvailable)
+	cacheCheckCounter.WithLabelValues("total").Inc()
 	sa := StatusAction{
 		Time:   time.Now(),
 		Name:   p.Name(),
 		Status: false,
 	}
-	if !powerCacheDisable {
-		metrics.CacheCheckCounter.WithLabelValues("failed").Inc()
-		sa.Status = !powerCacheDisable
+	if !disable {
+		sa.Status = true
 		return sa
 	}
 	//当前节点的power不可用,需要检查其他节点是否有可用的power_cache
-	if hasAvailablePowerCache {
-		metrics.CacheCheckCounter.WithLabelValues("failed").Inc()
+	if hasAvailable {
+		cacheCheckCounter.WithLabelValues("failed").Inc()
 		//可以切换VIP
 		return sa
 	}
@@ -49,104 +127,68 @@ func (p *PowerCacheImpl) CheckStatus() StatusAction {
 	return sa
 }
 
-// 检测挂载点是否存在
-func checkMountPoint(mountPoint string) (exist bool, err error) {
-	now := time.Now()
-	util.Logger.Info("start checkMountPoint at:%v", now.Unix())
-	defer func() {
-		used := time.Since(now)
-		util.Logger.Info("checkMountPoint used:%v", used)
-	}()
-	file, err := os.Open("/proc/mounts")
-	if err != nil {
-		return
-	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		if strings.Contains(scanner.Text(), mountPoint) {
-			return true, nil
-		}
-	}
-	if err = scanner.Err(); err != nil {
-		return
+// Start 启动后台写检测和etcd结果聚合，需要etcd客户端
+func (p *PowerCacheImpl) Start(ctx context.Context) error {
+	if p.env == nil || p.env.Etcd == nil {
+		return errors.New("power_cache requires an etcd client")
 	}
-	return
+	p.hasAvailable.Store(true)
+	p.notifyAggregation = make(chan struct{}, 1)
+	ctx, p.cancel = context.WithCancel(ctx)
+	go p.backGroundPowerCheck(ctx)
+	go p.aggregationPower(ctx)
+	return nil
 }
 
-// 标记power_cache是否可用,true 表示不能用 false 表示可用
-var (
-	powerCacheDisable bool
-
-	// 当powerCacheDisable为true的时候,需要判断该值，如果该值为true:说明有其他节点的power_cache可用,可以切换
-	// false:说明power_cache都不可用,此时切换VIP没有任何意义
-	hasAvailablePowerCache = true
-
-	// 通知AggregationPower函数执行聚合
-	notifyAggregationPower = make(chan struct{}, 1)
-	powerTimeOut           = 25 * time.Second
-	//工作队列
-	workerQueueCh = make(chan *resultFlag, 1)
-	//结果队列
-	resultCh = make(chan *resultFlag, 1)
-)
-
-type resultFlag struct {
-	flag int64
-	used time.Duration
-	err  error
+func (p *PowerCacheImpl) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
 }
 
-func checkPowerCache(mountPoint string, filePath string) {
+// checkPowerCache 处理工作队列中的写检测任务，结果写入结果队列
+func (p *PowerCacheImpl) checkPowerCache(ctx context.Context, filePath string, workerQueueCh <-chan *resultFlag, resultCh chan<- *resultFlag) {
 	_, err := os.Create(filePath)
 	if err != nil {
 		util.Logger.Error("create file failed:%v", err)
 	}
-	for rf := range workerQueueCh {
+	for {
+		var rf *resultFlag
+		select {
+		case <-ctx.Done():
+			return
+		case rf = <-workerQueueCh:
+		}
 		//开始新一轮检测
 		go func() {
-			var (
-				fileHandler *os.File
-				err         error
-			)
 			newRF := &resultFlag{
 				flag: rf.flag,
 			}
 			defer func() {
-				if fileHandler != nil {
-					fileHandler.Close()
-				}
 				newRF.used = time.Since(time.Unix(rf.flag, 0))
-				resultCh <- newRF
+				select {
+				case resultCh <- newRF:
+				case <-ctx.Done():
+				}
 			}()
-			exist, err := checkMountPoint(mountPoint)
-			//如果挂载点不存在
-			if err != nil {
-				newRF.err = err
-				util.Logger.Error("power_cache check mountpoint failed:%v", err)
+			// 挂载点按mountinfo精确匹配，写文件阻塞由probe_timeout兜底，因此不再单独stat
+			if res := checkMount(ctx, &MountConfig{Path: p.MountPoint}, nil); res.Err != nil {
+				newRF.err = fmt.Errorf("check mount point: %v", res)
+				util.Logger.Error("power_cache check mountpoint failed:%v", res)
 				return
 			}
-			if !exist {
-				newRF.err = errors.New("mount point not exist")
-				return
-			}
-			util.Logger.Info("start create or trunc at:%v", time.Now().Unix())
-			//fileHandler, err = os.OpenFile(filePath, os.O_RDWR|os.O_TRUNC|syscall.O_DIRECT, 0666)
-			fileHandler, err = os.OpenFile(filePath, os.O_RDWR|os.O_TRUNC, 0666)
-			if err != nil {
-				if os.IsNotExist(err) {
-					//文件不存在，说明power_cache被清理过
-					_, errCreate := os.Create(filePath)
-					if errCreate != nil {
-						util.Logger.Error("create file failed:%v", err)
-					}
+			if _, err := os.Stat(filePath); os.IsNotExist(err) {
+				//文件不存在，说明power_cache被清理过
+				_, errCreate := os.Create(filePath)
+				if errCreate != nil {
+					util.Logger.Error("create file failed:%v", errCreate)
 				}
 				newRF.err = err
-				util.Logger.Error("power_cache create or trunc failed:%v", err)
 				return
 			}
 			util.Logger.Info("start write at:%v", time.Now().Unix())
-			if _, err = fileHandler.WriteString(fmt.Sprintf("%v", rf.flag)); err != nil {
+			// 写入后fsync并读回校验
+			if _, err := ioProbe(filePath, powerCacheProbeSize, false, true); err != nil {
 				newRF.err = err
 				util.Logger.Error("power_cache write failed:%v", err)
 				return
@@ -155,14 +197,18 @@ func checkPowerCache(mountPoint string, filePath string) {
 	}
 }
 
-func backGroundPowerCheck(cli *clientv3.Client, mountPoint string) {
-	name, _ := os.Hostname()
+// backGroundPowerCheck 后台定时检测power_cache写操作
+func (p *PowerCacheImpl) backGroundPowerCheck(ctx context.Context) {
 	var (
-		writeFile = filepath.Join(mountPoint, ".write_check_"+name)
+		writeFile = filepath.Join(p.MountPoint, ".write_check_"+p.env.NodeName)
 		count     int
+		//工作队列
+		workerQueueCh = make(chan *resultFlag, 1)
+		//结果队列
+		resultCh = make(chan *resultFlag, 1)
 	)
 	//后台检测power_cache写操作
-	go checkPowerCache(mountPoint, writeFile)
+	go p.checkPowerCache(ctx, writeFile, workerQueueCh, resultCh)
 	workerQueueCh <- &resultFlag{
 		flag: time.Now().Unix(),
 	}
@@ -177,36 +223,45 @@ func backGroundPowerCheck(cli *clientv3.Client, mountPoint string) {
 				util.Logger.Error("execute power_cache check failed info:%v", res.err)
 				count++
 			} else {
-				powerCacheDisable = false
-				cleanCurrentPowerFromEtcd(cli)
+				p.disable.Store(false)
+				p.cleanCurrentPowerFromEtcd(ctx)
 				count = 0
 			}
 
-		case <-time.After(powerTimeOut):
-			util.Logger.Error("execute power_cache check timeout more than:%v", powerTimeOut)
+		case <-time.After(p.cfg.ProbeTimeout):
+			util.Logger.Error("execute power_cache check timeout more than:%v", p.cfg.ProbeTimeout)
 			count++
+		case <-ctx.Done():
+			return
 		}
-		if count >= 5 {
-			powerCacheDisable = true
+		if count >= p.cfg.FailureThreshold {
+			p.disable.Store(true)
 			count = 0
 			//当前的power不可用，推送到etcd
-			pushCurrentPowerToEtcd(cli)
+			p.pushCurrentPowerToEtcd(ctx)
+		}
+		select {
+		case <-time.After(p.cfg.Interval):
+		case <-ctx.Done():
+			return
 		}
-		time.Sleep(5 * time.Second)
 	}
 }
 
-// /power_cache/localip ---> time.Now().String()
-var powerPrefix = "/disable_power_cache/"
+func (p *PowerCacheImpl) notify() {
+	select {
+	case p.notifyAggregation <- struct{}{}:
+	default:
+		// 已有待执行的聚合
+	}
+}
 
 // 从etcd把当前节点的power_cache移除掉
-func cleanCurrentPowerFromEtcd(cli *clientv3.Client) {
-	defer func() {
-		notifyAggregationPower <- struct{}{}
-	}()
-	key := powerPrefix + config.GlobalConfigInstance.VrrpInstances.Instances[0].LocalIP
+func (p *PowerCacheImpl) cleanCurrentPowerFromEtcd(ctx context.Context) {
+	defer p.notify()
+	key := powerPrefix + p.env.LocalIP
 	util.Logger.Info("enable power cache key:%s", key)
-	txn := cli.Txn(context.Background())
+	txn := p.env.Etcd.Txn(ctx)
 	//如果存在,移除
 	txn.If(clientv3.Compare(clientv3.CreateRevision(key), "!=", 0)).
 		Then(clientv3.OpDelete(key)).Else()
@@ -218,13 +273,11 @@ func cleanCurrentPowerFromEtcd(cli *clientv3.Client) {
 }
 
 // 把当前不可用的power_cache推送到etcd
-func pushCurrentPowerToEtcd(cli *clientv3.Client) {
-	defer func() {
-		notifyAggregationPower <- struct{}{}
-	}()
-	key := powerPrefix + config.GlobalConfigInstance.VrrpInstances.Instances[0].LocalIP
+func (p *PowerCacheImpl) pushCurrentPowerToEtcd(ctx context.Context) {
+	defer p.notify()
+	key := powerPrefix + p.env.LocalIP
 	util.Logger.Info("disable power cache key:%s", key)
-	txn := cli.Txn(context.Background())
+	txn := p.env.Etcd.Txn(ctx)
 	//如果不存在,新增
 	txn.If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
 		Then(clientv3.OpPut(key, time.Now().String())).Else()
@@ -235,30 +288,24 @@ func pushCurrentPowerToEtcd(cli *clientv3.Client) {
 	}
 }
 
-// 聚合etcd中power_cache的结果，更新hasAvailablePowerCache
-func aggregationPower(cli *clientv3.Client) {
-	ctx := context.Background()
-	for range notifyAggregationPower {
-		ctx, _ := context.WithTimeout(ctx, 2*time.Second)
-		resp, err := cli.Get(ctx, powerPrefix, clientv3.WithPrefix())
+// aggregationPower 聚合etcd中power_cache的结果，更新hasAvailable
+func (p *PowerCacheImpl) aggregationPower(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.notifyAggregation:
+		}
+		getCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		resp, err := p.env.Etcd.Get(getCtx, powerPrefix, clientv3.WithPrefix())
+		cancel()
 		if err != nil {
 			//如果此处被cancel掉,说明超时了
 			util.Logger.Error("aggregationPower get etcd failed:%v", err)
 			//etcd获取失败,此种情况下，我们认为其他节点的power都是可用的
-			if !hasAvailablePowerCache {
-				hasAvailablePowerCache = true
-			}
+			p.hasAvailable.Store(true)
 			continue
 		}
-		if len(resp.Kvs) >= config.GlobalConfigInstance.InstancesCount {
-			if hasAvailablePowerCache {
-				hasAvailablePowerCache = false
-			}
-		} else {
-			if !hasAvailablePowerCache {
-				hasAvailablePowerCache = true
-			}
-		}
+		p.hasAvailable.Store(len(resp.Kvs) < p.env.NodeCount)
 	}
-
 }
@@ -0,0 +1,255 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"system-usability-detection/internal/procfs"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("pressure", Factory{
+		Description: "系统压力检测，基于PSI和loadavg",
+		Config:      func() any { return &PressureConfig{Level: LevelCritical} },
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return &PressureImpl{name: name, cfg: *cfg.(*PressureConfig)}, nil
+		},
+	})
+}
+
+// PressureConfig 阈值为0表示不检查，PSI阈值为百分比
+//
+//   - type: pressure
+//     memory: {full_avg10: 20}
+//     io: {full_avg60: 30}
+//     load: {load5: 4, per_cpu: true}
+type PressureConfig struct {
+	CPU    PSIThreshold  `mapstructure:"cpu"`
+	Memory PSIThreshold  `mapstructure:"memory"`
+	IO     PSIThreshold  `mapstructure:"io"`
+	Load   LoadThreshold `mapstructure:"load"`
+	// 超过阈值时的级别: critical、warn
+	Level string `mapstructure:"level"`
+}
+
+type PSIThreshold struct {
+	SomeAvg10 float64 `mapstructure:"some_avg10"`
+	SomeAvg60 float64 `mapstructure:"some_avg60"`
+	FullAvg10 float64 `mapstructure:"full_avg10"`
+	FullAvg60 float64 `mapstructure:"full_avg60"`
+}
+
+type LoadThreshold struct {
+	Load1  float64 `mapstructure:"load1"`
+	Load5  float64 `mapstructure:"load5"`
+	Load15 float64 `mapstructure:"load15"`
+	// 为true时按cpu核数折算，即阈值为每个核的负载
+	PerCPU bool `mapstructure:"per_cpu"`
+}
+
+func (c *PressureConfig) Validate() error {
+	if c.Level != LevelCritical && c.Level != LevelWarn {
+		return fmt.Errorf("level must be critical or warn, got %q", c.Level)
+	}
+	for _, t := range []PSIThreshold{c.CPU, c.Memory, c.IO} {
+		for _, v := range []float64{t.SomeAvg10, t.SomeAvg60, t.FullAvg10, t.FullAvg60} {
+			if v < 0 || v > 100 {
+				return fmt.Errorf("pressure threshold %v out of range 0-100", v)
+			}
+		}
+	}
+	if c.Load.Load1 < 0 || c.Load.Load5 < 0 || c.Load.Load15 < 0 {
+		return errors.New("load threshold must not be negative")
+	}
+	return nil
+}
+
+var (
+	pressureCheckCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "pressure",
+			Name:      "pressure_check_updates",
+			Help:      "Counter of pressure updates.",
+		}, []string{"type"}) // 系统压力检测计数counter
+
+	pressureAvg = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "pressure",
+			Name:      "avg_percent",
+			Help:      "PSI average stall percent.",
+		}, []string{"resource", "kind", "window"})
+
+	loadAvg = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "pressure",
+			Name:      "load",
+			Help:      "Load average.",
+		}, []string{"window"})
+)
+
+var _ StatusInterface = (*PressureImpl)(nil)
+
+type PressureImpl struct {
+	name string
+	cfg  PressureConfig
+}
+
+// PressureResult 系统压力检测结果
+type PressureResult struct {
+	// 内核未开启PSI时为false，只检查loadavg
+	PSIAvailable bool
+	PSI          map[string]*procfs.PSI
+	Load         *procfs.LoadAvg
+	// 超过阈值的原因
+	Reasons []string
+}
+
+func (r *PressureResult) String() string {
+	var s []string
+	if !r.PSIAvailable {
+		s = append(s, "psi unavailable")
+	}
+	for _, res := range []string{"cpu", "memory", "io"} {
+		psi, ok := r.PSI[res]
+		if !ok {
+			continue
+		}
+		item := fmt.Sprintf("%s some=%.2f/%.2f", res, psi.Some.Avg10, psi.Some.Avg60)
+		if psi.Full != nil {
+			item += fmt.Sprintf(" full=%.2f/%.2f", psi.Full.Avg10, psi.Full.Avg60)
+		}
+		s = append(s, item)
+	}
+	if r.Load != nil {
+		s = append(s, fmt.Sprintf("load=%.2f/%.2f/%.2f", r.Load.Load1, r.Load.Load5, r.Load.Load15))
+	}
+	if len(r.Reasons) > 0 {
+		s = append(s, "["+strings.Join(r.Reasons, ", ")+"]")
+	}
+	return strings.Join(s, " ")
+}
+
+func (p *PressureImpl) Name() string {
+	return p.name
+}
+
+func (p *PressureImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{pressureCheckCounter, pressureAvg, loadAvg}
+}
+
+func (p *PressureImpl) CheckStatus(context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", p.Name(), used)
+	}()
+	pressureCheckCounter.WithLabelValues("total").Inc()
+	sa := StatusAction{
+		Time:   now,
+		Name:   p.Name(),
+		Status: false,
+	}
+	res, err := p.collect()
+	if err != nil {
+		pressureCheckCounter.WithLabelValues("failed").Inc()
+		sa.Extra = err
+		return sa
+	}
+	sa.Extra = res
+	for _, t := range []struct {
+		resource string
+		th       PSIThreshold
+	}{{"cpu", p.cfg.CPU}, {"memory", p.cfg.Memory}, {"io", p.cfg.IO}} {
+		if psi, ok := res.PSI[t.resource]; ok {
+			res.Reasons = append(res.Reasons, psiExceeded(t.resource, psi, t.th)...)
+		}
+	}
+	res.Reasons = append(res.Reasons, p.loadExceeded(res.Load)...)
+	sa.Status = true
+	sa.Level = LevelOK
+	if len(res.Reasons) > 0 {
+		sa.Level = p.cfg.Level
+		sa.Status = p.cfg.Level == LevelWarn
+	}
+	if !sa.Status {
+		pressureCheckCounter.WithLabelValues("failed").Inc()
+	}
+	return sa
+}
+
+// collect 读取PSI和loadavg，PSI不可用时跳过
+func (p *PressureImpl) collect() (*PressureResult, error) {
+	res := &PressureResult{PSIAvailable: true, PSI: make(map[string]*procfs.PSI)}
+	for _, resource := range []string{"cpu", "memory", "io"} {
+		psi, err := procfs.Pressure(resource)
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.EOPNOTSUPP) {
+			res.PSIAvailable = false
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		res.PSI[resource] = psi
+		pressureAvg.WithLabelValues(resource, "some", "10").Set(psi.Some.Avg10)
+		pressureAvg.WithLabelValues(resource, "some", "60").Set(psi.Some.Avg60)
+		if psi.Full != nil {
+			pressureAvg.WithLabelValues(resource, "full", "10").Set(psi.Full.Avg10)
+			pressureAvg.WithLabelValues(resource, "full", "60").Set(psi.Full.Avg60)
+		}
+	}
+	load, err := procfs.ReadLoadAvg()
+	if err != nil {
+		return nil, err
+	}
+	res.Load = load
+	loadAvg.WithLabelValues("1").Set(load.Load1)
+	loadAvg.WithLabelValues("5").Set(load.Load5)
+	loadAvg.WithLabelValues("15").Set(load.Load15)
+	return res, nil
+}
+
+func psiExceeded(resource string, psi *procfs.PSI, th PSIThreshold) []string {
+	var reasons []string
+	check := func(kind string, v, limit float64) {
+		if limit > 0 && v > limit {
+			reasons = append(reasons, fmt.Sprintf("%s %s %.2f > %v", resource, kind, v, limit))
+		}
+	}
+	check("some avg10", psi.Some.Avg10, th.SomeAvg10)
+	check("some avg60", psi.Some.Avg60, th.SomeAvg60)
+	if psi.Full != nil {
+		check("full avg10", psi.Full.Avg10, th.FullAvg10)
+		check("full avg60", psi.Full.Avg60, th.FullAvg60)
+	}
+	return reasons
+}
+
+func (p *PressureImpl) loadExceeded(load *procfs.LoadAvg) []string {
+	scale, unit := 1.0, ""
+	if p.cfg.Load.PerCPU {
+		scale, unit = float64(runtime.NumCPU()), " per cpu"
+	}
+	var reasons []string
+	for _, l := range []struct {
+		name         string
+		v, threshold float64
+	}{{"load1", load.Load1, p.cfg.Load.Load1}, {"load5", load.Load5, p.cfg.Load.Load5}, {"load15", load.Load15, p.cfg.Load.Load15}} {
+		if l.threshold > 0 && l.v/scale > l.threshold {
+			reasons = append(reasons, fmt.Sprintf("%s %.2f%s > %v", l.name, l.v/scale, unit, l.threshold))
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,224 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"system-usability-detection/internal/procfs"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	registerProcess("process", "进程存活检测，按进程名、启动参数、pid文件或父进程匹配", ProcessConfig{Min: 1}, processCheckCounter)
+}
+
+// registerProcess 注册基于process检测的类型，defaults为该类型的默认匹配条件，可被配置覆盖
+func registerProcess(typ, description string, defaults ProcessConfig, counter *prometheus.CounterVec) {
+	Register(typ, Factory{
+		Description: description,
+		Config: func() any {
+			c := defaults
+			return &c
+		},
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return NewProcessCheck(name, cfg.(*ProcessConfig), counter)
+		},
+	})
+}
+
+// ProcessConfig 进程匹配条件，多个条件同时满足才算匹配
+type ProcessConfig struct {
+	// 进程名，与/proc/<pid>/comm完全一致
+	Comm string `mapstructure:"comm"`
+	// 启动参数正则，参数以空格连接后匹配
+	Cmdline string `mapstructure:"cmdline"`
+	// pid文件，只匹配文件中记录的进程
+	PidFile string `mapstructure:"pid_file"`
+	// 父进程名，如内核线程的父进程为kthreadd
+	Parent string `mapstructure:"parent"`
+	// 存活进程数量范围，默认至少1个，Max为0表示不限制
+	Min int `mapstructure:"min"`
+	Max int `mapstructure:"max"`
+	// 匹配到僵尸进程时是否仍视为正常，僵尸进程不计入存活数量
+	AllowZombie bool `mapstructure:"allow_zombie"`
+}
+
+func (c *ProcessConfig) Validate() error {
+	if c.Comm == "" && c.Cmdline == "" && c.PidFile == "" && c.Parent == "" {
+		return errors.New("one of comm, cmdline, pid_file or parent is required")
+	}
+	if c.Cmdline != "" {
+		if _, err := regexp.Compile(c.Cmdline); err != nil {
+			return fmt.Errorf("invalid cmdline regexp: %w", err)
+		}
+	}
+	if c.Min < 0 || c.Max < 0 || (c.Max > 0 && c.Max < c.Min) {
+		return fmt.Errorf("invalid instance range min=%d max=%d", c.Min, c.Max)
+	}
+	return nil
+}
+
+// String 匹配条件描述，用于检测结果
+func (c *ProcessConfig) String() string {
+	var s []string
+	if c.Comm != "" {
+		s = append(s, "comm="+c.Comm)
+	}
+	if c.Cmdline != "" {
+		s = append(s, "cmdline=~"+c.Cmdline)
+	}
+	if c.PidFile != "" {
+		s = append(s, "pid_file="+c.PidFile)
+	}
+	if c.Parent != "" {
+		s = append(s, "parent="+c.Parent)
+	}
+	return strings.Join(s, " ")
+}
+
+var processCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "process",
+		Name:      "process_check_updates",
+		Help:      "Counter of process updates.",
+	}, []string{"type"}) // 进程检测计数counter
+
+var _ StatusInterface = (*ProcessImpl)(nil)
+
+// ProcessImpl 扫描/proc检测进程存活，每次检测都重新扫描，不缓存pid
+type ProcessImpl struct {
+	name    string
+	cfg     ProcessConfig
+	cmdline *regexp.Regexp
+	counter *prometheus.CounterVec
+}
+
+// ProcessResult 进程检测结果
+type ProcessResult struct {
+	Pids    []int
+	Zombies []int
+	Err     error
+}
+
+func (r *ProcessResult) String() string {
+	s := fmt.Sprintf("pids=%v", r.Pids)
+	if len(r.Zombies) > 0 {
+		s += fmt.Sprintf(" zombies=%v", r.Zombies)
+	}
+	if r.Err != nil {
+		s = r.Err.Error() + " " + s
+	}
+	return s
+}
+
+// NewProcessCheck 创建进程检测，counter为nil时使用process类型的计数器
+func NewProcessCheck(name string, cfg *ProcessConfig, counter *prometheus.CounterVec) (*ProcessImpl, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	p := &ProcessImpl{name: name, cfg: *cfg, counter: counter}
+	if p.counter == nil {
+		p.counter = processCheckCounter
+	}
+	if cfg.Cmdline != "" {
+		p.cmdline = regexp.MustCompile(cfg.Cmdline)
+	}
+	return p, nil
+}
+
+func (p *ProcessImpl) Name() string {
+	return p.name
+}
+
+func (p *ProcessImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{p.counter}
+}
+
+func (p *ProcessImpl) CheckStatus(context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", p.Name(), used)
+	}()
+	p.counter.WithLabelValues("total").Inc()
+	sa := StatusAction{
+		Time:   now,
+		Name:   p.Name(),
+		Status: false,
+	}
+	res := p.match()
+	sa.Extra = res
+	if res.Err == nil {
+		res.Err = p.verify(res)
+	}
+	if res.Err != nil {
+		p.counter.WithLabelValues("failed").Inc()
+		return sa
+	}
+	sa.Status = true
+	return sa
+}
+
+// verify 校验存活数量和僵尸进程
+func (p *ProcessImpl) verify(res *ProcessResult) error {
+	if len(res.Zombies) > 0 && !p.cfg.AllowZombie {
+		return fmt.Errorf("zombie process matching %s", &p.cfg)
+	}
+	if n := len(res.Pids); n < p.cfg.Min {
+		return fmt.Errorf("found %d process matching %s, want at least %d", n, &p.cfg, p.cfg.Min)
+	}
+	if n := len(res.Pids); p.cfg.Max > 0 && n > p.cfg.Max {
+		return fmt.Errorf("found %d process matching %s, want at most %d", n, &p.cfg, p.cfg.Max)
+	}
+	return nil
+}
+
+// match 扫描/proc，返回匹配的存活进程和僵尸进程
+func (p *ProcessImpl) match() *ProcessResult {
+	res := &ProcessResult{}
+	pid := 0
+	if p.cfg.PidFile != "" {
+		var err error
+		if pid, err = procfs.ReadPidFile(p.cfg.PidFile); err != nil {
+			res.Err = err
+			return res
+		}
+	}
+	procs, err := procfs.Processes()
+	if err != nil {
+		res.Err = fmt.Errorf("scan processes: %w", err)
+		return res
+	}
+	comm := make(map[int]string, len(procs))
+	for _, proc := range procs {
+		comm[proc.Pid] = proc.Comm
+	}
+	for _, proc := range procs {
+		if pid != 0 && proc.Pid != pid {
+			continue
+		}
+		if p.cfg.Comm != "" && proc.Comm != p.cfg.Comm {
+			continue
+		}
+		if p.cfg.Parent != "" && comm[proc.PPid] != p.cfg.Parent {
+			continue
+		}
+		if p.cmdline != nil && !p.cmdline.MatchString(strings.Join(proc.Cmdline, " ")) {
+			continue
+		}
+		if proc.Zombie() {
+			res.Zombies = append(res.Zombies, proc.Pid)
+			continue
+		}
+		res.Pids = append(res.Pids, proc.Pid)
+	}
+	return res
+}
@@ -0,0 +1,267 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"system-usability-detection/internal/netprobe"
+	"system-usability-detection/internal/procfs"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("reachability", Factory{
+		Description: "通过vrrp网卡探测默认网关和对端节点是否可达，按多数派判断",
+		Config: func() any {
+			return &ReachabilityConfig{
+				Gateway:      true,
+				Methods:      []string{ProbeICMP, ProbeARP, ProbeTCP},
+				TCPPort:      22,
+				ProbeTimeout: time.Second,
+			}
+		},
+		New: func(name string, cfg any, env *Env) (StatusInterface, error) {
+			c := *cfg.(*ReachabilityConfig)
+			if c.Interface == "" {
+				c.Interface = env.Interface
+			}
+			return &ReachabilityImpl{name: name, cfg: c}, nil
+		},
+	})
+}
+
+// 探测方式
+const (
+	ProbeICMP = "icmp"
+	// ProbeARP 二层探测，对端禁ping时仍可判断链路是否正常，需要CAP_NET_RAW
+	ProbeARP = "arp"
+	// ProbeTCP 连接tcp_port，对端返回RST也视为可达
+	ProbeTCP = "tcp"
+)
+
+type ReachabilityConfig struct {
+	// 探测报文绑定的网卡，默认为vrrp网卡
+	Interface string `mapstructure:"interface"`
+	// 是否探测该网卡上的默认网关
+	Gateway bool `mapstructure:"gateway"`
+	// 其他探测目标，如对端节点ip
+	Targets []string `mapstructure:"targets"`
+	// 按顺序尝试，前一种方式失败时使用下一种
+	Methods []string `mapstructure:"methods"`
+	TCPPort int      `mapstructure:"tcp_port"`
+	// 单个目标单种方式的超时时间
+	ProbeTimeout time.Duration `mapstructure:"probe_timeout"`
+	// 至少多少个目标可达，为0时为多数派(n/2+1)
+	Quorum int `mapstructure:"quorum"`
+}
+
+func (c *ReachabilityConfig) Validate() error {
+	n := len(c.Targets)
+	if c.Gateway {
+		n++
+	}
+	if n == 0 {
+		return errors.New("gateway or targets are required")
+	}
+	for _, t := range c.Targets {
+		if ip := net.ParseIP(t); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("target %q is not an ipv4 address", t)
+		}
+	}
+	if len(c.Methods) == 0 {
+		return errors.New("methods are required")
+	}
+	for _, m := range c.Methods {
+		if m != ProbeICMP && m != ProbeARP && m != ProbeTCP {
+			return fmt.Errorf("unknown method %q", m)
+		}
+	}
+	if c.TCPPort <= 0 || c.TCPPort > 65535 {
+		return fmt.Errorf("invalid tcp_port %d", c.TCPPort)
+	}
+	if c.ProbeTimeout <= 0 {
+		return errors.New("probe_timeout must be greater than 0")
+	}
+	if c.Quorum < 0 || c.Quorum > n {
+		return fmt.Errorf("quorum must be between 0 and %d", n)
+	}
+	return nil
+}
+
+var (
+	reachabilityCheckCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "reachability",
+			Name:      "reachability_check_updates",
+			Help:      "Counter of reachability updates.",
+		}, []string{"type"}) // 可达性检测计数counter
+
+	reachabilityLatency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metrics.NameSpace,
+			Subsystem: "reachability",
+			Name:      "latency_seconds",
+			Help:      "Latency of the last successful probe, -1 if the target is unreachable.",
+		}, []string{"interface", "target"})
+)
+
+var _ StatusInterface = (*ReachabilityImpl)(nil)
+
+// ReachabilityImpl 可达性检测，网卡本身up但上联交换机或对端异常时，本节点不应继续持有vip
+type ReachabilityImpl struct {
+	name string
+	cfg  ReachabilityConfig
+}
+
+// ReachabilityTarget 单个目标的探测结果
+type ReachabilityTarget struct {
+	Target string
+	// 探测成功的方式
+	Method  string
+	Latency time.Duration
+	// 所有方式都失败时各方式的错误
+	Errs []error
+}
+
+func (t *ReachabilityTarget) OK() bool {
+	return t.Method != ""
+}
+
+// ReachabilityResult 作为StatusAction.Extra
+type ReachabilityResult struct {
+	Targets   []ReachabilityTarget
+	Reachable int
+	Quorum    int
+}
+
+func (r *ReachabilityResult) String() string {
+	s := make([]string, 0, len(r.Targets)+1)
+	s = append(s, fmt.Sprintf("reachable=%d/%d quorum=%d", r.Reachable, len(r.Targets), r.Quorum))
+	for _, t := range r.Targets {
+		if t.OK() {
+			s = append(s, fmt.Sprintf("%s=%s(%v)", t.Target, t.Method, t.Latency.Round(time.Microsecond)))
+			continue
+		}
+		errs := make([]string, 0, len(t.Errs))
+		for _, err := range t.Errs {
+			errs = append(errs, err.Error())
+		}
+		s = append(s, fmt.Sprintf("%s=[%s]", t.Target, strings.Join(errs, "; ")))
+	}
+	return strings.Join(s, " ")
+}
+
+func (r *ReachabilityImpl) Name() string {
+	return r.name
+}
+
+func (r *ReachabilityImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{reachabilityCheckCounter, reachabilityLatency}
+}
+
+// CheckStatus 达到quorum时正常，部分目标不可达时为warn
+func (r *ReachabilityImpl) CheckStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", r.Name(), used)
+	}()
+	reachabilityCheckCounter.WithLabelValues("total").Inc()
+	sa := StatusAction{
+		Time:   now,
+		Name:   r.Name(),
+		Status: false,
+	}
+	res := r.probeAll(ctx)
+	sa.Extra = res
+	switch {
+	case res.Reachable == len(res.Targets):
+		sa.Level = LevelOK
+		sa.Status = true
+	case res.Reachable >= res.Quorum:
+		sa.Level = LevelWarn
+		sa.Status = true
+	default:
+		sa.Level = LevelCritical
+		reachabilityCheckCounter.WithLabelValues("failed").Inc()
+	}
+	return sa
+}
+
+// probeAll 并发探测所有目标，默认网关每次重新读取路由表
+func (r *ReachabilityImpl) probeAll(ctx context.Context) *ReachabilityResult {
+	res := &ReachabilityResult{Quorum: r.cfg.Quorum}
+	var ips []net.IP
+	if r.cfg.Gateway {
+		gw, err := procfs.DefaultGateway(r.cfg.Interface)
+		if err != nil {
+			res.Targets = append(res.Targets, ReachabilityTarget{Target: "gateway", Errs: []error{err}})
+		} else {
+			res.Targets = append(res.Targets, ReachabilityTarget{Target: gw.String()})
+			ips = append(ips, gw)
+		}
+	}
+	for _, t := range r.cfg.Targets {
+		res.Targets = append(res.Targets, ReachabilityTarget{Target: t})
+		ips = append(ips, net.ParseIP(t))
+	}
+	if res.Quorum == 0 {
+		res.Quorum = len(res.Targets)/2 + 1
+	}
+	// 读取网关失败时该目标不参与探测，下标需要偏移
+	offset := len(res.Targets) - len(ips)
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.probe(ctx, ip, &res.Targets[offset+i])
+		}()
+	}
+	wg.Wait()
+	for _, t := range res.Targets {
+		if t.OK() {
+			res.Reachable++
+			reachabilityLatency.WithLabelValues(r.cfg.Interface, t.Target).Set(t.Latency.Seconds())
+		} else {
+			reachabilityLatency.WithLabelValues(r.cfg.Interface, t.Target).Set(-1)
+		}
+	}
+	return res
+}
+
+// probe 按配置顺序尝试各探测方式，任一方式成功即可达
+func (r *ReachabilityImpl) probe(ctx context.Context, ip net.IP, t *ReachabilityTarget) {
+	for _, m := range r.cfg.Methods {
+		if ctx.Err() != nil {
+			t.Errs = append(t.Errs, ctx.Err())
+			return
+		}
+		var (
+			latency time.Duration
+			err     error
+		)
+		switch m {
+		case ProbeICMP:
+			latency, err = netprobe.ICMP(ctx, r.cfg.Interface, ip, r.cfg.ProbeTimeout)
+		case ProbeARP:
+			latency, err = netprobe.ARP(ctx, r.cfg.Interface, ip, r.cfg.ProbeTimeout)
+		case ProbeTCP:
+			latency, err = netprobe.TCP(ctx, r.cfg.Interface, ip, r.cfg.TCPPort, r.cfg.ProbeTimeout)
+		}
+		if err == nil {
+			t.Method, t.Latency = m, latency
+			return
+		}
+		t.Errs = append(t.Errs, err)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build netns
+
+package status_check
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"system-usability-detection/internal/nstest"
+)
+
+func TestReachabilityQuorum(t *testing.T) {
+	v := nstest.NewVeth(t, 2, 5, 6)
+	// .2 .5 .6在对端netns中，.3 .4不存在
+	up1, up2, up3 := v.IP(2).String(), v.IP(5).String(), v.IP(6).String()
+	down1, down2 := v.IP(3).String(), v.IP(4).String()
+	cases := []struct {
+		name      string
+		targets   []string
+		quorum    int
+		level     string
+		status    bool
+		reachable int
+		wantQ     int
+	}{
+		{"all reachable", []string{up1, up2, up3}, 0, LevelOK, true, 3, 2},
+		{"majority", []string{up1, up2, down1}, 0, LevelWarn, true, 2, 2},
+		{"minority", []string{up1, down1, down2}, 0, LevelCritical, false, 1, 2},
+		{"explicit quorum", []string{up1, down1, down2}, 1, LevelWarn, true, 1, 1},
+		{"explicit quorum not met", []string{up1, up2, down1}, 3, LevelCritical, false, 2, 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := ReachabilityConfig{
+				Interface:    v.Name,
+				Targets:      c.targets,
+				Methods:      []string{ProbeICMP, ProbeARP, ProbeTCP},
+				TCPPort:      22,
+				ProbeTimeout: 200 * time.Millisecond,
+				Quorum:       c.quorum,
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			r := &ReachabilityImpl{name: "reachability", cfg: cfg}
+			sa := r.CheckStatus(context.Background())
+			res := sa.Extra.(*ReachabilityResult)
+			if sa.Level != c.level || sa.Status != c.status {
+				t.Errorf("level=%s status=%v, want %s %v: %s", sa.Level, sa.Status, c.level, c.status, res)
+			}
+			if res.Reachable != c.reachable || res.Quorum != c.wantQ {
+				t.Errorf("reachable=%d quorum=%d, want %d %d", res.Reachable, res.Quorum, c.reachable, c.wantQ)
+			}
+		})
+	}
+}
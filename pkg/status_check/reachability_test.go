@@ -0,0 +1,58 @@
+package status_check
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"system-usability-detection/internal/nstest"
+)
+
+func TestReachabilityLoopback(t *testing.T) {
+	// 127.0.0.0/8的任意地址都会返回RST
+	r := &ReachabilityImpl{name: "reachability", cfg: ReachabilityConfig{
+		Targets:      []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"},
+		Methods:      []string{ProbeTCP},
+		TCPPort:      1,
+		ProbeTimeout: time.Second,
+	}}
+	sa := r.CheckStatus(context.Background())
+	res := sa.Extra.(*ReachabilityResult)
+	if sa.Level != LevelOK || !sa.Status || res.Reachable != 3 || res.Quorum != 2 {
+		t.Fatalf("level=%s status=%v: %s", sa.Level, sa.Status, res)
+	}
+}
+
+func TestReachabilityMethodFallback(t *testing.T) {
+	nstest.RequireRoot(t)
+	// arp不支持lo，应继续尝试tcp
+	r := &ReachabilityImpl{name: "reachability", cfg: ReachabilityConfig{
+		Interface:    "lo",
+		Targets:      []string{"127.0.0.1"},
+		Methods:      []string{ProbeARP, ProbeTCP},
+		TCPPort:      1,
+		ProbeTimeout: 300 * time.Millisecond,
+	}}
+	sa := r.CheckStatus(context.Background())
+	res := sa.Extra.(*ReachabilityResult)
+	if sa.Level != LevelOK || res.Targets[0].Method != ProbeTCP || len(res.Targets[0].Errs) != 1 {
+		t.Fatalf("unexpected result %s", res)
+	}
+}
+
+func TestReachabilityConfigValidate(t *testing.T) {
+	base := ReachabilityConfig{Methods: []string{ProbeTCP}, TCPPort: 22, ProbeTimeout: time.Second}
+	bad := []func(*ReachabilityConfig){
+		func(c *ReachabilityConfig) {},
+		func(c *ReachabilityConfig) { c.Targets = []string{"::1"} },
+		func(c *ReachabilityConfig) { c.Targets = []string{"127.0.0.1"}; c.Methods = []string{"udp"} },
+		func(c *ReachabilityConfig) { c.Targets = []string{"127.0.0.1"}; c.Quorum = 2 },
+	}
+	for i, f := range bad {
+		c := base
+		f(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
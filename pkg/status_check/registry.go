@@ -0,0 +1,251 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"system-usability-detection/internal/config"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/prometheus/client_golang/prometheus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Env 检测模块运行环境
+type Env struct {
+	Etcd      *clientv3.Client // 未连接etcd时(如命令行工具)为nil
+	NodeName  string
+	LocalIP   string
+	Interface string // vrrp网卡
+	NodeCount int    // 集群节点数
+	// keepalived pid文件，keepalived检测默认匹配该文件中的进程
+	KeepalivedPidFile string
+}
+
+// NewEnv 根据全局配置生成运行环境
+func NewEnv(cli *clientv3.Client) *Env {
+	gc := config.GlobalConfigInstance
+	return &Env{
+		Etcd:              cli,
+		NodeName:          gc.NodeName,
+		LocalIP:           gc.LocalIP,
+		Interface:         gc.VrrpNetInterface,
+		NodeCount:         gc.InstancesCount,
+		KeepalivedPidFile: gc.Keepalived.PidFile,
+	}
+}
+
+// Factory 检测类型，通过Register注册
+type Factory struct {
+	Description string
+	// Config 返回该类型配置结构体的指针，字段初始值即为默认值，按mapstructure标签解码，
+	// 解码后若实现了ConfigValidator则进行校验
+	Config func() any
+	// New 根据解码后的配置创建检测模块，cfg为Config返回的指针
+	New func(name string, cfg any, env *Env) (StatusInterface, error)
+}
+
+// ConfigValidator 检测模块配置校验，config validate和Build时调用
+type ConfigValidator interface {
+	Validate() error
+}
+
+// Worker 需要后台任务的检测模块实现该接口，Start在检测开始前调用，Stop在服务退出时调用
+type Worker interface {
+	Start(ctx context.Context) error
+	Stop()
+}
+
+// MetricsProvider 检测模块自带的指标，创建后注册到metrics.Gather
+type MetricsProvider interface {
+	Collectors() []prometheus.Collector
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[string]Factory{}
+)
+
+// DefaultChecks 默认检测模块，未在配置中出现时自动添加
+var DefaultChecks = []string{"front_interface", "keepalived"}
+
+// typeAliases 同一检测的新类型名称，已配置时不再补充对应的默认检测模块
+var typeAliases = map[string]string{"interface": "front_interface"}
+
+// Register 注册检测类型，各检测模块在init中调用，类型重复时panic
+func Register(typ string, f Factory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[typ]; ok {
+		panic("status_check: duplicate check type " + typ)
+	}
+	if f.Config == nil {
+		f.Config = func() any { return &struct{}{} }
+	}
+	registry[typ] = f
+}
+
+// Lookup 查找检测类型
+func Lookup(typ string) (Factory, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	f, ok := registry[typ]
+	return f, ok
+}
+
+// GetAllSupportType 所有已注册的检测类型
+func GetAllSupportType() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	support := make([]string, 0, len(registry))
+	for k := range registry {
+		support = append(support, k)
+	}
+	sort.Strings(support)
+	return support
+}
+
+// withDefaults 补充未配置的默认检测模块，front_interface在最前，keepalived在最后
+func withDefaults(specs []config.CheckSpec) []config.CheckSpec {
+	has := make(map[string]bool, len(specs))
+	for _, s := range specs {
+		has[s.Type] = true
+		if alias, ok := typeAliases[s.Type]; ok {
+			has[alias] = true
+		}
+	}
+	var ret []config.CheckSpec
+	if !has[DefaultChecks[0]] {
+		ret = append(ret, config.CheckSpec{Type: DefaultChecks[0]})
+	}
+	ret = append(ret, specs...)
+	for _, typ := range DefaultChecks[1:] {
+		if !has[typ] {
+			ret = append(ret, config.CheckSpec{Type: typ})
+		}
+	}
+	return ret
+}
+
+// decode 按类型解码检测模块配置，未知字段视为错误
+func decode(spec config.CheckSpec) (Factory, string, any, error) {
+	name := spec.Name
+	if name == "" {
+		name = spec.Type
+	}
+	f, ok := Lookup(spec.Type)
+	if !ok {
+		return f, name, nil, fmt.Errorf("check %s: unsupported check type %q", name, spec.Type)
+	}
+	cfg := f.Config()
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           cfg,
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		// 配置中的列表、map替换默认值而不是合并
+		ZeroFields: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+			mapstructure.TextUnmarshallerHookFunc(),
+		),
+	})
+	if err != nil {
+		return f, name, nil, err
+	}
+	options := spec.Options
+	if options == nil {
+		options = map[string]any{}
+	}
+	if err := dec.Decode(options); err != nil {
+		return f, name, nil, fmt.Errorf("check %s: %w", name, err)
+	}
+	if v, ok := cfg.(ConfigValidator); ok {
+		if err := v.Validate(); err != nil {
+			return f, name, nil, fmt.Errorf("check %s: %w", name, err)
+		}
+	}
+	return f, name, cfg, nil
+}
+
+// Validate 校验检测模块配置，不创建检测模块
+func Validate(specs []config.CheckSpec) []error {
+	var errs []error
+	names := make(map[string]bool)
+	for _, spec := range withDefaults(specs) {
+		_, name, _, err := decode(spec)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if names[name] {
+			errs = append(errs, fmt.Errorf("check %s: duplicate name", name))
+		}
+		names[name] = true
+	}
+	return errs
+}
+
+// Build 根据配置创建检测模块，每个模块按配置的超时时间包装，并注册模块自带的指标
+func Build(specs []config.CheckSpec, env *Env) ([]StatusInterface, error) {
+	var (
+		checks []StatusInterface
+		errs   []error
+		names  = make(map[string]bool)
+	)
+	for _, spec := range withDefaults(specs) {
+		f, name, cfg, err := decode(spec)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if names[name] {
+			errs = append(errs, fmt.Errorf("check %s: duplicate name", name))
+			continue
+		}
+		names[name] = true
+		si, err := f.New(name, cfg, env)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("check %s: %w", name, err))
+			continue
+		}
+		if mp, ok := si.(MetricsProvider); ok {
+			for _, c := range mp.Collectors() {
+				if err := metrics.Gather.Register(c); err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+					errs = append(errs, fmt.Errorf("check %s: register metrics: %w", name, err))
+				}
+			}
+		}
+		checks = append(checks, newGuardedCheck(name, spec.Type, si, spec.Timeout))
+	}
+	return checks, errors.Join(errs...)
+}
+
+// StartWorkers 启动检测模块的后台任务，ctx结束时停止
+func StartWorkers(ctx context.Context, checks []StatusInterface) error {
+	var (
+		started []Worker
+		errs    []error
+	)
+	for _, c := range checks {
+		w, ok := Unwrap(c).(Worker)
+		if !ok {
+			continue
+		}
+		if err := w.Start(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("start check %s: %w", c.Name(), err))
+			continue
+		}
+		started = append(started, w)
+	}
+	go func() {
+		<-ctx.Done()
+		for _, w := range started {
+			w.Stop()
+		}
+	}()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,25 @@
+package status_check
+
+import (
+	"testing"
+
+	"system-usability-detection/internal/config"
+)
+
+// newCheck 按守护进程相同的流程创建检测：类型默认配置经mapstructure解码options并校验，再由Factory.New创建
+func newCheck[T StatusInterface](t *testing.T, typ string, options map[string]any, env *Env) T {
+	t.Helper()
+	f, name, cfg, err := decode(config.CheckSpec{Type: typ, Options: options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	si, err := f.New(name, cfg, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := si.(T)
+	if !ok {
+		t.Fatalf("check %s is %T", typ, si)
+	}
+	return c
+}
@@ -1,60 +1,160 @@
 package status_check
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"system-usability-detection/internal/smb2"
+	"system-usability-detection/internal/util"
 	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+func init() {
+	Register("samba", Factory{
+		Description: "samba服务检测，完成一次SMB2 NEGOTIATE交互，并检查smb.conf中的共享",
+		Config: func() any {
+			return &SambaConfig{
+				Host:           "127.0.0.1",
+				Port:           445,
+				ConnectTimeout: 2 * time.Second,
+				SMBConf:        "/etc/samba/smb.conf",
+			}
+		},
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			return &SambaImpl{name: name, cfg: *cfg.(*SambaConfig)}, nil
+		},
+	})
+}
+
+type SambaConfig struct {
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
+	// 连接和等待NEGOTIATE响应的总超时时间
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
+	SMBConf        string        `mapstructure:"smb_conf"`
+	// smb.conf中必须存在的共享，不区分大小写
+	Shares []string `mapstructure:"shares"`
+}
+
+func (c *SambaConfig) Validate() error {
+	if c.Port <= 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid port %d", c.Port)
+	}
+	if c.ConnectTimeout <= 0 {
+		return errors.New("connect_timeout must be greater than 0")
+	}
+	if len(c.Shares) > 0 && c.SMBConf == "" {
+		return errors.New("smb_conf is required to check shares")
+	}
+	return nil
+}
+
+var sambaCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "samba",
+		Name:      "samba_check_updates",
+		Help:      "Counter of samba updates.",
+	}, []string{"type"}) // samba检测计数counter
+
 var _ StatusInterface = (*SambaImpl)(nil)
 
+// SambaImpl samba服务检测，smbd进程存在但无法处理请求时同样视为失败
 type SambaImpl struct {
+	name string
+	cfg  SambaConfig
+}
+
+// SambaResult 作为StatusAction.Extra
+type SambaResult struct {
+	Negotiate *smb2.NegotiateResult
+	Reasons   []string
+}
+
+func (r *SambaResult) String() string {
+	var s []string
+	if r.Negotiate != nil {
+		s = append(s, fmt.Sprintf("dialect=%s signing_required=%v latency=%v",
+			r.Negotiate.DialectString(), r.Negotiate.SigningRequired, r.Negotiate.Latency.Round(time.Microsecond)))
+	}
+	return strings.Join(append(s, r.Reasons...), " ")
 }
 
-func (n *SambaImpl) Name() string {
-	return "samba"
+func (s *SambaImpl) Name() string {
+	return s.name
 }
 
-// 缓存PID列表
-var sambaCachePidList = map[int]int{}
+func (s *SambaImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{sambaCheckCounter}
+}
 
-func (n *SambaImpl) CheckStatus() StatusAction {
+func (s *SambaImpl) CheckStatus(ctx context.Context) StatusAction {
 	now := time.Now()
 	defer func() {
 		used := time.Since(now)
-		logger.Infof("check %s used:%v", n.Name(), used)
+		util.Logger.Info("check %s used:%v", s.Name(), used)
 	}()
-	metrics.SambaCheckCounter.WithLabelValues("total").Inc()
+	sambaCheckCounter.WithLabelValues("total").Inc()
 	sa := StatusAction{
-		Time:   time.Now(),
-		Name:   n.Name(),
+		Time:   now,
+		Name:   s.Name(),
 		Status: false,
 	}
-	for k, v := range sambaCachePidList {
-		alived := checkProcessPid(v)
-		if !alived {
-			delete(sambaCachePidList, k)
-			continue
-		}
-		sa.Status = true
-		return sa
+	res := &SambaResult{}
+	sa.Extra = res
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	neg, err := smb2.Negotiate(ctx, addr, s.cfg.ConnectTimeout)
+	if err != nil {
+		res.Reasons = append(res.Reasons, fmt.Sprintf("negotiate: %v", err))
 	}
-	result := command.ExecBinBashCmd(5*time.Second, `pgrep smbd`) // OpenEuler和Ubuntu一样
-	if result.HasError() {
-		metrics.SambaCheckCounter.WithLabelValues("failed").Inc()
-		sa.Extra = result.Error()
-		return sa
+	res.Negotiate = neg
+	if len(s.cfg.Shares) > 0 {
+		if missing, err := missingShares(s.cfg.SMBConf, s.cfg.Shares); err != nil {
+			res.Reasons = append(res.Reasons, fmt.Sprintf("read %s: %v", s.cfg.SMBConf, err))
+		} else if len(missing) > 0 {
+			res.Reasons = append(res.Reasons, "missing shares: "+strings.Join(missing, ","))
+		}
 	}
-	if len(result.StdOutput) == 0 {
-		metrics.SambaCheckCounter.WithLabelValues("failed").Inc()
-		sa.Extra = errors.New("has no smbd process")
+	if len(res.Reasons) > 0 {
+		sambaCheckCounter.WithLabelValues("failed").Inc()
 		return sa
 	}
-	split, err := bytesToIntSlice(string(result.StdOutput), "\n")
-	logger.Infof("all smbd pids:%v message:%v", split, err)
-	for i := range split {
-		sambaCachePidList[i] = split[i]
-	}
 	sa.Status = true
 	return sa
 }
+
+// missingShares 返回smb.conf中不存在的共享，不处理include
+func missingShares(path string, shares []string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sections := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections[strings.ToLower(strings.TrimSpace(line[1:len(line)-1]))] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, share := range shares {
+		if name := strings.ToLower(share); name == "global" || !sections[name] {
+			missing = append(missing, share)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,157 @@
+package status_check
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"system-usability-detection/internal/smb2"
+)
+
+// negotiateResponse 构造SMB2 NEGOTIATE响应，不含direct tcp头
+func negotiateResponse(status uint32, dialect uint16, securityMode uint16) []byte {
+	le := binary.LittleEndian
+	msg := make([]byte, 64+64)
+	copy(msg, []byte{0xfe, 'S', 'M', 'B'})
+	le.PutUint16(msg[4:], 64)
+	le.PutUint32(msg[8:], status)
+	// SMB2_FLAGS_SERVER_TO_REDIR
+	le.PutUint32(msg[16:], 1)
+	body := msg[64:]
+	le.PutUint16(body[0:], 65)
+	le.PutUint16(body[2:], securityMode)
+	le.PutUint16(body[4:], dialect)
+	return msg
+}
+
+// fakeSMB 对每个连接读取一个NEGOTIATE请求，返回frame生成的数据后关闭连接
+func fakeSMB(t *testing.T, frame func() []byte) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var hdr [4]byte
+				if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+					return
+				}
+				req := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+				if _, err := io.ReadFull(conn, req); err != nil {
+					return
+				}
+				if string(req[:4]) != "\xfeSMB" || binary.LittleEndian.Uint16(req[12:]) != 0 {
+					t.Errorf("not an smb2 negotiate request: %x", req[:16])
+					return
+				}
+				_, _ = conn.Write(frame())
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// withLength 加上direct tcp传输的4字节长度头
+func withLength(msg []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...)
+}
+
+// newSambaCheck 连接本机的fake服务端，其余配置使用默认值
+func newSambaCheck(t *testing.T, port int, options map[string]any) *SambaImpl {
+	t.Helper()
+	if options == nil {
+		options = map[string]any{}
+	}
+	options["port"] = port
+	options["connect_timeout"] = "1s"
+	return newCheck[*SambaImpl](t, "samba", options, nil)
+}
+
+func TestSambaNegotiate(t *testing.T) {
+	port := fakeSMB(t, func() []byte { return withLength(negotiateResponse(0, 0x0302, 0x3)) })
+	sa := newSambaCheck(t, port, nil).CheckStatus(context.Background())
+	res := sa.Extra.(*SambaResult)
+	if !sa.Status || len(res.Reasons) != 0 {
+		t.Fatalf("status=%v: %s", sa.Status, res)
+	}
+	if res.Negotiate.DialectString() != "3.0.2" || !res.Negotiate.SigningRequired {
+		t.Fatalf("unexpected negotiate result: %s", res)
+	}
+}
+
+func TestSambaNegotiateFailures(t *testing.T) {
+	valid := negotiateResponse(0, 0x0210, 0x1)
+	smb1 := slices.Clone(valid)
+	smb1[0] = 0xff
+	badSig := slices.Clone(valid)
+	copy(badSig, "HTTP")
+	cases := []struct {
+		name  string
+		frame []byte
+		want  string
+	}{
+		// 长度头声明了完整响应，但连接在发送完之前关闭
+		{"truncated stream", withLength(valid)[:4+80], "unexpected EOF"},
+		// 长度头与内容一致，但响应体不完整
+		{"truncated body", withLength(valid[:64+8]), "invalid smb2 negotiate response"},
+		{"short length", withLength(valid[:64]), "invalid smb2 response length"},
+		{"smb1 signature", withLength(smb1), "server only supports smb1"},
+		{"bad signature", withLength(badSig), "not an smb2 response"},
+		{"status", withLength(negotiateResponse(0xc0000022, 0, 0)), "status 0xc0000022"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			port := fakeSMB(t, func() []byte { return c.frame })
+			sa := newSambaCheck(t, port, nil).CheckStatus(context.Background())
+			res := sa.Extra.(*SambaResult)
+			if sa.Status || res.Negotiate != nil {
+				t.Fatalf("expected failure: %s", res)
+			}
+			if len(res.Reasons) != 1 || !strings.HasPrefix(res.Reasons[0], "negotiate: ") || !strings.Contains(res.Reasons[0], c.want) {
+				t.Fatalf("reasons %q, want %q", res.Reasons, c.want)
+			}
+		})
+	}
+}
+
+func TestSambaStatusError(t *testing.T) {
+	port := fakeSMB(t, func() []byte { return withLength(negotiateResponse(0xc0000022, 0, 0)) })
+	_, err := smb2.Negotiate(context.Background(), net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), time.Second)
+	var serr smb2.StatusError
+	if !errors.As(err, &serr) || uint32(serr) != 0xc0000022 {
+		t.Fatalf("err=%v, want StatusError", err)
+	}
+}
+
+func TestSambaShares(t *testing.T) {
+	conf := filepath.Join(t.TempDir(), "smb.conf")
+	if err := os.WriteFile(conf, []byte("[global]\n  workgroup = WG\n[Data]\n  path = /data\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	port := fakeSMB(t, func() []byte { return withLength(negotiateResponse(0, 0x0300, 0x1)) })
+	sa := newSambaCheck(t, port, map[string]any{
+		"smb_conf": conf,
+		"shares":   []string{"data", "backup", "global"},
+	}).CheckStatus(context.Background())
+	res := sa.Extra.(*SambaResult)
+	if sa.Status || len(res.Reasons) != 1 || res.Reasons[0] != "missing shares: backup,global" {
+		t.Fatalf("status=%v reasons=%q", sa.Status, res.Reasons)
+	}
+}
@@ -0,0 +1,188 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"system-usability-detection/internal/systemd"
+	"system-usability-detection/internal/util"
+	"system-usability-detection/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	Register("systemd", Factory{
+		Description: "systemd unit状态检测，unit未处于active状态或窗口内自动重启次数过多时失败",
+		Config: func() any {
+			return &SystemdConfig{Backend: SystemdBackendSystemctl, MaxRestarts: 3, RestartWindow: 10 * time.Minute}
+		},
+		New: func(name string, cfg any, _ *Env) (StatusInterface, error) {
+			c := cfg.(*SystemdConfig)
+			var backend systemd.Backend = &systemd.Systemctl{Path: c.Command}
+			if c.Backend == SystemdBackendDBus {
+				backend = &systemd.Busctl{Path: c.Command}
+			}
+			return NewSystemdCheck(name, c, backend), nil
+		},
+	})
+}
+
+// systemd查询方式
+const (
+	SystemdBackendSystemctl = "systemctl"
+	// SystemdBackendDBus 通过busctl读取D-Bus属性
+	SystemdBackendDBus = "dbus"
+)
+
+type SystemdConfig struct {
+	// unit名称，省略后缀时为.service
+	Units   []string `mapstructure:"units"`
+	Backend string   `mapstructure:"backend"`
+	// systemctl或busctl的路径，默认从PATH中查找
+	Command string `mapstructure:"command"`
+	// restart_window内自动重启次数超过该值时失败，为0时不检查
+	MaxRestarts   uint64        `mapstructure:"max_restarts"`
+	RestartWindow time.Duration `mapstructure:"restart_window"`
+}
+
+func (c *SystemdConfig) Validate() error {
+	if len(c.Units) == 0 {
+		return errors.New("units are required")
+	}
+	if c.Backend != SystemdBackendSystemctl && c.Backend != SystemdBackendDBus {
+		return fmt.Errorf("unknown backend %q", c.Backend)
+	}
+	if c.MaxRestarts > 0 && c.RestartWindow <= 0 {
+		return errors.New("restart_window must be greater than 0")
+	}
+	return nil
+}
+
+var systemdCheckCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: metrics.NameSpace,
+		Subsystem: "systemd",
+		Name:      "systemd_check_updates",
+		Help:      "Counter of systemd updates.",
+	}, []string{"type"}) // systemd检测计数counter
+
+var _ StatusInterface = (*SystemdImpl)(nil)
+
+// restartSample 一次采样时unit的NRestarts
+type restartSample struct {
+	time      time.Time
+	nRestarts uint64
+}
+
+// SystemdImpl systemd unit检测，NRestarts为累计值，按采样记录计算窗口内的增量
+type SystemdImpl struct {
+	name    string
+	cfg     SystemdConfig
+	backend systemd.Backend
+
+	lock    sync.Mutex
+	samples map[string][]restartSample
+}
+
+// SystemdUnitResult 单个unit的检测结果
+type SystemdUnitResult struct {
+	systemd.UnitState
+	// restart_window内的自动重启次数
+	Restarts uint64
+	Reason   string
+}
+
+// SystemdResult 作为StatusAction.Extra
+type SystemdResult []SystemdUnitResult
+
+func (r SystemdResult) String() string {
+	s := make([]string, 0, len(r))
+	for _, u := range r {
+		item := fmt.Sprintf("%s=%s/%s(restarts=%d)", u.Name, u.ActiveState, u.SubState, u.Restarts)
+		if u.Reason != "" {
+			item += ":" + u.Reason
+		}
+		s = append(s, item)
+	}
+	return strings.Join(s, " ")
+}
+
+// NewSystemdCheck 创建systemd检测，backend可替换为其他实现
+func NewSystemdCheck(name string, cfg *SystemdConfig, backend systemd.Backend) *SystemdImpl {
+	return &SystemdImpl{name: name, cfg: *cfg, backend: backend, samples: make(map[string][]restartSample)}
+}
+
+func (s *SystemdImpl) Name() string {
+	return s.name
+}
+
+func (s *SystemdImpl) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{systemdCheckCounter}
+}
+
+func (s *SystemdImpl) CheckStatus(ctx context.Context) StatusAction {
+	now := time.Now()
+	defer func() {
+		used := time.Since(now)
+		util.Logger.Info("check %s used:%v", s.Name(), used)
+	}()
+	systemdCheckCounter.WithLabelValues("total").Inc()
+	sa := StatusAction{
+		Time:   now,
+		Name:   s.Name(),
+		Status: false,
+	}
+	states, err := s.backend.UnitStates(ctx, s.cfg.Units)
+	if err != nil {
+		systemdCheckCounter.WithLabelValues("failed").Inc()
+		sa.Extra = err
+		return sa
+	}
+	res := make(SystemdResult, len(states))
+	ok := true
+	for i, st := range states {
+		res[i] = SystemdUnitResult{UnitState: st, Restarts: s.restarts(st.Name, st.NRestarts, now)}
+		switch {
+		case st.LoadState != "loaded":
+			res[i].Reason = st.LoadState
+		case !st.Active():
+			res[i].Reason = "not active"
+		case s.cfg.MaxRestarts > 0 && res[i].Restarts > s.cfg.MaxRestarts:
+			res[i].Reason = fmt.Sprintf("restarted %d times in %v", res[i].Restarts, s.cfg.RestartWindow)
+		}
+		if res[i].Reason != "" {
+			ok = false
+		}
+	}
+	sa.Extra = res
+	if !ok {
+		systemdCheckCounter.WithLabelValues("failed").Inc()
+		return sa
+	}
+	sa.Status = true
+	return sa
+}
+
+// restarts 记录本次采样并返回窗口内的重启次数，NRestarts变小(unit被手动重启或reset-failed)时重新计数
+func (s *SystemdImpl) restarts(unit string, n uint64, now time.Time) uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	samples := s.samples[unit]
+	if len(samples) > 0 && samples[len(samples)-1].nRestarts > n {
+		samples = nil
+	}
+	samples = append(samples, restartSample{time: now, nRestarts: n})
+	// 保留窗口内最早的一个采样作为基准
+	i := 0
+	for i+1 < len(samples) && now.Sub(samples[i+1].time) >= s.cfg.RestartWindow {
+		i++
+	}
+	samples = samples[i:]
+	s.samples[unit] = samples
+	return n - samples[0].nRestarts
+}
@@ -0,0 +1,124 @@
+package status_check
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"system-usability-detection/internal/systemd"
+)
+
+// fakeSystemd 返回预设的unit状态
+type fakeSystemd struct {
+	states map[string]systemd.UnitState
+	err    error
+}
+
+func (f *fakeSystemd) UnitStates(_ context.Context, units []string) ([]systemd.UnitState, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	ret := make([]systemd.UnitState, 0, len(units))
+	for _, u := range units {
+		st, ok := f.states[u]
+		if !ok {
+			st = systemd.UnitState{LoadState: "not-found", ActiveState: "inactive", SubState: "dead"}
+		}
+		st.Name = u
+		ret = append(ret, st)
+	}
+	return ret, nil
+}
+
+func TestSystemdUnitStates(t *testing.T) {
+	backend := &fakeSystemd{states: map[string]systemd.UnitState{
+		"nginx":    {LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		"smb":      {LoadState: "loaded", ActiveState: "reloading", SubState: "reload"},
+		"stopped":  {LoadState: "loaded", ActiveState: "inactive", SubState: "dead"},
+		"crashed":  {LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+		"disabled": {LoadState: "masked", ActiveState: "inactive", SubState: "dead"},
+	}}
+	cases := []struct {
+		unit   string
+		status bool
+		reason string
+	}{
+		{"nginx", true, ""},
+		{"smb", true, ""},
+		{"stopped", false, "not active"},
+		{"crashed", false, "not active"},
+		{"disabled", false, "masked"},
+		{"missing", false, "not-found"},
+	}
+	for _, c := range cases {
+		t.Run(c.unit, func(t *testing.T) {
+			s := NewSystemdCheck("systemd", &SystemdConfig{Units: []string{c.unit}, Backend: SystemdBackendSystemctl}, backend)
+			sa := s.CheckStatus(context.Background())
+			res := sa.Extra.(SystemdResult)
+			if sa.Status != c.status || res[0].Reason != c.reason {
+				t.Fatalf("status=%v reason=%q, want %v %q", sa.Status, res[0].Reason, c.status, c.reason)
+			}
+		})
+	}
+
+	s := NewSystemdCheck("systemd", &SystemdConfig{Units: []string{"nginx", "crashed"}, Backend: SystemdBackendSystemctl}, backend)
+	if sa := s.CheckStatus(context.Background()); sa.Status {
+		t.Fatalf("one failed unit should fail the check: %s", sa.Extra)
+	}
+}
+
+func TestSystemdBackendError(t *testing.T) {
+	s := NewSystemdCheck("systemd", &SystemdConfig{Units: []string{"nginx"}, Backend: SystemdBackendSystemctl},
+		&fakeSystemd{err: errors.New("dbus unavailable")})
+	sa := s.CheckStatus(context.Background())
+	if sa.Status || sa.Extra.(error).Error() != "dbus unavailable" {
+		t.Fatalf("status=%v extra=%v", sa.Status, sa.Extra)
+	}
+}
+
+func TestSystemdRestartWindow(t *testing.T) {
+	s := NewSystemdCheck("systemd", &SystemdConfig{Units: []string{"nginx"}, MaxRestarts: 2, RestartWindow: 10 * time.Minute}, nil)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		at   time.Duration
+		n    uint64
+		want uint64
+	}{
+		{0, 5, 0},
+		{5 * time.Minute, 7, 2},
+		// 基准仍为窗口外最近的采样(0分钟)
+		{11 * time.Minute, 8, 3},
+		// 5分钟的采样已超出窗口，成为新的基准
+		{16 * time.Minute, 8, 1},
+		{30 * time.Minute, 8, 0},
+		// NRestarts变小(reset-failed或手动重启)时重新计数
+		{31 * time.Minute, 1, 0},
+		{32 * time.Minute, 3, 2},
+	}
+	for _, step := range steps {
+		if got := s.restarts("nginx", step.n, start.Add(step.at)); got != step.want {
+			t.Fatalf("at %v NRestarts=%d: restarts=%d, want %d", step.at, step.n, got, step.want)
+		}
+	}
+	// 各unit独立计数
+	if got := s.restarts("smb", 100, start.Add(33*time.Minute)); got != 0 {
+		t.Fatalf("restarts of a new unit=%d, want 0", got)
+	}
+}
+
+func TestSystemdTooManyRestarts(t *testing.T) {
+	backend := &fakeSystemd{states: map[string]systemd.UnitState{
+		"nginx": {LoadState: "loaded", ActiveState: "active", SubState: "running", NRestarts: 1},
+	}}
+	s := NewSystemdCheck("systemd", &SystemdConfig{Units: []string{"nginx"}, MaxRestarts: 2, RestartWindow: time.Hour}, backend)
+	if sa := s.CheckStatus(context.Background()); !sa.Status {
+		t.Fatalf("first sample should pass: %s", sa.Extra)
+	}
+	backend.states["nginx"] = systemd.UnitState{LoadState: "loaded", ActiveState: "active", SubState: "running", NRestarts: 4}
+	sa := s.CheckStatus(context.Background())
+	res := sa.Extra.(SystemdResult)
+	if sa.Status || res[0].Restarts != 3 || res[0].Reason != "restarted 3 times in 1h0m0s" {
+		t.Fatalf("status=%v: %s", sa.Status, res)
+	}
+}